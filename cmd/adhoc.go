@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// アドホック取得の出力形式
+const (
+	adHocFormatJSON  = "json"
+	adHocFormatJSONL = "jsonl"
+)
+
+// adHocFlags はアドホック取得に切り替えるフラグ。いずれかが指定されると設定やデータベースを使わずに取得する。
+// --source は通常の実行では対象の絞り込みに使う
+var adHocFlags = []string{"subreddits", "keywords", "sort", "time", "limit"}
+
+// adHocBuilders はデータソースごとにフラグから一時的な詳細設定を作る
+var adHocBuilders = map[string]func(cmd *cobra.Command) ([]model.FetchConfigDetail, error){
+	"reddit": redditAdHocDetails,
+}
+
+// isAdHocFetch はアドホック取得用のフラグが指定されているかを返す
+func isAdHocFetch(cmd *cobra.Command) bool {
+	for _, name := range adHocFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// adHocDetails はフラグから取得対象の詳細設定を作る。データソースの既定はreddit
+func adHocDetails(cmd *cobra.Command) ([]model.FetchConfigDetail, error) {
+	source, _ := cmd.Flags().GetString("source")
+	if source == "" {
+		source = "reddit"
+	}
+
+	build, ok := adHocBuilders[source]
+	if !ok {
+		return nil, fmt.Errorf("unsupported data source for ad-hoc fetch: '%s'", source)
+	}
+	return build(cmd)
+}
+
+// redditAdHocDetails はsubredditごとに詳細設定を作る。
+// subredditが指定されていない場合は全体をキーワード検索する設定を1件作る
+func redditAdHocDetails(cmd *cobra.Command) ([]model.FetchConfigDetail, error) {
+	flags := cmd.Flags()
+	subreddits, _ := flags.GetStringSlice("subreddits")
+	keywords, _ := flags.GetStringSlice("keywords")
+	sortBy, _ := flags.GetString("sort")
+	timeFilter, _ := flags.GetString("time")
+	limit, _ := flags.GetInt("limit")
+
+	var names []string
+	for _, s := range subreddits {
+		if s = strings.TrimPrefix(strings.TrimSpace(s), "r/"); s != "" {
+			names = append(names, s)
+		}
+	}
+	if len(names) == 0 {
+		if len(keywords) == 0 {
+			return nil, fmt.Errorf("--subreddits or --keywords is required for reddit")
+		}
+		names = []string{""}
+	}
+
+	details := make([]model.FetchConfigDetail, 0, len(names))
+	for _, name := range names {
+		d := model.NewRedditFetchConfigDetail(uuid.Nil)
+		d.Subreddit = name
+		d.Keywords = keywords
+		if sortBy != "" {
+			d.SortBy = model.RedditSort(sortBy)
+		}
+		if timeFilter != "" {
+			d.TimeFilter = model.RedditTimeFilter(timeFilter)
+		}
+		if limit != 0 {
+			d.LimitCount = limit
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// writeFetchedData は取得結果を書き出す。pathが "-" の場合は標準出力に書く
+func writeFetchedData(path, format string, data []*model.FetchedData) error {
+	if format != adHocFormatJSON && format != adHocFormatJSONL {
+		return fmt.Errorf("unsupported output format %q (expected %q or %q)", format, adHocFormatJSON, adHocFormatJSONL)
+	}
+
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	if format == adHocFormatJSONL {
+		for _, item := range data {
+			if err := enc.Encode(item); err != nil {
+				return fmt.Errorf("failed to write fetched data: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if data == nil {
+		data = []*model.FetchedData{}
+	}
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("failed to write fetched data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// 出力形式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// configSpec は configs create/update の入力と configs show の出力に使う設定の表現。
+// show の出力を編集してそのまま update に渡せる
+type configSpec struct {
+	ID       string            `json:"id,omitempty" yaml:"id,omitempty"`
+	UserID   string            `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	Name     string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Source   string            `json:"source,omitempty" yaml:"source,omitempty"`
+	Schedule string            `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Active   *bool             `json:"active,omitempty" yaml:"active,omitempty"`
+	Reddit   *redditConfigSpec `json:"reddit,omitempty" yaml:"reddit,omitempty"`
+	// Status は出力専用。入力では無視する
+	Status *configStatusSpec `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+type redditConfigSpec struct {
+	Subreddit  string   `json:"subreddit,omitempty" yaml:"subreddit,omitempty"`
+	SortBy     string   `json:"sort_by,omitempty" yaml:"sort_by,omitempty"`
+	TimeFilter string   `json:"time_filter,omitempty" yaml:"time_filter,omitempty"`
+	Limit      int      `json:"limit,omitempty" yaml:"limit,omitempty"`
+	Keywords   []string `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+}
+
+type configStatusSpec struct {
+	LastFetchedAt       *time.Time `json:"last_fetched_at,omitempty" yaml:"last_fetched_at,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty" yaml:"next_run_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty" yaml:"consecutive_failures,omitempty"`
+	LastError           string     `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	SuspendedAt         *time.Time `json:"suspended_at,omitempty" yaml:"suspended_at,omitempty"`
+	SuspendedReason     string     `json:"suspended_reason,omitempty" yaml:"suspended_reason,omitempty"`
+}
+
+// readConfigSpec はYAMLまたはJSONの設定を読み込む。pathが "-" の場合は標準入力から読む。
+// 拡張子が .json の場合はJSON、それ以外はYAML（JSONも読める）として解釈し、未知の項目はエラーにする
+func readConfigSpec(path string) (*configSpec, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var spec configSpec
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else {
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&spec); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	spec.Status = nil
+	return &spec, nil
+}
+
+// specFromConfig は保存されている設定を出力用の表現に変換する
+func specFromConfig(cfg service.EnrichedFetchConfig) configSpec {
+	c := cfg.UserFetchConfig
+	active := c.IsActive
+	spec := configSpec{
+		ID:       c.ID.String(),
+		UserID:   c.UserID.String(),
+		Name:     c.Name,
+		Source:   c.DataSourceID,
+		Schedule: c.Schedule,
+		Active:   &active,
+	}
+
+	if d, ok := cfg.Detail.(*model.RedditFetchConfigDetail); ok {
+		spec.Reddit = &redditConfigSpec{
+			Subreddit:  d.Subreddit,
+			SortBy:     string(d.SortBy),
+			TimeFilter: string(d.TimeFilter),
+			Limit:      d.LimitCount,
+			Keywords:   d.Keywords,
+		}
+	}
+
+	status := configStatusSpec{
+		LastFetchedAt:       c.LastFetchedAt,
+		ConsecutiveFailures: c.ConsecutiveFailures,
+		SuspendedAt:         c.SuspendedAt,
+	}
+	if next, err := c.NextRunAt(); err == nil {
+		status.NextRunAt = next
+	}
+	if c.LastError != nil {
+		status.LastError = *c.LastError
+	}
+	if c.SuspendedReason != nil {
+		status.SuspendedReason = *c.SuspendedReason
+	}
+	spec.Status = &status
+
+	return spec
+}
+
+// newConfigDetail はデータソースに応じた詳細設定を既定値で作る
+func newConfigDetail(source string, configID uuid.UUID) (model.FetchConfigDetail, error) {
+	switch source {
+	case "reddit":
+		return model.NewRedditFetchConfigDetail(configID), nil
+	case "":
+		return nil, fmt.Errorf("source is required")
+	default:
+		return nil, fmt.Errorf("unsupported data source: '%s'", source)
+	}
+}
+
+// applyTo は指定された項目のみを設定に反映する。作成済みの設定のデータソースと所有ユーザーは変更できない
+func (s *configSpec) applyTo(config *model.UserFetchConfig, detail model.FetchConfigDetail) error {
+	if s.Source != "" && s.Source != config.DataSourceID {
+		return fmt.Errorf("source cannot be changed from '%s' to '%s'", config.DataSourceID, s.Source)
+	}
+	if s.UserID != "" {
+		userID, err := uuid.Parse(s.UserID)
+		if err != nil {
+			return fmt.Errorf("invalid user_id %q: %w", s.UserID, err)
+		}
+		// 更新は名前・有効状態・スケジュールと検索条件のみを保存するため、所有ユーザーの変更は黙って無視せずエラーにする
+		if config.UserID != uuid.Nil && userID != config.UserID {
+			return fmt.Errorf("user_id cannot be changed from '%s' to '%s'", config.UserID, userID)
+		}
+		config.UserID = userID
+	}
+	if s.Name != "" {
+		config.Name = s.Name
+	}
+	if s.Schedule != "" {
+		config.Schedule = s.Schedule
+	}
+	if s.Active != nil {
+		config.IsActive = *s.Active
+	}
+
+	if s.Reddit != nil {
+		d, ok := detail.(*model.RedditFetchConfigDetail)
+		if !ok {
+			return fmt.Errorf("reddit settings given for a '%s' config", config.DataSourceID)
+		}
+		if s.Reddit.Subreddit != "" {
+			d.Subreddit = strings.TrimPrefix(s.Reddit.Subreddit, "r/")
+		}
+		if s.Reddit.SortBy != "" {
+			d.SortBy = model.RedditSort(s.Reddit.SortBy)
+		}
+		if s.Reddit.TimeFilter != "" {
+			d.TimeFilter = model.RedditTimeFilter(s.Reddit.TimeFilter)
+		}
+		if s.Reddit.Limit != 0 {
+			d.LimitCount = s.Reddit.Limit
+		}
+		if s.Reddit.Keywords != nil {
+			d.Keywords = s.Reddit.Keywords
+		}
+	}
+	return nil
+}
+
+// addConfigSpecFlags は configs create/update で共通のフラグを登録する
+func addConfigSpecFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("file", "f", "", "Read the config from a YAML or JSON file (\"-\" for stdin); flags override file values")
+	cmd.Flags().String("user", "", "User ID that owns the config")
+	cmd.Flags().String("name", "", "Config name")
+	cmd.Flags().String("source", "", "Data source (reddit)")
+	cmd.Flags().String("schedule", "", "Cron expression or interval such as \"@every 6h\" (default \"@daily\")")
+	cmd.Flags().Bool("active", true, "Whether the config is fetched")
+	cmd.Flags().String("subreddit", "", "Reddit: subreddit to fetch or search")
+	cmd.Flags().String("sort", "", "Reddit: sort order")
+	cmd.Flags().String("time-filter", "", "Reddit: time filter for top/controversial")
+	cmd.Flags().Int("limit", 0, "Reddit: maximum posts per fetch")
+	cmd.Flags().StringSlice("keywords", nil, "Reddit: search keywords (comma-separated)")
+}
+
+// configSpecFromFlags は -f で指定されたファイルを読み込み、明示されたフラグで上書きする
+func configSpecFromFlags(cmd *cobra.Command) (*configSpec, error) {
+	flags := cmd.Flags()
+
+	spec := &configSpec{}
+	if path, _ := flags.GetString("file"); path != "" {
+		var err error
+		if spec, err = readConfigSpec(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if flags.Changed("user") {
+		spec.UserID, _ = flags.GetString("user")
+	}
+	if flags.Changed("name") {
+		spec.Name, _ = flags.GetString("name")
+	}
+	if flags.Changed("source") {
+		spec.Source, _ = flags.GetString("source")
+	}
+	if flags.Changed("schedule") {
+		spec.Schedule, _ = flags.GetString("schedule")
+	}
+	if flags.Changed("active") {
+		active, _ := flags.GetBool("active")
+		spec.Active = &active
+	}
+
+	redditFlags := []string{"subreddit", "sort", "time-filter", "limit", "keywords"}
+	for _, name := range redditFlags {
+		if !flags.Changed(name) {
+			continue
+		}
+		if spec.Reddit == nil {
+			spec.Reddit = &redditConfigSpec{}
+		}
+		switch name {
+		case "subreddit":
+			spec.Reddit.Subreddit, _ = flags.GetString(name)
+		case "sort":
+			spec.Reddit.SortBy, _ = flags.GetString(name)
+		case "time-filter":
+			spec.Reddit.TimeFilter, _ = flags.GetString(name)
+		case "limit":
+			spec.Reddit.Limit, _ = flags.GetInt(name)
+		case "keywords":
+			spec.Reddit.Keywords, _ = flags.GetStringSlice(name)
+		}
+	}
+
+	return spec, nil
+}
+
+// writeStructured はvをJSONまたはYAMLで出力する
+func writeStructured(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
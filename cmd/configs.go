@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+)
+
+var (
+	retryAll bool
+
+	configsListUser   string
+	configsListSource string
+	configsListActive bool
+	configsListOutput string
+	configsShowOutput string
+	configsDeleteYes  bool
+)
+
+var configsCmd = &cobra.Command{
+	Use:   "configs",
+	Short: "Manage user fetch configs",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		injector, err = di.NewContainer()
+		if err != nil {
+			return err
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessUserScoped)
+	},
+}
+
+var configsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List fetch configs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		filter := output.FetchConfigFilter{
+			DataSourceID: configsListSource,
+			ActiveOnly:   configsListActive,
+		}
+		if configsListUser != "" {
+			userID, err := uuid.Parse(configsListUser)
+			if err != nil {
+				return fmt.Errorf("invalid user ID %q: %w", configsListUser, err)
+			}
+			filter.UserID = &userID
+		}
+
+		configs, err := svc.ListConfigs(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		if configsListOutput != outputTable {
+			return writeStructured(os.Stdout, configsListOutput, configs)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CONFIG ID\tNAME\tUSER ID\tSOURCE\tSCHEDULE\tSTATE\tLAST FETCHED")
+		for _, cfg := range configs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				cfg.ID, cfg.Name, cfg.UserID, cfg.DataSourceID, cfg.Schedule, configState(cfg), formatTime(cfg.LastFetchedAt))
+		}
+		return w.Flush()
+	},
+}
+
+var configsShowCmd = &cobra.Command{
+	Use:   "show <config-id>",
+	Short: "Show a fetch config with its source settings",
+	Long: `Show a fetch config with its source settings. The YAML output can be edited
+and passed back with "feedle configs update <config-id> -f <file>".`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		id, err := uuid.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid config ID %q: %w", args[0], err)
+		}
+
+		cfg, err := svc.GetEnrichedConfigUnvalidated(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return writeStructured(os.Stdout, configsShowOutput, specFromConfig(*cfg))
+	},
+}
+
+var configsCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a fetch config",
+	Long: `Create a fetch config from flags or a YAML/JSON file. For example:
+
+  feedle configs create --user <user-id> --name "Go news" --source reddit \
+    --subreddit golang --sort new --limit 50 --schedule "@every 6h"
+
+  feedle configs create -f config.yaml
+
+where config.yaml looks like:
+
+  user_id: 6f1c...
+  name: Go news
+  source: reddit
+  schedule: "@every 6h"
+  reddit:
+    subreddit: golang
+    sort_by: new
+    limit: 50
+    keywords: [generics, modules]`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		spec, err := configSpecFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		config := model.NewUserFetchConfig(uuid.Nil, "", spec.Source)
+		detail, err := newConfigDetail(spec.Source, config.ID)
+		if err != nil {
+			return err
+		}
+		if err := spec.applyTo(config, detail); err != nil {
+			return err
+		}
+
+		if err := svc.CreateConfig(ctx, config, detail); err != nil {
+			return err
+		}
+		fmt.Printf("Created config %s\n", config.ID)
+		return nil
+	},
+}
+
+var configsUpdateCmd = &cobra.Command{
+	Use:   "update <config-id>",
+	Short: "Update a fetch config",
+	Long: `Update a fetch config from flags or a YAML/JSON file. Only the given fields
+are changed; the data source and the owning user cannot be changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		id, err := uuid.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid config ID %q: %w", args[0], err)
+		}
+
+		spec, err := configSpecFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if spec.ID != "" && spec.ID != id.String() {
+			return fmt.Errorf("config ID in file (%s) does not match %s", spec.ID, id)
+		}
+
+		cfg, err := svc.GetEnrichedConfigUnvalidated(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := spec.applyTo(&cfg.UserFetchConfig, cfg.Detail); err != nil {
+			return err
+		}
+
+		if err := svc.UpdateConfig(ctx, &cfg.UserFetchConfig, cfg.Detail); err != nil {
+			return err
+		}
+		fmt.Printf("Updated config %s\n", id)
+		return nil
+	},
+}
+
+var configsDisableCmd = &cobra.Command{
+	Use:   "disable <config-id>...",
+	Short: "Stop fetching configs without deleting them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setConfigsActive(args, false)
+	},
+}
+
+var configsEnableCmd = &cobra.Command{
+	Use:   "enable <config-id>...",
+	Short: "Resume fetching disabled configs",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setConfigsActive(args, true)
+	},
+}
+
+var configsDeleteCmd = &cobra.Command{
+	Use:   "delete <config-id>...",
+	Short: "Delete configs and their source settings",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		ids, err := parseConfigIDs(args)
+		if err != nil {
+			return err
+		}
+		if !configsDeleteYes {
+			return errors.New("deleting configs cannot be undone; pass --yes to confirm (or use \"configs disable\")")
+		}
+
+		for _, id := range ids {
+			if err := svc.DeleteConfig(ctx, id); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted config %s\n", id)
+		}
+		return nil
+	},
+}
+
+var configsValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check every config for invalid settings",
+	Long: `Check the configs of every user (including disabled and suspended ones) for
+settings that would fail at fetch time, such as an invalid subreddit name or an
+unsupported sort order. Configs with problems are skipped by "feedle fetch".
+Exits with status 1 when any problem is found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		filter := output.FetchConfigFilter{DataSourceID: configsListSource}
+		if configsListUser != "" {
+			userID, err := uuid.Parse(configsListUser)
+			if err != nil {
+				return fmt.Errorf("invalid user ID %q: %w", configsListUser, err)
+			}
+			filter.UserID = &userID
+		}
+
+		results, err := svc.ValidateConfigs(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		invalid := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "USER ID\tCONFIG ID\tNAME\tPROBLEM")
+		for _, result := range results {
+			if len(result.Problems) == 0 {
+				continue
+			}
+			invalid++
+			for _, problem := range result.Problems {
+				cfg := result.Config
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cfg.UserID, cfg.ID, cfg.Name, problem)
+			}
+		}
+		if invalid == 0 {
+			fmt.Printf("All %d configs are valid\n", len(results))
+			return nil
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%d of %d configs have problems\n", invalid, len(results))
+		return exitWithCode(ExitError)
+	},
+}
+
+var configsRetryCmd = &cobra.Command{
+	Use:   "retry [config-id...]",
+	Short: "Re-enable configs that were suspended after repeated failures",
+	Long: `Re-enable configs that were automatically suspended after repeated permanent
+failures (for example a subreddit that does not exist). Without arguments the
+suspended configs and their reasons are listed; pass config IDs or --all to re-enable.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+		ids, err := parseConfigIDs(args)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			suspended, err := svc.GetSuspendedConfigs(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !retryAll {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "CONFIG ID\tNAME\tSOURCE\tSUSPENDED\tREASON")
+				for _, cfg := range suspended {
+					reason := ""
+					if cfg.SuspendedReason != nil {
+						reason = *cfg.SuspendedReason
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cfg.ID, cfg.Name, cfg.DataSourceID, formatTime(cfg.SuspendedAt), reason)
+				}
+				return w.Flush()
+			}
+
+			for _, cfg := range suspended {
+				ids = append(ids, cfg.ID)
+			}
+		}
+
+		for _, id := range ids {
+			if err := svc.Unsuspend(ctx, id); err != nil {
+				return err
+			}
+			fmt.Printf("Re-enabled config %s\n", id)
+		}
+		return nil
+	},
+}
+
+func setConfigsActive(args []string, active bool) error {
+	ctx := context.Background()
+	svc := do.MustInvoke[*service.FetchConfigService](injector)
+
+	ids, err := parseConfigIDs(args)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := svc.SetActive(ctx, id, active); err != nil {
+			return err
+		}
+		if active {
+			fmt.Printf("Enabled config %s\n", id)
+		} else {
+			fmt.Printf("Disabled config %s\n", id)
+		}
+	}
+	return nil
+}
+
+func parseConfigIDs(args []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(args))
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config ID %q: %w", arg, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// configState は一覧に表示する設定の状態
+func configState(cfg model.UserFetchConfig) string {
+	switch {
+	case cfg.IsSuspended():
+		return "suspended"
+	case !cfg.IsActive:
+		return "disabled"
+	default:
+		return "active"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configsCmd)
+	configsCmd.AddCommand(configsListCmd)
+	configsCmd.AddCommand(configsShowCmd)
+	configsCmd.AddCommand(configsCreateCmd)
+	configsCmd.AddCommand(configsUpdateCmd)
+	configsCmd.AddCommand(configsDisableCmd)
+	configsCmd.AddCommand(configsEnableCmd)
+	configsCmd.AddCommand(configsDeleteCmd)
+	configsCmd.AddCommand(configsValidateCmd)
+	configsCmd.AddCommand(configsRetryCmd)
+
+	configsListCmd.Flags().StringVar(&configsListUser, "user", "", "Only show configs of this user ID")
+	configsListCmd.Flags().StringVar(&configsListSource, "source", "", "Only show configs of this data source")
+	configsListCmd.Flags().BoolVar(&configsListActive, "active", false, "Only show configs that are enabled and not suspended")
+	configsListCmd.Flags().StringVarP(&configsListOutput, "output", "o", outputTable, "Output format (table, json or yaml)")
+	configsValidateCmd.Flags().StringVar(&configsListUser, "user", "", "Only check configs of this user ID")
+	configsValidateCmd.Flags().StringVar(&configsListSource, "source", "", "Only check configs of this data source")
+	configsShowCmd.Flags().StringVarP(&configsShowOutput, "output", "o", outputYAML, "Output format (yaml or json)")
+
+	addConfigSpecFlags(configsCreateCmd)
+	addConfigSpecFlags(configsUpdateCmd)
+
+	configsDeleteCmd.Flags().BoolVar(&configsDeleteYes, "yes", false, "Confirm deletion")
+	configsRetryCmd.Flags().BoolVar(&retryAll, "all", false, "Re-enable every suspended config")
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/daemon"
+	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
+	"github.com/YamaguchiKoki/feedle_batch/internal/usecase"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonInterval     time.Duration
+	daemonDrainTimeout time.Duration
+	daemonAddr         string
+	daemonLockTTL      time.Duration
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run as a long-lived process that fetches due configs periodically",
+	Long: `Keep running and periodically reload fetch configs, dispatching the ones whose
+schedule is due. Each run takes the same run lock as "fetch", so a tick is skipped
+while another process holds it. On SIGTERM/SIGINT the config being fetched is
+finished before exit; the remaining configs of that run are left for "fetch --resume".`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		injector, err = di.NewContainer()
+		if err != nil {
+			return err
+		}
+		if err := di.CheckFetchers(injector); err != nil {
+			return err
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessBatch)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		uc := do.MustInvoke[*usecase.FetchAndSaveUsecase](injector)
+
+		d := daemon.New(uc, daemon.Options{
+			Interval:     daemonInterval,
+			DrainTimeout: daemonDrainTimeout,
+		})
+		d.SetRunLock(do.MustInvoke[*service.RunLockService](injector), daemonLockTTL)
+
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", d.HealthHandler())
+		mux.Handle("/metrics", metrics.Handler())
+
+		server := &http.Server{
+			Addr:              daemonAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			slog.Info("Health and metrics endpoints listening", "addr", daemonAddr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Health server stopped", "error", err)
+			}
+		}()
+
+		slog.Info("Daemon started", "interval", daemonInterval)
+		if err := d.Run(ctx); err != nil {
+			return err
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shutdown health server", "error", err)
+		}
+
+		slog.Info("Daemon stopped")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().DurationVar(&daemonInterval, "interval", time.Minute, "How often to reload configs and dispatch due fetches")
+	daemonCmd.Flags().DurationVar(&daemonDrainTimeout, "drain-timeout", 5*time.Minute, "Maximum time to wait for in-flight fetches on shutdown")
+	daemonCmd.Flags().StringVar(&daemonAddr, "addr", ":8080", "Address for the health and metrics endpoints")
+	daemonCmd.Flags().DurationVar(&daemonLockTTL, "lock-ttl", 10*time.Minute, "Run lock lease duration; a lock not refreshed within this period is taken over")
+}
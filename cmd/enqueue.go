@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/usecase"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+)
+
+var enqueueMaxAttempts int
+
+var enqueueCmd = &cobra.Command{
+	Use:   "enqueue",
+	Short: "Enqueue fetch jobs for configs that are due",
+	Long:  `Enqueue a fetch job for every config whose schedule is due. Jobs are processed by "feedle worker".`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		injector, err = di.NewContainer()
+		if err != nil {
+			return err
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessBatch)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		uc := do.MustInvoke[*usecase.EnqueueDueConfigsUsecase](injector)
+
+		count, err := uc.Execute(ctx, enqueueMaxAttempts)
+		if err != nil {
+			return exitWithError("Failed to enqueue jobs", err)
+		}
+
+		slog.InfoContext(ctx, "Enqueued jobs", "count", count)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(enqueueCmd)
+
+	enqueueCmd.Flags().IntVar(&enqueueMaxAttempts, "max-attempts", usecase.DefaultMaxAttempts, "Maximum attempts before a job is marked dead")
+}
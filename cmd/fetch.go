@@ -2,51 +2,291 @@ package cmd
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
 	"github.com/YamaguchiKoki/feedle_batch/internal/usecase"
+	"github.com/google/uuid"
 	"github.com/samber/do"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	dryRun   bool
-	injector *do.Injector
+	dryRun      bool
+	waitLock    bool
+	waitTimeout time.Duration
+	lockTTL     time.Duration
+	resumeRunID string
+	reportPath  string
+	outputPath  string
+	outputFmt   string
+	fetchUser   string
+	fetchConfig []string
+	fetchSince  string
+	injector    *do.Injector
 )
 
 var fetchCmd = &cobra.Command{
 	Use:   "fetch",
 	Short: "Fetch data from configured sources",
-	Long:  `Fetch data from various sources (Reddit, Twitter, etc.) and save to database`,
+	Long: `Fetch data from various sources (Reddit, Twitter, etc.) and save to database.
+
+A summary table of per-config results is printed when the run finishes; use
+--report to also write it as JSON. The exit code reflects the outcome:
+
+  0  every config succeeded (or none were due)
+  1  the run could not be executed (e.g. the run lock is held)
+  2  partial failure: some configs failed or were skipped
+  3  total failure: no config succeeded
+
+Targeted runs: --user, --config-id and --source limit the run to matching configs.
+Configs given with --config-id are fetched even if they are not due, disabled or
+suspended; otherwise only due configs run. --since (a duration such as 24h or
+an RFC 3339 time) ignores schedules and refetches every matching config that
+has not been fetched since then. Targeted runs and --resume always refetch
+instead of skipping responses found in the HTTP cache:
+
+  feedle fetch --config-id <config-id>
+  feedle fetch --user <user-id> --source reddit --since 24h
+
+Ad-hoc mode: passing any of --subreddits, --keywords, --sort, --time or --limit
+fetches only the given targets instead of the stored configs (--source selects
+the data source). Nothing is read from or written to the database (Supabase
+settings are not needed); results are written as JSON to --output. One target
+is fetched per subreddit:
+
+  feedle fetch --subreddits golang,programming --sort top --time week --limit 10
+  feedle fetch --keywords "go generics" --output posts.jsonl --format jsonl
+
+In ad-hoc mode the exit code is 2 if some targets failed and 3 if all failed.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		var err error
 		injector, err = di.NewContainer()
 		if err != nil {
 			return err
 		}
-		return nil
+		if err := di.CheckFetchers(injector); err != nil {
+			return err
+		}
+		// アドホック取得はデータベースを使わない
+		if isAdHocFetch(cmd) {
+			return nil
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessBatch)
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		// 失敗時の内容はログとレポートに出力する
+		cmd.SilenceUsage = true
+
+		if isAdHocFetch(cmd) {
+			return runAdHocFetch(ctx, cmd)
+		}
+
+		var runID uuid.UUID
+		if resumeRunID != "" {
+			var err error
+			runID, err = uuid.Parse(resumeRunID)
+			if err != nil {
+				return exitWithError("Invalid run ID for --resume", err)
+			}
+		}
+
+		sel, err := fetchSelectorFromFlags(cmd, time.Now())
+		if err != nil {
+			return exitWithError("Invalid target flags", err)
+		}
+		if runID != uuid.Nil && !sel.IsZero() {
+			return exitWithError("Invalid flags", fmt.Errorf("--resume cannot be combined with --user, --config-id, --source or --since"))
+		}
+
+		// 手動実行と定期実行が重ならないようにロックを取得する
+		lockService := do.MustInvoke[*service.RunLockService](injector)
+		lock, err := lockService.Acquire(ctx, service.FetchRunLockName, service.RunLockOptions{
+			TTL:         lockTTL,
+			Wait:        waitLock,
+			WaitTimeout: waitTimeout,
+		})
+		if err != nil {
+			return exitWithError("Failed to acquire run lock", err)
+		}
+		slog.InfoContext(ctx, "Acquired run lock", "holder", lock.Holder())
 
 		uc := do.MustInvoke[*usecase.FetchAndSaveUsecase](injector)
 
-		if err := uc.Execute(ctx); err != nil {
-			log.Fatal("Failed to execute fetch:", err)
+		// ロックを失った場合は他のプロセスと重ならないよう処理中の取得を中断する
+		runCtx, cancelRun := lock.Bind(ctx)
+		var report *model.FetchRunReport
+		var execErr error
+		if runID != uuid.Nil {
+			report, execErr = uc.Resume(runCtx, runID)
+		} else {
+			report, execErr = uc.ExecuteSelected(runCtx, sel)
+		}
+		cancelRun()
+		lockErr := lock.Err()
+		if lockErr != nil {
+			execErr = errors.Join(execErr, lockErr)
 		}
 
-		log.Println("Fetch completed successfully")
-	},
-	PostRun: func(cmd *cobra.Command, args []string) {
-		if err := injector.Shutdown(); err != nil {
-			log.Printf("Warning: Failed to shutdown injector: %v", err)
+		if err := lock.Release(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to release run lock", "error", err)
+		}
+
+		// バッチ実行はスクレイプされないため、終了時にPushgatewayへ送信する
+		if url := viper.GetString("PUSHGATEWAY_URL"); url != "" {
+			if err := metrics.Push(ctx, url, "feedle_batch", viper.GetString("PUSHGATEWAY_INSTANCE")); err != nil {
+				slog.WarnContext(ctx, "Failed to push metrics", "error", err)
+			} else {
+				slog.DebugContext(ctx, "Pushed metrics", "url", url)
+			}
+		}
+
+		if report == nil {
+			return exitWithError("Failed to execute fetch", execErr)
+		}
+
+		if reportPath != "" {
+			if err := writeRunReport(reportPath, report); err != nil {
+				slog.ErrorContext(ctx, "Failed to write run report", "error", err)
+			}
+		}
+		if reportPath != "-" {
+			if err := printRunReport(os.Stdout, report); err != nil {
+				slog.WarnContext(ctx, "Failed to print run report", "error", err)
+			}
+		}
+
+		if execErr != nil {
+			slog.ErrorContext(ctx, "Fetch run failed", "error", execErr)
 		}
+		code := exitCodeForReport(report)
+		if lockErr != nil {
+			// 他のプロセスが同時に実行していた可能性があるため、結果に関係なく失敗とする
+			code = ExitFailure
+		}
+		if code != ExitSuccess {
+			slog.WarnContext(ctx, "Fetch finished with failures", "outcome", report.Outcome, "exit_code", code)
+			return exitWithCode(code)
+		}
+
+		slog.InfoContext(ctx, "Fetch completed successfully")
+		return nil
 	},
 }
 
+// fetchSelectorFromFlags は --user, --config-id, --source, --since から実行対象の条件を作る
+func fetchSelectorFromFlags(cmd *cobra.Command, now time.Time) (service.FetchSelector, error) {
+	var sel service.FetchSelector
+
+	if fetchUser != "" {
+		userID, err := uuid.Parse(fetchUser)
+		if err != nil {
+			return sel, fmt.Errorf("invalid user ID %q: %w", fetchUser, err)
+		}
+		sel.UserID = &userID
+	}
+
+	ids, err := parseConfigIDs(fetchConfig)
+	if err != nil {
+		return sel, err
+	}
+	sel.ConfigIDs = ids
+
+	sel.DataSourceID, _ = cmd.Flags().GetString("source")
+
+	if fetchSince != "" {
+		since, err := parseSince(fetchSince, now)
+		if err != nil {
+			return sel, err
+		}
+		sel.Since = &since
+	}
+	return sel, nil
+}
+
+// parseSince は "24h" のような期間（現在時刻から遡る）またはRFC 3339形式・日付の時刻を解釈する
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("--since duration must be positive: %q", value)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (expected a duration such as 24h, an RFC 3339 time or a date)", value)
+}
+
+// runAdHocFetch はフラグで指定された対象を取得して出力する。ロックや実行履歴は使わない
+func runAdHocFetch(ctx context.Context, cmd *cobra.Command) error {
+	if resumeRunID != "" || fetchUser != "" || len(fetchConfig) > 0 || fetchSince != "" {
+		return exitWithError("Invalid flags", fmt.Errorf("--resume, --user, --config-id and --since cannot be used with ad-hoc fetch flags"))
+	}
+
+	details, err := adHocDetails(cmd)
+	if err != nil {
+		return exitWithError("Invalid ad-hoc fetch flags", err)
+	}
+
+	uc := do.MustInvoke[*usecase.AdHocFetchUsecase](injector)
+	data, execErr := uc.Execute(ctx, details)
+	if execErr != nil && data == nil {
+		var verr *model.ValidationError
+		if errors.As(execErr, &verr) {
+			return exitWithError("Invalid ad-hoc fetch flags", execErr)
+		}
+		slog.ErrorContext(ctx, "Ad-hoc fetch failed", "error", execErr)
+		return exitWithCode(ExitFailure)
+	}
+
+	if err := writeFetchedData(outputPath, outputFmt, data); err != nil {
+		return exitWithError("Failed to write fetched data", err)
+	}
+
+	if execErr != nil {
+		slog.WarnContext(ctx, "Ad-hoc fetch finished with failures", "items", len(data), "error", execErr)
+		return exitWithCode(ExitPartialFailure)
+	}
+	slog.InfoContext(ctx, "Ad-hoc fetch completed", "targets", len(details), "items", len(data))
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(fetchCmd)
 
 	fetchCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Run without saving to database")
+	fetchCmd.Flags().BoolVar(&waitLock, "wait", false, "Wait for a concurrent run to finish instead of failing immediately")
+	fetchCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 30*time.Minute, "Maximum time to wait for the run lock with --wait (0 = no limit)")
+	fetchCmd.Flags().StringVar(&resumeRunID, "resume", "", "Resume an interrupted run by ID, processing only configs that have not succeeded")
+	fetchCmd.Flags().DurationVar(&lockTTL, "lock-ttl", 10*time.Minute, "Run lock lease duration; a lock not refreshed within this period is taken over")
+	fetchCmd.Flags().StringVar(&reportPath, "report", "", "Write the run report as JSON to this file (\"-\" for stdout instead of the table)")
+	fetchCmd.Flags().StringVar(&fetchUser, "user", "", "Only fetch configs of this user ID")
+	fetchCmd.Flags().StringSliceVar(&fetchConfig, "config-id", nil, "Only fetch these config IDs (comma-separated or repeated), even if not due")
+	fetchCmd.Flags().StringVar(&fetchSince, "since", "", "Refetch matching configs not fetched since this time, ignoring schedules (duration such as 24h, RFC 3339 time or date)")
+	fetchCmd.Flags().String("source", "", "Only fetch configs of this data source; in ad-hoc mode the source to fetch from (default \"reddit\")")
+	fetchCmd.Flags().StringSlice("subreddits", nil, "Ad-hoc: subreddits to fetch (comma-separated)")
+	fetchCmd.Flags().StringSlice("keywords", nil, "Ad-hoc: search keywords (comma-separated)")
+	fetchCmd.Flags().String("sort", "", "Ad-hoc: sort order (default \"hot\")")
+	fetchCmd.Flags().String("time", "", "Ad-hoc: time filter for top/controversial or search (default \"day\")")
+	fetchCmd.Flags().Int("limit", 0, "Ad-hoc: maximum posts per target (default 25)")
+	fetchCmd.Flags().StringVarP(&outputPath, "output", "o", "-", "Ad-hoc: write fetched items to this file (\"-\" for stdout)")
+	fetchCmd.Flags().StringVar(&outputFmt, "format", adHocFormatJSON, "Ad-hoc: output format (json, jsonl)")
+	fetchCmd.Flags().String("pushgateway-url", "", "Pushgateway-compatible endpoint to push metrics to when the run finishes")
+	fetchCmd.Flags().String("pushgateway-instance", metrics.DefaultPushInstance, "Instance label of the pushed metrics group; keep it fixed so each run replaces the previous one")
+
+	_ = viper.BindPFlag("PUSHGATEWAY_URL", fetchCmd.Flags().Lookup("pushgateway-url"))
+	_ = viper.BindPFlag("PUSHGATEWAY_INSTANCE", fetchCmd.Flags().Lookup("pushgateway-instance"))
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// 終了コード。CIでは部分的な失敗と全体の失敗を区別して通知できる
+const (
+	ExitSuccess        = 0
+	ExitError          = 1 // ロック取得失敗など、実行自体ができなかった
+	ExitPartialFailure = 2 // 一部の設定が失敗またはスキップされた
+	ExitFailure        = 3 // 成功した設定が1件もない
+)
+
+// exitCodeForReport はレポートの結果に対応する終了コードを返す
+func exitCodeForReport(report *model.FetchRunReport) int {
+	switch report.Outcome {
+	case model.FetchRunOutcomeSuccess:
+		return ExitSuccess
+	case model.FetchRunOutcomePartialFailure:
+		return ExitPartialFailure
+	default:
+		return ExitFailure
+	}
+}
+
+// writeRunReport はレポートをJSONで書き出す。pathが "-" の場合は標準出力に書く
+func writeRunReport(path string, report *model.FetchRunReport) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// printRunReport はレポートを表形式で出力する
+func printRunReport(out io.Writer, report *model.FetchRunReport) error {
+	fmt.Fprintf(out, "Run:      %s\n", report.RunID)
+	fmt.Fprintf(out, "Status:   %s (%s)\n", report.Status, report.Outcome)
+	fmt.Fprintf(out, "Started:  %s\n", formatTime(&report.StartedAt))
+	fmt.Fprintf(out, "Finished: %s\n", formatTime(report.FinishedAt))
+	if report.FinishedAt != nil {
+		fmt.Fprintf(out, "Duration: %s\n", formatSeconds(report.DurationSeconds))
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONFIG ID\tSOURCE\tSTATUS\tSAVED\tDURATION\tERROR")
+	for _, item := range report.Configs {
+		source := item.Source
+		if source == "" {
+			source = "-"
+		}
+		duration := "-"
+		if item.StartedAt != nil && item.FinishedAt != nil {
+			duration = formatSeconds(item.DurationSeconds)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", item.ConfigID, source, item.Status, item.ItemsSaved, duration, item.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	t := report.Totals
+	fmt.Fprintf(out, "\n%d configs: %d succeeded, %d failed, %d skipped, %d pending; %d items saved\n",
+		t.Configs, t.Succeeded, t.Failed, t.Skipped, t.Pending, t.ItemsSaved)
+
+	// 閉じているブレーカーは表示しない
+	sources := make([]string, 0, len(report.CircuitBreakers))
+	for source, cb := range report.CircuitBreakers {
+		if cb.State != "closed" || cb.Skipped > 0 {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	sort.Strings(sources)
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tCIRCUIT\tFAILURES\tSKIPPED\tOPENED\tLAST ERROR")
+	for _, source := range sources {
+		cb := report.CircuitBreakers[source]
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", source, cb.State, cb.ConsecutiveFailures, cb.Skipped, formatTime(cb.OpenedAt), cb.LastError)
+	}
+	return w.Flush()
+}
+
+func formatSeconds(s float64) string {
+	return (time.Duration(s * float64(time.Second))).Round(time.Millisecond).String()
+}
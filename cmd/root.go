@@ -1,28 +1,48 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/YamaguchiKoki/feedle_batch/internal/logging"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// shutdownTracing は終了前に未送信のスパンを送信する
+var shutdownTracing tracing.ShutdownFunc = func(context.Context) error { return nil }
+
 var rootCmd = &cobra.Command{
 	Use:   "feedle",
 	Short: "Feedle - Multi-source feed aggregator",
 	Long: `Feedle is a batch processor that fetches data from multiple sources
 including Twitter, YouTube, Instagram, Reddit, and Hacker News.`,
+	// エラーは Execute で出力する
+	SilenceErrors: true,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// PostRun はRunEがエラーを返すと呼ばれないため、ここで終了処理を行う
+	shutdownInjector()
+	flushTracing()
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 }
 
@@ -30,6 +50,18 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .env)")
+	rootCmd.PersistentFlags().String("log-format", logging.FormatText, "log output format (text or json)")
+	rootCmd.PersistentFlags().String("log-level", "info", "log level (debug, info, warn, error)")
+
+	rootCmd.PersistentFlags().String("trace-exporter", tracing.ExporterNone, "trace exporter (none, otlp or stdout; stdout writes spans to stderr or TRACE_FILE)")
+	rootCmd.PersistentFlags().String("trace-endpoint", "", "OTLP/HTTP endpoint URL for traces (default: OTEL_EXPORTER_OTLP_ENDPOINT)")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "disable the on-disk HTTP response cache and always fetch fresh data")
+
+	_ = viper.BindPFlag("LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	_ = viper.BindPFlag("LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	_ = viper.BindPFlag("TRACE_EXPORTER", rootCmd.PersistentFlags().Lookup("trace-exporter"))
+	_ = viper.BindPFlag("TRACE_ENDPOINT", rootCmd.PersistentFlags().Lookup("trace-endpoint"))
+	_ = viper.BindPFlag("HTTP_CACHE_DISABLED", rootCmd.PersistentFlags().Lookup("no-cache"))
 }
 
 func initConfig() {
@@ -46,13 +78,94 @@ func initConfig() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	// 設定ファイルの読み込み
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Println("No config file found, using environment variables only")
+	readErr := viper.ReadInConfig()
+
+	// ログ設定は設定ファイル・環境変数・フラグのいずれからも指定できるため、読み込み後に初期化する
+	if err := logging.Setup(os.Stderr, viper.GetString("LOG_FORMAT"), viper.GetString("LOG_LEVEL")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var traceWriter io.Writer = os.Stderr
+	var traceFile *os.File
+	if path := viper.GetString("TRACE_FILE"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create trace file: %w", err))
+			os.Exit(1)
+		}
+		traceWriter, traceFile = f, f
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    viper.GetString("TRACE_EXPORTER"),
+		Endpoint:    viper.GetString("TRACE_ENDPOINT"),
+		Insecure:    viper.GetBool("TRACE_INSECURE"),
+		SampleRatio: viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		ServiceName: viper.GetString("TRACE_SERVICE_NAME"),
+		// 標準出力は取得結果やレポートのJSONに使うため、スパンは標準エラー出力かファイルに書く
+		Writer: traceWriter,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	shutdownTracing = func(ctx context.Context) error {
+		err := shutdown(ctx)
+		if traceFile != nil {
+			err = errors.Join(err, traceFile.Close())
+		}
+		return err
+	}
+
+	if readErr != nil {
+		if _, ok := readErr.(viper.ConfigFileNotFoundError); ok {
+			slog.Info("No config file found, using environment variables only")
 		} else {
-			log.Printf("Error reading config file: %v", err)
+			slog.Warn("Error reading config file", "error", readErr)
 		}
 	} else {
-		log.Printf("Using config file: %s", viper.ConfigFileUsed())
+		slog.Info("Using config file", "path", viper.ConfigFileUsed())
+	}
+}
+
+// flushTracing は未送信のスパンを送信する。エクスポート先が応答しない場合でも終了できるよう時間を区切る
+func flushTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+}
+
+// shutdownInjector はDIコンテナのサービスを終了する。コンテナを作らないコマンドでは何もしない
+func shutdownInjector() {
+	if injector == nil {
+		return
+	}
+	if err := injector.Shutdown(); err != nil {
+		slog.Warn("Failed to shutdown injector", "error", err)
 	}
+	injector = nil
+}
+
+// exitError はコマンドの終了コードを Execute に伝える。終了処理（DIコンテナの終了、トレースの送信）を
+// 済ませてから終了するため、コマンドの中では os.Exit を呼ばずにこのエラーを返す
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitWithCode は指定した終了コードで終了させるエラーを返す。原因はログに出力済みであること
+func exitWithCode(code int) error {
+	return &exitError{code: code}
+}
+
+// exitWithError はエラーをログに出力し、ExitError で終了させるエラーを返す
+func exitWithError(msg string, err error) error {
+	slog.Error(msg, "error", err)
+	return exitWithCode(ExitError)
 }
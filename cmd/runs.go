@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runsLimit int
+	runsJSON  bool
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Inspect fetch run history",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		injector, err = di.NewContainer()
+		if err != nil {
+			return err
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessBatch)
+	},
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent fetch runs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		repo := do.MustInvoke[output.FetchRunRepository](injector)
+
+		runs, err := repo.List(ctx, runsLimit)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUN ID\tSTATUS\tSTARTED\tFINISHED")
+		for _, run := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.ID, run.Status, formatTime(&run.StartedAt), formatTime(run.FinishedAt))
+		}
+		return w.Flush()
+	},
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show per-config progress of a fetch run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		repo := do.MustInvoke[output.FetchRunRepository](injector)
+
+		runID, err := uuid.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid run ID: %w", err)
+		}
+
+		run, err := repo.GetByID(ctx, runID)
+		if err != nil {
+			return err
+		}
+		items, err := repo.GetItems(ctx, runID)
+		if err != nil {
+			return err
+		}
+
+		report := model.NewFetchRunReport(run, items)
+		if runsJSON {
+			return writeRunReport("-", report)
+		}
+		return printRunReport(os.Stdout, report)
+	},
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsShowCmd)
+
+	runsListCmd.Flags().IntVar(&runsLimit, "limit", 20, "Maximum number of runs to show")
+	runsShowCmd.Flags().BoolVar(&runsJSON, "json", false, "Print the run report as JSON")
+}
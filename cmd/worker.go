@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/di"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/usecase"
+	"github.com/YamaguchiKoki/feedle_batch/internal/worker"
+	"github.com/samber/do"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workerID           string
+	workerBatchSize    int
+	workerLease        time.Duration
+	workerPollInterval time.Duration
+	workerMetricsAddr  string
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Process fetch jobs from the job queue",
+	Long: `Claim fetch jobs from the queue and execute them. Any number of workers can run
+concurrently; jobs are claimed with FOR UPDATE SKIP LOCKED and held by a lease
+that is extended by heartbeats until each claimed job has been processed. A job
+whose lease is lost is abandoned without recording a result, and jobs for configs
+that were deactivated or suspended after they were queued are completed without fetching.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		injector, err = di.NewContainer()
+		if err != nil {
+			return err
+		}
+		if err := di.CheckFetchers(injector); err != nil {
+			return err
+		}
+		return di.CheckSupabaseAccess(context.Background(), injector, di.AccessBatch)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		if workerMetricsAddr != "" {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			server := &http.Server{Addr: workerMetricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				slog.Info("Metrics endpoint listening", "addr", workerMetricsAddr)
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("Metrics server failed", "error", err)
+				}
+			}()
+			defer server.Close()
+		}
+
+		queue := do.MustInvoke[output.FetchJobQueue](injector)
+		uc := do.MustInvoke[*usecase.FetchAndSaveUsecase](injector)
+
+		w := worker.New(queue, uc, worker.Options{
+			ID:           workerID,
+			BatchSize:    workerBatchSize,
+			Lease:        workerLease,
+			PollInterval: workerPollInterval,
+		})
+
+		return w.Run(ctx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+
+	workerCmd.Flags().StringVar(&workerID, "id", "", "Worker identifier recorded as the lease holder (default: random)")
+	workerCmd.Flags().IntVar(&workerBatchSize, "batch-size", 1, "Number of jobs to claim at once")
+	workerCmd.Flags().DurationVar(&workerLease, "lease", 5*time.Minute, "How long a claimed job is reserved before other workers may take it")
+	workerCmd.Flags().DurationVar(&workerPollInterval, "poll-interval", 10*time.Second, "How long to wait when the queue is empty")
+	workerCmd.Flags().StringVar(&workerMetricsAddr, "metrics-addr", "", "Address to expose /metrics on (disabled when empty)")
+}
@@ -0,0 +1,363 @@
+package article
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minParagraphLength より短い段落は本文の判定に使わない（ボタンやキャプションなど）
+const minParagraphLength = 25
+
+var (
+	// unlikelyPattern はclassやidがこれに一致する要素を本文の候補から除く
+	unlikelyPattern = regexp.MustCompile(`(?i)comment|sidebar|footer|foot|nav|menu|share|social|related|promo|advert|sponsor|\bads?\b|cookie|subscribe|newsletter|popup|modal|banner|breadcrumb|pagination`)
+	// likelyPattern に一致する要素は unlikelyPattern に一致しても除かない
+	likelyPattern = regexp.MustCompile(`(?i)article|content|main|body|post|entry|story|text`)
+	// bylinePattern は著者名を含む要素のclass・id・itemprop
+	bylinePattern = regexp.MustCompile(`(?i)byline|author`)
+	spaces        = regexp.MustCompile(`\s+`)
+)
+
+// removedElements は本文の判定の前に取り除く要素
+var removedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Iframe: true,
+	atom.Svg: true, atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Textarea: true, atom.Object: true,
+}
+
+// textBlocks はプレーンテキストで段落として区切る要素
+var textBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Table: true, atom.Tr: true, atom.Figure: true, atom.Figcaption: true, atom.Br: true, atom.Hr: true,
+}
+
+// ParseHTML はHTMLのページから記事を取り出す。pageURLは相対URLの解決に使う。
+// ネットワークを使わないため、保存したHTMLのページでそのまま確認できる
+func ParseHTML(r io.Reader, pageURL *url.URL) (*model.Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := collectMeta(doc)
+	a := &model.Article{
+		Title:       first(meta["og:title"], meta["twitter:title"], meta["title"]),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    meta["og:site_name"],
+		Byline:      first(meta["author"], nonURL(meta["article:author"])),
+	}
+	if pageURL != nil {
+		a.URL = pageURL.String()
+	}
+	if img := first(meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]); img != "" {
+		a.Image = resolveURL(pageURL, img)
+	}
+	if t, err := time.Parse(time.RFC3339, first(meta["article:published_time"], meta["datePublished"])); err == nil {
+		utc := t.UTC()
+		a.PublishedAt = &utc
+	}
+
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	if a.Byline == "" {
+		a.Byline = findByline(body)
+	}
+	if a.PublishedAt == nil {
+		if t := findFirst(body, atom.Time); t != nil {
+			if parsed, err := time.Parse(time.RFC3339, attr(t, "datetime")); err == nil {
+				utc := parsed.UTC()
+				a.PublishedAt = &utc
+			}
+		}
+	}
+
+	prune(body)
+	if content := mainContent(body); content != nil {
+		a.Text = plainText(content)
+		a.WordCount = countWords(a.Text)
+	}
+	return a, nil
+}
+
+// collectMeta は meta 要素の property・name と content、および title 要素の文字列を集める。同じキーは最初の値を使う
+func collectMeta(doc *html.Node) map[string]string {
+	meta := make(map[string]string)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Meta:
+				key := first(attr(n, "property"), attr(n, "name"), attr(n, "itemprop"))
+				content := strings.TrimSpace(attr(n, "content"))
+				if key != "" && content != "" {
+					if _, ok := meta[key]; !ok {
+						meta[key] = content
+					}
+				}
+			case atom.Title:
+				if _, ok := meta["title"]; !ok {
+					meta["title"] = collapse(textContent(n))
+				}
+			case atom.Body:
+				return // metaはheadにある
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return meta
+}
+
+// findByline は rel="author" のリンク、または class・id・itemprop に author や byline を含む短い要素の文字列を返す
+func findByline(root *html.Node) string {
+	var byline string
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && !removedElements[n.DataAtom] {
+			if attr(n, "rel") == "author" || bylinePattern.MatchString(attr(n, "class")+" "+attr(n, "id")+" "+attr(n, "itemprop")) {
+				text := strings.TrimPrefix(collapse(textContent(n)), "By ")
+				if text != "" && utf8.RuneCountInString(text) < 100 {
+					byline = text
+					return true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(root)
+	return byline
+}
+
+// prune は本文と関係のない要素（スクリプト、ナビゲーション、コメント欄など）を取り除く
+func prune(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			n.RemoveChild(c)
+		case c.Type == html.ElementNode && isUnlikely(c):
+			n.RemoveChild(c)
+		default:
+			prune(c)
+		}
+		c = next
+	}
+}
+
+func isUnlikely(n *html.Node) bool {
+	if removedElements[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom == atom.Article || n.DataAtom == atom.Main || n.DataAtom == atom.Body {
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyPattern.MatchString(names) && !likelyPattern.MatchString(names)
+}
+
+// mainContent は段落の文字数から本文を含む要素を選ぶ。
+// 段落ごとに親へ得点を、祖父母へその半分を加え、リンクの文字が多い要素は得点を下げる
+func mainContent(body *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre) {
+			text := collapse(textContent(n))
+			if length := utf8.RuneCountInString(text); length >= minParagraphLength {
+				score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "、")) + min(float64(length)/100, 3)
+				if p := n.Parent; p != nil {
+					scores[p] += score
+					if gp := p.Parent; gp != nil {
+						scores[gp] += score / 2
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+			score *= 1.25
+		}
+		score *= 1 - linkDensity(n)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return body
+	}
+	return best
+}
+
+// linkDensity は要素の文字のうちリンクの文字の割合を返す
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(collapse(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			links += utf8.RuneCountInString(collapse(textContent(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(total)
+}
+
+// plainText はブロック要素ごとに段落を分けたプレーンテキストを返す
+func plainText(n *html.Node) string {
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if text := collapse(current.String()); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+		current.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			current.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if textBlocks[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	flush()
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// countWords は単語の数を返す。分かち書きしない文字（漢字・かな）は1文字を1語と数える
+func countWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				count++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func resolveURL(base *url.URL, raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s, " "))
+}
+
+// first は最初の空でない値を返す
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// nonURL はURLでない値を返す（article:author はプロフィールのURLのことがある）
+func nonURL(s string) string {
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package article
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+func TestParseHTML(t *testing.T) {
+	published := func(s string) *time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &tm
+	}
+	tests := []struct {
+		name    string
+		fixture string
+		want    model.Article
+	}{
+		{
+			name:    "OpenGraphの情報とarticle要素の本文",
+			fixture: "opengraph_article",
+			want: model.Article{
+				Title:       "Go 1.24 is released",
+				Byline:      "Jane Gopher",
+				Description: "Generic type aliases, faster maps and a new tool directive.",
+				Image:       "https://example.com/images/go124.png",
+				SiteName:    "Example News",
+				PublishedAt: published("2025-02-11T00:00:00Z"),
+				Text: "Go 1.24 is released\n\n" +
+					"The Go team released Go 1.24 today, six months after Go 1.23, with changes to the toolchain, runtime and libraries.\n\n" +
+					"Generic type aliases are now fully supported, and the builtin map uses a new implementation based on Swiss tables.\n\n" +
+					"The go command can track executable dependencies with tool directives in go.mod.",
+				WordCount: 59,
+			},
+		},
+		{
+			name:    "メタデータのないページはtitle要素・著者の要素・time要素から取り出す",
+			fixture: "plain_blog",
+			want: model.Article{
+				Title:       "Goの並行処理 入門",
+				Byline:      "山田 太郎",
+				Description: "goroutineとchannelの基本",
+				PublishedAt: published("2024-06-01T12:30:00Z"),
+				Text: "By 山田 太郎 2024年6月1日\n\n" +
+					"Goではgoroutineを使って、軽量な並行処理を簡単に書くことができます。\n\n" +
+					"goroutine同士の値のやり取りにはchannelを使い、共有メモリへのアクセスを減らします。\n\n" +
+					"ch := make(chan int) go func() { ch <- 1 }()",
+				WordCount: 80,
+			},
+		},
+		{
+			name:    "本文の段落がない場合はbody全体を使う",
+			fixture: "no_content",
+			want: model.Article{
+				Title:     "Login",
+				Text:      "Short.",
+				WordCount: 1,
+			},
+		},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/posts/1")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.fixture+".html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := ParseHTML(f, pageURL)
+			if err != nil {
+				t.Fatalf("ParseHTML: %v", err)
+			}
+			tt.want.URL = pageURL.String()
+			if (got.PublishedAt == nil) != (tt.want.PublishedAt == nil) ||
+				(got.PublishedAt != nil && !got.PublishedAt.Equal(*tt.want.PublishedAt)) {
+				t.Errorf("PublishedAt = %v, want %v", got.PublishedAt, tt.want.PublishedAt)
+			}
+			got.PublishedAt, tt.want.PublishedAt = nil, nil
+			if *got != tt.want {
+				t.Errorf("ParseHTML()\n got: %+v\nwant: %+v", *got, tt.want)
+			}
+		})
+	}
+}
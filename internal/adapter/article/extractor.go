@@ -0,0 +1,221 @@
+// Package article はリンク投稿のリンク先のページを取得し、記事の本文・著者・OpenGraphの情報を取り出す。
+// robots.txtに従い、ページの大きさと取得時間に上限を設ける。
+// リンク先は投稿者が指定するため、内部のアドレスに接続しないクライアント（httpclient.Factory.PublicClient）を渡す
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpclient"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"golang.org/x/net/html/charset"
+)
+
+// RobotsAgent はrobots.txtの User-agent と照合する名前。User-Agentヘッダーにも含まれる
+const RobotsAgent = "feedle-batch"
+
+const (
+	DefaultTimeout       = 10 * time.Second
+	DefaultMaxBytes      = 2 << 20 // 2MiB
+	DefaultMaxTextLength = 20000
+	maxRedirects         = 5
+	robotsTTL            = time.Hour
+	// robotsErrorTTL はrobots.txtを取得できなかった（取得しない）ホストを再確認するまでの時間
+	robotsErrorTTL = 5 * time.Minute
+)
+
+type robotsEntry struct {
+	rules   *robotsRules
+	expires time.Time
+}
+
+// Extractor はHTTPでページを取得して ParseHTML で記事を取り出す
+type Extractor struct {
+	client        *http.Client
+	robotsClient  *http.Client
+	timeout       time.Duration
+	maxBytes      int64
+	maxTextLength int
+	now           func() time.Time
+
+	mu     sync.Mutex
+	robots map[string]robotsEntry
+}
+
+func NewExtractor(client *http.Client) *Extractor {
+	e := &Extractor{
+		robotsClient:  client,
+		timeout:       DefaultTimeout,
+		maxBytes:      DefaultMaxBytes,
+		maxTextLength: DefaultMaxTextLength,
+		now:           time.Now,
+		robots:        make(map[string]robotsEntry),
+	}
+	// リダイレクト先のホストのrobots.txtも確認する。呼び出し元のクライアントは変更しない
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if !e.allowed(req.Context(), req.URL) {
+			return fmt.Errorf("redirect to %s: %w", req.URL, output.ErrRobotsDisallowed)
+		}
+		return nil
+	}
+	e.client = &c
+	return e
+}
+
+// SetTimeout はrobots.txtの確認を含めた1ページの取得時間の上限を変更する
+func (e *Extractor) SetTimeout(d time.Duration) {
+	if d > 0 {
+		e.timeout = d
+	}
+}
+
+// SetMaxBytes は読み込むページの大きさの上限を変更する。Content-Lengthが上限を超えるページは取得しない
+func (e *Extractor) SetMaxBytes(n int64) {
+	if n > 0 {
+		e.maxBytes = n
+	}
+}
+
+// SetMaxTextLength は保存する本文の文字数の上限を変更する
+func (e *Extractor) SetMaxTextLength(n int) {
+	if n > 0 {
+		e.maxTextLength = n
+	}
+}
+
+var _ output.ArticleExtractor = (*Extractor)(nil)
+
+func (e *Extractor) Extract(ctx context.Context, pageURL string) (*model.Article, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid article URL %q", pageURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	if !e.allowed(ctx, u) {
+		return nil, output.ErrRobotsDisallowed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if errors.Is(err, httpclient.ErrNonPublicAddress) {
+			// 内部のアドレスを指すリンクは再試行しても取得しない
+			return nil, fmt.Errorf("%w: %w", output.ErrNotArticle, err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		// 削除された記事など、再試行しても取得できないページ
+		return nil, fmt.Errorf("%w (status %d)", output.ErrNotArticle, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch %s: status %d", u, resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, fmt.Errorf("%w (content type %q)", output.ErrNotArticle, contentType)
+	}
+	if resp.ContentLength > e.maxBytes {
+		return nil, fmt.Errorf("%w (%d bytes exceeds the limit)", output.ErrNotArticle, resp.ContentLength)
+	}
+
+	// 長さが分からない場合は上限までを読む。OpenGraphの情報は先頭のheadにあるため、途中までのページでも取り出せる
+	body, err := charset.NewReader(io.LimitReader(resp.Body, e.maxBytes), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", u, err)
+	}
+	article, err := ParseHTML(body, resp.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", u, err)
+	}
+
+	article.Text = truncate(article.Text, e.maxTextLength)
+	article.ExtractedAt = e.now().UTC()
+	return article, nil
+}
+
+// allowed はrobots.txtでURLの取得が許可されているかを返す。robots.txtはホストごとに一定時間覚えておく。
+// robots.txtがない（4xx）場合は許可し、サーバーエラーや接続できない場合は取得しない
+func (e *Extractor) allowed(ctx context.Context, u *url.URL) bool {
+	key := u.Scheme + "://" + u.Host
+	now := e.now()
+
+	e.mu.Lock()
+	entry, ok := e.robots[key]
+	e.mu.Unlock()
+	if !ok || now.After(entry.expires) {
+		rules, ttl := e.fetchRobots(ctx, key)
+		entry = robotsEntry{rules: rules, expires: now.Add(ttl)}
+		e.mu.Lock()
+		e.robots[key] = entry
+		e.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return entry.rules.allowed(path)
+}
+
+func (e *Extractor) fetchRobots(ctx context.Context, origin string) (*robotsRules, time.Duration) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return disallowAll, robotsErrorTTL
+	}
+	resp, err := e.robotsClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return disallowAll, 0
+		}
+		return disallowAll, robotsErrorTTL
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseRobots(resp.Body, RobotsAgent), robotsTTL
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return disallowAll, robotsErrorTTL
+	case resp.StatusCode >= 400:
+		return allowAll, robotsTTL
+	default:
+		return disallowAll, robotsErrorTTL
+	}
+}
+
+// truncate は文字数の上限で切る
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:max])) + "…"
+}
@@ -0,0 +1,143 @@
+package article
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// robotsRules はrobots.txtのうち、このバッチに適用されるグループの規則
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// allowAll と disallowAll はrobots.txtを読めなかった場合の扱い
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{disallow: []string{"/"}}
+)
+
+// parseRobots はrobots.txtから agent に適用されるグループを選ぶ。
+// User-agent が agent に含まれるグループのうち最も長く一致するものを使い、なければ "*" のグループを使う
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, 512*1024))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// 連続する User-agent は同じグループ
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				continue // 空の Disallow はすべて許可
+			}
+			if key == "allow" {
+				current.rules.allow = append(current.rules.allow, value)
+			} else {
+				current.rules.disallow = append(current.rules.disallow, value)
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	var matched, wildcard *robotsRules
+	matchedLen := 0
+	for _, g := range groups {
+		for _, a := range g.agents {
+			switch {
+			case a == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				wildcard.allow = append(wildcard.allow, g.rules.allow...)
+				wildcard.disallow = append(wildcard.disallow, g.rules.disallow...)
+			case a != "" && strings.Contains(agent, a) && len(a) > matchedLen:
+				rules := g.rules
+				matched, matchedLen = &rules, len(a)
+			}
+		}
+	}
+	if matched != nil {
+		return matched
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+	return allowAll
+}
+
+// allowed はパス（クエリを含む）の取得が許可されているかを返す。
+// 最も長く一致した規則に従い、同じ長さの場合は Allow を優先する
+func (r *robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, p := range r.disallow {
+		if l := matchLength(p, path); l > best {
+			best, allow = l, false
+		}
+	}
+	for _, p := range r.allow {
+		if l := matchLength(p, path); l >= best && l >= 0 {
+			best, allow = l, true
+		}
+	}
+	return allow
+}
+
+// matchLength はパターンがパスの先頭に一致する場合にパターンの長さを、一致しない場合は-1を返す。
+// "*" は任意の文字列、末尾の "$" はパスの終わりに一致する
+func matchLength(pattern, path string) int {
+	anchored := strings.HasSuffix(pattern, "$")
+	p := strings.TrimSuffix(pattern, "$")
+	if matchPattern(p, path, anchored) {
+		return len(pattern)
+	}
+	return -1
+}
+
+func matchPattern(pattern, path string, anchored bool) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,94 @@
+package article
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		allowed map[string]bool
+	}{
+		{
+			name:    "空のファイルはすべて許可",
+			robots:  "",
+			allowed: map[string]bool{"/": true, "/private": true},
+		},
+		{
+			name:    "* のグループ",
+			robots:  "User-agent: *\nDisallow: /private\n",
+			allowed: map[string]bool{"/": true, "/private": false, "/private/a": false, "/public": true},
+		},
+		{
+			name:    "名前が一致するグループを * より優先する",
+			robots:  "User-agent: *\nDisallow: /\n\nUser-agent: Feedle-Batch\nDisallow: /admin\n",
+			allowed: map[string]bool{"/": true, "/admin": false},
+		},
+		{
+			name:    "連続する User-agent は同じグループ",
+			robots:  "User-agent: otherbot\nUser-agent: feedle-batch\nDisallow: /a\n\nUser-agent: *\nDisallow: /\n",
+			allowed: map[string]bool{"/a": false, "/b": true},
+		},
+		{
+			name:    "最も長く一致した規則に従い、同じ長さなら Allow",
+			robots:  "User-agent: *\nDisallow: /docs\nAllow: /docs/public\nAllow: /x\nDisallow: /x\n",
+			allowed: map[string]bool{"/docs/a": false, "/docs/public/a": true, "/x": true},
+		},
+		{
+			name:    "空の Disallow とコメント",
+			robots:  "# comment\nUser-agent: * # all\nDisallow:\n",
+			allowed: map[string]bool{"/": true},
+		},
+		{
+			name:    "ワイルドカードと $ とクエリ",
+			robots:  "User-agent: *\nDisallow: /*.pdf$\nDisallow: /*?session=\n",
+			allowed: map[string]bool{"/a.pdf": false, "/a.pdf?x=1": true, "/a?session=1": false, "/a?page=1": true},
+		},
+		{
+			name:    "グループの前の規則は無視する",
+			robots:  "Disallow: /\nUser-agent: *\nDisallow: /tmp\n",
+			allowed: map[string]bool{"/": true, "/tmp": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(tt.robots), RobotsAgent)
+			for path, want := range tt.allowed {
+				if got := rules.allowed(path); got != want {
+					t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		anchored bool
+		want     bool
+	}{
+		{"/", "/anything", false, true},
+		{"/fish", "/fish.html", false, true},
+		{"/fish", "/Fish", false, false},
+		{"/fish", "/fish", true, true},
+		{"/fish", "/fish.html", true, false},
+		{"/*.php", "/index.php", false, true},
+		{"/*.php", "/dir/index.php?a=1", false, true},
+		{"/*.php", "/index.php?a=1", true, false},
+		{"/*.php", "/index.php", true, true},
+		{"/a*b*c", "/aXbYc", false, true},
+		{"/a*b*c", "/aXcYb", false, false},
+		{"/*", "/", false, true},
+		{"/*", "/", true, true},
+		{"/a*a", "/a", true, false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path, tt.anchored); got != tt.want {
+			t.Errorf("matchPattern(%q, %q, %v) = %v, want %v", tt.pattern, tt.path, tt.anchored, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// ErrCircuitOpen はデータソースの障害が続いているため取得を行わなかったことを表す
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 5 * time.Minute
+)
+
+type CircuitBreakerOptions struct {
+	// FailureThreshold はOpenに遷移するまでの連続失敗回数
+	FailureThreshold int
+	// Cooldown はOpenになってから試行（Half-Open）を許可するまでの時間
+	Cooldown time.Duration
+}
+
+// CircuitSnapshot は実行結果の出力用のブレーカーの状態。
+// Skipped はプロセス開始からの累計のため、実行ごとの件数は実行開始時の状態との差（Since）で求める
+type CircuitSnapshot struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Skipped             int          `json:"skipped"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker はデータソース単位でFetchをラップし、障害が続く間は残りの設定の取得を止める。
+// 設定固有の恒久的な失敗（存在しないサブレディット等）はデータソースの障害とみなさない。
+// アクセスの拒否（403）はデータソース全体のブロックの可能性があるため障害として数える
+type CircuitBreaker struct {
+	inner DetailFetcher
+	opts  CircuitBreakerOptions
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	skipped  int
+	openedAt time.Time
+	probing  bool
+	lastErr  error
+}
+
+func WithCircuitBreaker(inner DetailFetcher, opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultCooldown
+	}
+
+	return &CircuitBreaker{
+		inner: inner,
+		opts:  opts,
+		state: CircuitClosed,
+	}
+}
+
+func (cb *CircuitBreaker) Name() string {
+	return cb.inner.Name()
+}
+
+func (cb *CircuitBreaker) FetchDetail(ctx context.Context, detail model.FetchConfigDetail) ([]*model.FetchedData, error) {
+	if err := cb.allow(); err != nil {
+		return nil, err
+	}
+
+	data, err := cb.inner.FetchDetail(ctx, detail)
+	cb.record(err)
+	return data, err
+}
+
+// allow は現在の状態で取得を行ってよいかを判定する。Cooldown経過後は1件だけ試行を許可する
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.opts.Cooldown {
+			cb.skipped++
+			return fmt.Errorf("%w for %s since %s: %v", ErrCircuitOpen, cb.inner.Name(), cb.openedAt.Format(time.RFC3339), cb.lastErr)
+		}
+		cb.state = CircuitHalfOpen
+		cb.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.probing {
+			cb.skipped++
+			return fmt.Errorf("%w for %s: probe in progress", ErrCircuitOpen, cb.inner.Name())
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// 中断された試行は判定に使わず、次の呼び出しで改めて試行する
+		if cb.state == CircuitHalfOpen {
+			cb.state = CircuitOpen
+		}
+	case err == nil || model.ClassifyFetchError(err).IsPermanent():
+		// 設定固有の失敗はデータソース自体が応答しているため正常とみなす
+		cb.state = CircuitClosed
+		cb.failures = 0
+	default:
+		cb.failures++
+		cb.lastErr = err
+		if cb.state == CircuitHalfOpen || cb.failures >= cb.opts.FailureThreshold {
+			cb.state = CircuitOpen
+			cb.openedAt = time.Now()
+		}
+	}
+}
+
+func (cb *CircuitBreaker) Snapshot() CircuitSnapshot {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	snapshot := CircuitSnapshot{
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+		Skipped:             cb.skipped,
+	}
+	if cb.state != CircuitClosed {
+		openedAt := cb.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	if cb.lastErr != nil {
+		snapshot.LastError = cb.lastErr.Error()
+	}
+	return snapshot
+}
+
+// Since は before 以降にスキップした件数を Skipped とした状態を返す
+func (s CircuitSnapshot) Since(before CircuitSnapshot) CircuitSnapshot {
+	s.Skipped -= before.Skipped
+	return s
+}
@@ -0,0 +1,90 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// scriptedFetcher は呼び出しのたびに errs を先頭から順に返す
+type scriptedFetcher struct {
+	errs  []error
+	calls int
+}
+
+func (f *scriptedFetcher) Name() string {
+	return "scripted"
+}
+
+func (f *scriptedFetcher) FetchDetail(ctx context.Context, detail model.FetchConfigDetail) ([]*model.FetchedData, error) {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil, nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return nil, err
+}
+
+func TestCircuitBreakerRecord(t *testing.T) {
+	status := func(code int) error { return model.NewFetchErrorFromStatus(code, http.StatusText(code)) }
+	tests := []struct {
+		name string
+		errs []error
+		want CircuitState
+		// failures は最後の呼び出し後の連続失敗回数
+		failures int
+	}{
+		{"一時的な失敗が閾値に達したら開く", []error{status(503), status(503), status(503)}, CircuitOpen, 3},
+		{"閾値に達する前の成功で数え直す", []error{status(503), status(503), nil, status(503)}, CircuitClosed, 1},
+		{"アクセスの拒否は失敗として数える", []error{status(403), status(403), status(403)}, CircuitOpen, 3},
+		{"アクセスの拒否で連続失敗を数え直さない", []error{status(503), status(503), status(403)}, CircuitOpen, 3},
+		{"対象が存在しない失敗はデータソースの障害とみなさない", []error{status(503), status(503), status(404)}, CircuitClosed, 0},
+		{"中断は判定に使わない", []error{status(503), status(503), context.Canceled}, CircuitClosed, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &scriptedFetcher{errs: tt.errs}
+			cb := WithCircuitBreaker(inner, CircuitBreakerOptions{FailureThreshold: 3, Cooldown: time.Hour})
+			for range tt.errs {
+				_, _ = cb.FetchDetail(context.Background(), nil)
+			}
+
+			snapshot := cb.Snapshot()
+			if snapshot.State != tt.want || snapshot.ConsecutiveFailures != tt.failures {
+				t.Errorf("state %s with %d failures, want %s with %d", snapshot.State, snapshot.ConsecutiveFailures, tt.want, tt.failures)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSkipsWhileOpen(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	forbidden := model.NewFetchErrorFromStatus(http.StatusForbidden, "blocked")
+	inner := &scriptedFetcher{errs: []error{forbidden, forbidden}}
+	cb := WithCircuitBreaker(inner, CircuitBreakerOptions{FailureThreshold: 2, Cooldown: cooldown})
+
+	for range 2 {
+		_, _ = cb.FetchDetail(context.Background(), nil)
+	}
+	if _, err := cb.FetchDetail(context.Background(), nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("FetchDetail while open error = %v, want ErrCircuitOpen", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("inner fetcher called %d times, want 2", inner.calls)
+	}
+
+	// Cooldownが過ぎたら1件だけ試行し、成功すれば閉じる
+	time.Sleep(cooldown)
+	if _, err := cb.FetchDetail(context.Background(), nil); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	snapshot := cb.Snapshot()
+	if snapshot.State != CircuitClosed || snapshot.Skipped != 1 {
+		t.Errorf("after probe: state %s, skipped %d, want closed with 1 skipped", snapshot.State, snapshot.Skipped)
+	}
+}
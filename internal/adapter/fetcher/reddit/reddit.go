@@ -4,14 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpcache"
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,6 +25,9 @@ const (
 	maxLimit         = 100
 	defaultSort      = "relevance"
 	defaultTimeframe = "all"
+
+	// DefaultRequestInterval is the pause before each request to stay under Reddit's rate limit
+	DefaultRequestInterval = time.Second
 )
 
 type SearchParams struct {
@@ -33,10 +42,11 @@ type SearchParams struct {
 
 // RedditFetcher handles Reddit API interactions
 type RedditFetcher struct {
-	baseURL   string
-	userAgent string
-	client    *http.Client
-	auth      *RedditAuth
+	baseURL         string
+	userAgent       string
+	client          *http.Client
+	auth            *RedditAuth
+	requestInterval time.Duration
 }
 
 func NewRedditFetcher(userAgent string, auth *RedditAuth) *RedditFetcher {
@@ -54,10 +64,11 @@ func NewRedditFetcher(userAgent string, auth *RedditAuth) *RedditFetcher {
 	}
 
 	return &RedditFetcher{
-		baseURL:   baseURL,
-		userAgent: userAgent,
-		client:    client,
-		auth:      auth,
+		baseURL:         baseURL,
+		userAgent:       userAgent,
+		client:          client,
+		auth:            auth,
+		requestInterval: DefaultRequestInterval,
 	}
 }
 
@@ -78,10 +89,26 @@ func NewRedditFetcherWithClient(userAgent string, auth *RedditAuth, client *http
 	}
 
 	return &RedditFetcher{
-		baseURL:   baseURL,
-		userAgent: userAgent,
-		client:    client,
-		auth:      auth,
+		baseURL:         baseURL,
+		userAgent:       userAgent,
+		client:          client,
+		auth:            auth,
+		requestInterval: DefaultRequestInterval,
+	}
+}
+
+// SetBaseURL points the fetcher at another API host, such as a test server.
+// An empty URL keeps the default.
+func (rf *RedditFetcher) SetBaseURL(baseURL string) {
+	if baseURL != "" {
+		rf.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// SetRequestInterval sets the pause before each request. Zero disables it.
+func (rf *RedditFetcher) SetRequestInterval(d time.Duration) {
+	if d >= 0 {
+		rf.requestInterval = d
 	}
 }
 
@@ -94,10 +121,15 @@ func (rf *RedditFetcher) Fetch(ctx context.Context, config model.RedditFetchConf
 		return nil, fmt.Errorf("no subreddit or keywords provided")
 	}
 
+	// Cache per config so an unchanged response means this config has already stored it
+	if config.UserFetchConfigID != uuid.Nil {
+		ctx = httpcache.WithScope(ctx, config.UserFetchConfigID.String())
+	}
+
 	var allResults []*model.FetchedData
 
 	if len(config.Keywords) == 0 {
-		posts, err := rf.fetchSubredditPosts(config.Subreddit, config.LimitCount)
+		posts, err := rf.fetchSubredditPosts(ctx, config.Subreddit, string(config.SortBy), string(config.TimeFilter), config.LimitCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch subreddit posts: %w", err)
 		}
@@ -111,21 +143,25 @@ func (rf *RedditFetcher) Fetch(ctx context.Context, config model.RedditFetchConf
 	}
 
 	// Search with keywords
+	var lastErr error
+	failed := 0
 	for _, keyword := range config.Keywords {
-		fmt.Println("iter", keyword)
+		slog.DebugContext(ctx, "Searching Reddit", "subreddit", config.Subreddit, "keyword", keyword)
 		params := SearchParams{
 			Query:      keyword,
 			Subreddit:  config.Subreddit,
 			Limit:      config.LimitCount,
-			Sort:       config.SortBy,
-			Time:       config.TimeFilter,
+			Sort:       string(config.SortBy),
+			Time:       string(config.TimeFilter),
 			RestrictSR: config.Subreddit != "", // restrict to subreddit if specified
 		}
 
 		posts, err := rf.searchPosts(ctx, params)
 		if err != nil {
 			// Log error but continue with other keywords
-			fmt.Printf("failed to search for keyword %s: %v\n", keyword, err)
+			slog.WarnContext(ctx, "Failed to search keyword", "subreddit", config.Subreddit, "keyword", keyword, "error", err)
+			lastErr = err
+			failed++
 			continue
 		}
 
@@ -139,6 +175,11 @@ func (rf *RedditFetcher) Fetch(ctx context.Context, config model.RedditFetchConf
 		allResults = append(allResults, posts...)
 	}
 
+	// Every keyword failed: surface the error so the config is not treated as successful
+	if failed == len(config.Keywords) {
+		return nil, fmt.Errorf("failed to search all %d keywords: %w", failed, lastErr)
+	}
+
 	// Remove duplicates based on post ID
 	return rf.deduplicatePosts(allResults), nil
 }
@@ -147,7 +188,7 @@ func (rf *RedditFetcher) searchPosts(ctx context.Context, params SearchParams) (
 	var allPosts []*model.FetchedData
 
 	// Handle pagination
-	for {
+	for page := 1; ; page++ {
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
@@ -156,7 +197,7 @@ func (rf *RedditFetcher) searchPosts(ctx context.Context, params SearchParams) (
 			// Continue processing
 		}
 
-		posts, nextAfter, err := rf.searchPage(params)
+		posts, nextAfter, err := rf.searchPage(ctx, params, page)
 		if err != nil {
 			return allPosts, err
 		}
@@ -180,11 +221,14 @@ func (rf *RedditFetcher) searchPosts(ctx context.Context, params SearchParams) (
 }
 
 // searchPage fetches a single page of search results
-func (rf *RedditFetcher) searchPage(params SearchParams) ([]*model.FetchedData, string, error) {
+func (rf *RedditFetcher) searchPage(ctx context.Context, params SearchParams, page int) ([]*model.FetchedData, string, error) {
 	// Build search URL
 	searchURL := rf.buildSearchURL(params)
 
-	posts, nextAfter, err := rf.fetchFromURL(searchURL)
+	posts, nextAfter, err := rf.fetchFromURL(ctx, searchURL,
+		tracing.AttrSubreddit.String(params.Subreddit),
+		tracing.AttrKeyword.String(params.Query),
+		tracing.AttrPage.Int(page))
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to fetch search results: %w", err)
 	}
@@ -240,15 +284,28 @@ func (rf *RedditFetcher) buildSearchURL(params SearchParams) string {
 	return fmt.Sprintf("%s?%s", endpoint, query.Encode())
 }
 
-// fetchSubredditPosts fetches posts from a specific subreddit
-func (rf *RedditFetcher) fetchSubredditPosts(subreddit string, limit int) ([]*model.FetchedData, error) {
+// fetchSubredditPosts fetches posts from a specific subreddit listing (hot, new, top, ...)
+func (rf *RedditFetcher) fetchSubredditPosts(ctx context.Context, subreddit, sort, timeFilter string, limit int) ([]*model.FetchedData, error) {
 	if limit <= 0 {
 		limit = defaultLimit
 	}
 
-	url := fmt.Sprintf("%s/r/%s.json?limit=%d", rf.baseURL, subreddit, limit)
+	query := url.Values{}
+	query.Set("limit", fmt.Sprintf("%d", limit))
+	// Time filter (for top/controversial sort)
+	if timeFilter != "" && (sort == "top" || sort == "controversial") {
+		query.Set("t", timeFilter)
+	}
+
+	endpoint := fmt.Sprintf("%s/r/%s.json", rf.baseURL, subreddit)
+	if sort != "" {
+		endpoint = fmt.Sprintf("%s/r/%s/%s.json", rf.baseURL, subreddit, sort)
+	}
+	url := fmt.Sprintf("%s?%s", endpoint, query.Encode())
 
-	posts, _, err := rf.fetchFromURL(url)
+	posts, _, err := rf.fetchFromURL(ctx, url,
+		tracing.AttrSubreddit.String(subreddit),
+		tracing.AttrPage.Int(1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch subreddit posts: %w", err)
 	}
@@ -256,9 +313,40 @@ func (rf *RedditFetcher) fetchSubredditPosts(subreddit string, limit int) ([]*mo
 	return posts, nil
 }
 
-// fetchFromURL fetches data from a Reddit URL and returns posts and pagination info
-func (rf *RedditFetcher) fetchFromURL(url string) ([]*model.FetchedData, string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// fetchFromURL fetches data from a Reddit URL and returns posts and pagination info.
+// attrs are added to the request span (subreddit, page, etc.)
+func (rf *RedditFetcher) fetchFromURL(ctx context.Context, url string, attrs ...attribute.KeyValue) ([]*model.FetchedData, string, error) {
+	// Wait before starting the span so the pause is not reported as request latency
+	if err := rf.waitForRateLimit(ctx); err != nil {
+		return nil, "", err
+	}
+
+	ctx, span := tracing.Start(ctx, "reddit.request", append(attrs, tracing.AttrSource.String(rf.Name()))...)
+	defer span.End()
+
+	posts, after, err := rf.doFetch(ctx, url)
+	if err != nil {
+		return nil, "", tracing.Fail(span, err)
+	}
+	span.SetAttributes(tracing.AttrItems.Int(len(posts)))
+	return posts, after, nil
+}
+
+// waitForRateLimit pauses before a request to respect Reddit's rate limit
+func (rf *RedditFetcher) waitForRateLimit(ctx context.Context) error {
+	if rf.requestInterval <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(rf.requestInterval):
+		return nil
+	}
+}
+
+func (rf *RedditFetcher) doFetch(ctx context.Context, url string) ([]*model.FetchedData, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -274,16 +362,25 @@ func (rf *RedditFetcher) fetchFromURL(url string) ([]*model.FetchedData, string,
 		}
 	}
 
-	// Respect rate limiting
-	time.Sleep(time.Second) // Basic rate limiting - adjust as needed
-
+	start := time.Now()
 	resp, err := rf.client.Do(req)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to fetch from Reddit: %w", err)
 	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+		semconv.HTTPResponseStatusCode(resp.StatusCode),
+	)
+	slog.DebugContext(ctx, "Reddit request completed",
+		"url", req.URL.String(),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+		"ratelimit_remaining", resp.Header.Get("X-Ratelimit-Remaining"))
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("failed to close response body: %v\n", cerr)
+			slog.WarnContext(ctx, "Failed to close response body", "error", cerr)
 		}
 	}()
 
@@ -292,6 +389,14 @@ func (rf *RedditFetcher) fetchFromURL(url string) ([]*model.FetchedData, string,
 		return nil, "", err
 	}
 
+	// Same response as the last one this config saved (cache entries are committed only
+	// after the items are stored): skip decoding and stop paginating
+	if httpcache.NotModified(resp) {
+		span.SetAttributes(attribute.String("http.cache", resp.Header.Get(httpcache.HeaderStatus)))
+		slog.DebugContext(ctx, "Reddit response not modified, skipping", "url", req.URL.String())
+		return nil, "", nil
+	}
+
 	// Decode response
 	var redditResp RedditResponse
 	if err := json.NewDecoder(resp.Body).Decode(&redditResp); err != nil {
@@ -312,7 +417,7 @@ func (rf *RedditFetcher) fetchFromURL(url string) ([]*model.FetchedData, string,
 
 // addAuthHeaders adds authentication headers to the request
 func (rf *RedditFetcher) addAuthHeaders(req *http.Request) error {
-	token, err := rf.auth.GetAccessToken()
+	token, err := rf.auth.GetAccessToken(req.Context())
 	if err != nil {
 		return fmt.Errorf("failed to get access token: %w", err)
 	}
@@ -338,25 +443,32 @@ func (rf *RedditFetcher) handleHTTPError(resp *http.Response) error {
 		return nil
 	}
 
-	// Read error response
-	var errorResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil {
-		if message, ok := errorResp["message"].(string); ok {
-			return fmt.Errorf("reddit API error (status %d): %s", resp.StatusCode, message)
-		}
-	}
-
 	// Handle specific status codes
+	var message string
 	switch resp.StatusCode {
 	case http.StatusTooManyRequests:
-		return fmt.Errorf("rate limit exceeded, retry after %s", resp.Header.Get("X-Ratelimit-Reset"))
+		message = fmt.Sprintf("rate limit exceeded, retry after %s", resp.Header.Get("X-Ratelimit-Reset"))
 	case http.StatusUnauthorized:
-		return fmt.Errorf("authentication failed")
+		message = "authentication failed"
 	case http.StatusForbidden:
-		return fmt.Errorf("access forbidden - check permissions")
+		message = "access forbidden - check permissions"
+	case http.StatusNotFound:
+		message = "not found - check that the subreddit exists"
 	default:
-		return fmt.Errorf("reddit API returned status %d", resp.StatusCode)
+		message = "reddit API returned an error"
+	}
+
+	// Read error response
+	var errorResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil {
+		if reason, ok := errorResp["reason"].(string); ok {
+			message = fmt.Sprintf("%s (reason: %s)", message, reason)
+		} else if apiMessage, ok := errorResp["message"].(string); ok {
+			message = fmt.Sprintf("%s: %s", message, apiMessage)
+		}
 	}
+
+	return model.NewFetchErrorFromStatus(resp.StatusCode, message)
 }
 
 // deduplicatePosts removes duplicate posts based on ID
@@ -398,11 +510,22 @@ func (rf *RedditFetcher) transformPost(post RedditPost) *model.FetchedData {
 		"over_18":      post.Data.Over18,
 	}
 
+	permalink := fmt.Sprintf("https://reddit.com%s", post.Data.Permalink)
+
 	// Extract media URLs if present
 	var mediaURLs []string
+	linkURL := permalink
 	if post.Data.URL != "" && !strings.HasPrefix(post.Data.URL, "https://www.reddit.com") {
 		// External URL might be media
 		mediaURLs = append(mediaURLs, post.Data.URL)
+		linkURL = post.Data.URL
+	}
+
+	// Link posts are grouped by the article they point to, self posts by their permalink.
+	// Short URLs are expanded later when the item is linked to a story.
+	canonicalURL, err := model.CanonicalizeURL(linkURL)
+	if err != nil {
+		canonicalURL = ""
 	}
 
 	// Generate tags
@@ -421,12 +544,13 @@ func (rf *RedditFetcher) transformPost(post RedditPost) *model.FetchedData {
 		Source:       "reddit",
 		Title:        post.Data.Title,
 		Content:      post.Data.Selftext,
-		URL:          fmt.Sprintf("https://reddit.com%s", post.Data.Permalink),
+		URL:          permalink,
 		AuthorName:   post.Data.Author,
 		SourceItemID: post.Data.ID,
 		PublishedAt:  &publishedAt,
 		Tags:         tags,
 		MediaURLs:    mediaURLs,
+		CanonicalURL: canonicalURL,
 		Metadata:     metadata,
 		FetchedAt:    now,
 		CreatedAt:    now,
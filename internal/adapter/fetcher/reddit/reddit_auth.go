@@ -1,20 +1,33 @@
 package reddit
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultTokenURL はclient credentialsでアクセストークンを発行するエンドポイント
+const DefaultTokenURL = "https://www.reddit.com/api/v1/access_token"
+
 type RedditAuth struct {
 	clientID     string
 	clientSecret string
 	userAgent    string
+	tokenURL     string
+	client       *http.Client
 	accessToken  string
 	expiresAt    time.Time
 }
@@ -36,20 +49,58 @@ func NewRedditAuth(clientID, clientSecret, username string) *RedditAuth {
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		userAgent:    userAgent,
+		tokenURL:     DefaultTokenURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SetHTTPClient はトークンの取得に使うクライアントを変更する（プロキシやCA証明書の設定など）
+func (ra *RedditAuth) SetHTTPClient(client *http.Client) {
+	if client != nil {
+		ra.client = client
+	}
+}
+
+// SetUserAgent はトークンの取得に使うUser-Agentを変更する。空の場合は既定値のまま
+func (ra *RedditAuth) SetUserAgent(userAgent string) {
+	if userAgent != "" {
+		ra.userAgent = userAgent
 	}
 }
 
-func (ra *RedditAuth) GetAccessToken() (string, error) {
+// SetTokenURL はトークンの発行先を変更する（テスト用のサーバーなど）。空の場合は既定値のまま
+func (ra *RedditAuth) SetTokenURL(tokenURL string) {
+	if tokenURL != "" {
+		ra.tokenURL = tokenURL
+	}
+}
+
+func (ra *RedditAuth) GetAccessToken(ctx context.Context) (string, error) {
+	ctx, span := tracing.Start(ctx, "reddit.access_token", tracing.AttrSource.String("reddit"))
+	defer span.End()
+
 	// トークンがまだ有効な場合は再利用
 	if ra.accessToken != "" && time.Now().Before(ra.expiresAt) {
+		span.SetAttributes(attribute.Bool("reddit.token_cached", true))
 		return ra.accessToken, nil
 	}
 
+	token, err := ra.refreshToken(ctx)
+	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("reddit", "error").Inc()
+		return "", tracing.Fail(span, err)
+	}
+	metrics.TokenRefreshes.WithLabelValues("reddit", "success").Inc()
+	return token, nil
+}
+
+// refreshToken はclient credentialsで新しいアクセストークンを取得する
+func (ra *RedditAuth) refreshToken(ctx context.Context) (string, error) {
 	// 新しいトークンを取得
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequest("POST", "https://www.reddit.com/api/v1/access_token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", ra.tokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create token request: %w", err)
 	}
@@ -60,14 +111,14 @@ func (ra *RedditAuth) GetAccessToken() (string, error) {
 	req.Header.Set("User-Agent", ra.userAgent)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := ra.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("failed to close response body: %v\n", err)
+			slog.WarnContext(ctx, "Failed to close response body", "error", err)
 		}
 	}()
 
@@ -0,0 +1,79 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// DetailFetcher はデータソース固有の型を意識せずに取得を行うためのFetcher
+type DetailFetcher interface {
+	Name() string
+	FetchDetail(ctx context.Context, detail model.FetchConfigDetail) ([]*model.FetchedData, error)
+}
+
+type typedFetcher[T model.FetchConfigDetail] struct {
+	Fetcher[T]
+}
+
+// Adapt は型付きのFetcherをDetailFetcherに変換する
+func Adapt[T model.FetchConfigDetail](f Fetcher[T]) DetailFetcher {
+	return typedFetcher[T]{Fetcher: f}
+}
+
+func (f typedFetcher[T]) FetchDetail(ctx context.Context, detail model.FetchConfigDetail) ([]*model.FetchedData, error) {
+	switch d := any(detail).(type) {
+	case T:
+		return f.Fetch(ctx, d)
+	case *T:
+		return f.Fetch(ctx, *d)
+	default:
+		return nil, fmt.Errorf("fetcher %s does not support config detail %T", f.Name(), detail)
+	}
+}
+
+// Registry はデータソースIDとFetcherの対応を保持する
+type Registry struct {
+	fetchers map[string]DetailFetcher
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		fetchers: make(map[string]DetailFetcher),
+	}
+}
+
+func (r *Registry) Register(dataSourceID string, f DetailFetcher) {
+	r.fetchers[dataSourceID] = f
+}
+
+func (r *Registry) Get(dataSourceID string) (DetailFetcher, error) {
+	f, ok := r.fetchers[dataSourceID]
+	if !ok {
+		return nil, fmt.Errorf("unsupported data source: %s", dataSourceID)
+	}
+	return f, nil
+}
+
+// Sources は登録済みのデータソースIDを昇順で返す
+func (r *Registry) Sources() []string {
+	sources := make([]string, 0, len(r.fetchers))
+	for id := range r.fetchers {
+		sources = append(sources, id)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// CircuitSnapshots はブレーカーでラップされたデータソースの状態を返す。Skipped はプロセス開始からの累計
+func (r *Registry) CircuitSnapshots() map[string]CircuitSnapshot {
+	snapshots := make(map[string]CircuitSnapshot)
+	for id, f := range r.fetchers {
+		if cb, ok := f.(*CircuitBreaker); ok {
+			snapshots[id] = cb.Snapshot()
+		}
+	}
+	return snapshots
+}
@@ -0,0 +1,190 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 既定の上限。古いレスポンスは再検証にしか使えず、設定の削除などで参照されなくなったものも残るため削除する
+const (
+	DefaultMaxAge   = 7 * 24 * time.Hour
+	DefaultMaxBytes = 100 << 20
+
+	// pruneEvery は何回保存するごとに上限を確認するか
+	pruneEvery = 100
+)
+
+// Entry はキャッシュしたレスポンス
+type Entry struct {
+	URL          string      `json:"url"`
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// hasValidators は条件付きリクエストに使える値があるかを返す
+func (e *Entry) hasValidators() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// Store はレスポンスをディレクトリ以下に1件ずつJSONファイルとして保存する。
+// 保存した時刻が MaxAge より古いファイルと、合計が MaxBytes を超えた分の古いファイルは Prune で削除する
+type Store struct {
+	dir      string
+	maxAge   time.Duration
+	maxBytes int64
+
+	mu   sync.Mutex
+	puts int
+}
+
+func NewStore(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory must be set")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Store{dir: dir, maxAge: DefaultMaxAge, maxBytes: DefaultMaxBytes}, nil
+}
+
+// SetMaxAge は保存してからキャッシュを残す期間を変更する。0以下の場合は期間で削除しない
+func (s *Store) SetMaxAge(d time.Duration) {
+	s.maxAge = d
+}
+
+// SetMaxBytes はキャッシュの合計サイズの上限を変更する。0以下の場合はサイズで削除しない
+func (s *Store) SetMaxBytes(n int64) {
+	s.maxBytes = n
+}
+
+// DefaultDir はユーザーのキャッシュディレクトリ以下の既定の保存先を返す
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "feedle", "http"), nil
+}
+
+// Get はキャッシュを読み込む。ない場合はnilを返す
+func (s *Store) Get(key string) (*Entry, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		// 壊れたファイルはないものとして扱い、次の保存で上書きする
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// Put はキャッシュを保存する。途中で中断しても壊れたファイルが残らないよう、一時ファイルから置き換える
+func (s *Store) Put(key string, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	s.mu.Lock()
+	s.puts++
+	prune := s.puts%pruneEvery == 0
+	s.mu.Unlock()
+	if prune {
+		if _, err := s.Prune(time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune は上限を超えたキャッシュを削除し、削除した件数を返す。
+// 起動時と一定回数の保存ごとに呼ばれ、ファイルの更新時刻が古いものから削除する
+func (s *Store) Prune(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	var total int64
+	for _, de := range dirEntries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			// 並行して削除された場合など
+			continue
+		}
+		files = append(files, file{path: filepath.Join(s.dir, de.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	removed := 0
+	var errs []error
+	for _, f := range files {
+		expired := s.maxAge > 0 && now.Sub(f.modTime) > s.maxAge
+		oversize := s.maxBytes > 0 && total > s.maxBytes
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		total -= f.size
+		removed++
+	}
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("failed to prune cache: %w", errors.Join(errs...))
+	}
+	return removed, nil
+}
+
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
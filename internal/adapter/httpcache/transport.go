@@ -0,0 +1,249 @@
+// Package httpcache はフェッチャーで共有するディスクキャッシュ付きのhttp.RoundTripperを提供する。
+// ETag/Last-Modifiedを保存して条件付きリクエストを送り、304の場合は保存したレスポンスを返す
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
+)
+
+// HeaderStatus はキャッシュの利用結果を示すレスポンスヘッダー
+const HeaderStatus = "X-Feedle-Cache"
+
+// キャッシュの利用結果
+const (
+	StatusMiss        = "miss"        // 取得して保存した（または保存しなかった）
+	StatusHit         = "hit"         // 有効期限内のため送信しなかった
+	StatusRevalidated = "revalidated" // 条件付きリクエストに304が返った
+)
+
+type (
+	scopeKey   struct{}
+	pendingKey struct{}
+	refreshKey struct{}
+)
+
+// WithScope はキャッシュの利用者（設定IDなど）をコンテキストに設定する。
+// 利用者ごとにキャッシュを分けるため、NotModified が真なら同じ利用者が前回と同じ内容を取得済みとわかる
+func WithScope(ctx context.Context, scope string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+func scopeFrom(ctx context.Context) string {
+	scope, _ := ctx.Value(scopeKey{}).(string)
+	return scope
+}
+
+// Pending は保存を保留したキャッシュ。取得したデータを保存できた後に Commit で書き込む
+type Pending struct {
+	mu      sync.Mutex
+	entries []pendingEntry
+}
+
+type pendingEntry struct {
+	transport *Transport
+	key       string
+	entry     *Entry
+}
+
+// Defer は以降のレスポンスのキャッシュへの書き込みを Commit まで保留する。
+// キャッシュは「前回と同じ内容を取得済み」の判定に使うため、取得したデータの保存に失敗した場合は
+// Commit せずに破棄し、次の実行で同じレスポンスを読み飛ばさないようにする
+func Defer(ctx context.Context) (context.Context, *Pending) {
+	p := &Pending{}
+	return context.WithValue(ctx, pendingKey{}, p), p
+}
+
+// Commit は保留したキャッシュを書き込む。書き込めなくても取得自体は成功しているため、警告のみにする
+func (p *Pending) Commit(ctx context.Context) {
+	p.mu.Lock()
+	entries := p.entries
+	p.entries = nil
+	p.mu.Unlock()
+
+	for _, pe := range entries {
+		pe.transport.write(ctx, pe.key, pe.entry)
+	}
+}
+
+func (p *Pending) add(t *Transport, key string, e *Entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, pendingEntry{transport: t, key: key, entry: e})
+}
+
+// Refresh は以降のリクエストでキャッシュを読まずに送信するようにする。レスポンスは通常どおりキャッシュに保存する。
+// 指定した設定の取り直しや中断した実行の再開など、前回の取得結果に関係なく取得したい場合に使う
+func Refresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+func refreshing(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// NotModified は利用者を指定したリクエストで、前回から内容が変わっていない場合に真を返す。
+// フェッチャーはこの場合に変換と保存を省略できる
+func NotModified(resp *http.Response) bool {
+	if resp == nil || resp.Request == nil || scopeFrom(resp.Request.Context()) == "" {
+		return false
+	}
+	switch resp.Header.Get(HeaderStatus) {
+	case StatusHit, StatusRevalidated:
+		return true
+	default:
+		return false
+	}
+}
+
+// Transport はGETリクエストの200レスポンスをキャッシュするhttp.RoundTripper
+type Transport struct {
+	source string
+	store  *Store
+	ttl    time.Duration
+	next   http.RoundTripper
+	now    func() time.Time
+}
+
+// NewTransport はデータソースごとのTransportを作る。ttlの間は送信せずにキャッシュを返し、
+// 過ぎた後は条件付きリクエストで再検証する
+func NewTransport(source string, store *Store, ttl time.Duration, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{source: source, store: store, ttl: ttl, next: next, now: time.Now}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	key := scopeFrom(ctx) + "\n" + req.URL.String()
+	var entry *Entry
+	if !refreshing(ctx) {
+		var err error
+		if entry, err = t.store.Get(key); err != nil {
+			slog.WarnContext(ctx, "Failed to read HTTP cache", "source", t.source, "error", err)
+		}
+	}
+
+	now := t.now()
+	if entry != nil && t.ttl > 0 && now.Sub(entry.StoredAt) < t.ttl {
+		t.observe(StatusHit)
+		return entry.response(req, StatusHit), nil
+	}
+
+	outReq := req
+	if entry != nil && entry.hasValidators() {
+		outReq = req.Clone(ctx)
+		if entry.ETag != "" {
+			outReq.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			outReq.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		entry.StoredAt = now
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		t.put(ctx, key, entry)
+		t.observe(StatusRevalidated)
+		return entry.response(req, StatusRevalidated), nil
+	}
+
+	t.observe(StatusMiss)
+	if !t.cacheable(resp) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.put(ctx, key, &Entry{
+		URL:          req.URL.String(),
+		Status:       resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     now,
+	})
+	resp.Header.Set(HeaderStatus, StatusMiss)
+	return resp, nil
+}
+
+// cacheable は保存する意味のあるレスポンスかを返す。
+// 検証用の値がなく有効期限もない場合は再利用できないため保存しない
+func (t *Transport) cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	return t.ttl > 0 || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// put はキャッシュを保存する。Defer されている場合は Commit まで保留する
+func (t *Transport) put(ctx context.Context, key string, e *Entry) {
+	if p, ok := ctx.Value(pendingKey{}).(*Pending); ok {
+		p.add(t, key, e)
+		return
+	}
+	t.write(ctx, key, e)
+}
+
+// write はキャッシュを書き込む。書き込めなくても取得自体は成功させる
+func (t *Transport) write(ctx context.Context, key string, e *Entry) {
+	if err := t.store.Put(key, e); err != nil {
+		slog.WarnContext(ctx, "Failed to write HTTP cache", "source", t.source, "error", err)
+	}
+}
+
+func (t *Transport) observe(result string) {
+	metrics.HTTPCacheResults.WithLabelValues(t.source, result).Inc()
+}
+
+func (e *Entry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(HeaderStatus, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
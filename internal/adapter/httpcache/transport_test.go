@@ -0,0 +1,143 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestTransport はETagで再検証できるサーバーと、それに接続するTransportを作る
+func newTestTransport(t *testing.T) (*Transport, string, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTransport("test", store, 0, server.Client().Transport), server.URL, &requests
+}
+
+func get(t *testing.T, ctx context.Context, tr *Transport, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestDeferWritesOnlyOnCommit(t *testing.T) {
+	tr, url, _ := newTestTransport(t)
+	ctx := WithScope(context.Background(), "config")
+
+	// Commit しなかった取得（保存に失敗した場合）は次の取得で変更なしにならない
+	deferred, _ := Defer(ctx)
+	get(t, deferred, tr, url)
+	if resp := get(t, ctx, tr, url); NotModified(resp) {
+		t.Fatal("response was cached without Commit")
+	}
+
+	deferred, pending := Defer(ctx)
+	get(t, deferred, tr, url)
+	pending.Commit(ctx)
+	if resp := get(t, ctx, tr, url); !NotModified(resp) {
+		t.Errorf("cache status = %q after Commit, want revalidated", resp.Header.Get(HeaderStatus))
+	}
+}
+
+func TestRefreshSkipsCachedEntries(t *testing.T) {
+	tr, url, requests := newTestTransport(t)
+	ctx := WithScope(context.Background(), "config")
+	get(t, ctx, tr, url)
+
+	resp := get(t, Refresh(ctx), tr, url)
+	if NotModified(resp) || resp.StatusCode != http.StatusOK {
+		t.Errorf("refresh returned %d %q, want a fresh 200", resp.StatusCode, resp.Header.Get(HeaderStatus))
+	}
+	if *requests != 2 {
+		t.Errorf("requests = %d, want 2", *requests)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		// maxFiles は合計サイズの上限を1件分のサイズの何倍にするか
+		maxFiles float64
+		want     []string
+	}{
+		{"制限なし", 0, 0, []string{"old", "mid", "new"}},
+		{"期間を過ぎたものを削除", 36 * time.Hour, 0, []string{"mid", "new"}},
+		{"サイズを超えた分を古い順に削除", 0, 2.5, []string{"mid", "new"}},
+		{"両方", 36 * time.Hour, 1.5, []string{"new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetMaxAge(tt.maxAge)
+
+			ages := map[string]time.Duration{"old": 48 * time.Hour, "mid": 24 * time.Hour, "new": time.Hour}
+			for key, age := range ages {
+				if err := store.Put(key, &Entry{Body: make([]byte, 50)}); err != nil {
+					t.Fatal(err)
+				}
+				modTime := now.Add(-age)
+				if err := os.Chtimes(store.path(key), modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := os.Stat(store.path("old"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetMaxBytes(int64(tt.maxFiles * float64(info.Size())))
+
+			if _, err := store.Prune(now); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			var kept []string
+			for _, key := range []string{"old", "mid", "new"} {
+				if _, err := os.Stat(store.path(key)); err == nil {
+					kept = append(kept, key)
+				}
+			}
+			if len(kept) != len(tt.want) {
+				t.Fatalf("kept %v, want %v", kept, tt.want)
+			}
+			for i := range kept {
+				if kept[i] != tt.want[i] {
+					t.Fatalf("kept %v, want %v", kept, tt.want)
+				}
+			}
+			if matches, _ := filepath.Glob(filepath.Join(store.dir, ".tmp-*")); len(matches) > 0 {
+				t.Errorf("temporary files left: %v", matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Package httpclient はフェッチャーと認証で共有するHTTPクライアントを作る。
+// プロキシ、追加のCA証明書、タイムアウト、接続数の上限、データソースごとのUser-Agentを一か所で設定する
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Version はUser-Agentに含めるバージョン。ビルド時に -ldflags で上書きできる
+var Version = "v1.0.0"
+
+// DefaultUserAgent はデータソースごとの指定がない場合のUser-Agentのテンプレート
+const DefaultUserAgent = "golang:feedle-batch:{{.Version}}{{if .Username}} (by /u/{{.Username}}){{end}}"
+
+// Options はクライアントの設定。ゼロ値の項目は既定値を使う
+type Options struct {
+	// ProxyURL はすべての通信に使うプロキシ。空の場合は HTTPS_PROXY/HTTP_PROXY/NO_PROXY に従う
+	ProxyURL string
+	// CABundle はシステムの証明書に追加で信頼するCA証明書（PEM）のファイル
+	CABundle string
+
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// UserAgent はUser-Agentのテンプレート。{{.Source}}、{{.Version}}、{{.Username}} を使える
+	UserAgent string
+	// SourceUserAgents はデータソースごとのテンプレート
+	SourceUserAgents map[string]string
+	// Usernames はデータソースごとのアカウント名。User-Agentの {{.Username}} に入る
+	Usernames map[string]string
+}
+
+// userAgentData はUser-Agentのテンプレートに渡す値
+type userAgentData struct {
+	Source   string
+	Version  string
+	Username string
+}
+
+// Factory はデータソースごとのクライアントを作る。コネクションプールは全クライアントで共有する
+type Factory struct {
+	opts      Options
+	transport *http.Transport
+	// publicTransport は公開されたアドレスにだけ接続する。コネクションプールは transport と分ける
+	publicTransport *http.Transport
+}
+
+func NewFactory(opts Options) (*Factory, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.TLSHandshakeTimeout == 0 {
+		opts.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if opts.IdleConnTimeout == 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = 100
+	}
+	if opts.MaxIdleConnsPerHost == 0 {
+		opts.MaxIdleConnsPerHost = 10
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.ProxyURL != "" {
+		u, err := url.Parse(opts.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid HTTP_PROXY_URL %q", opts.ProxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q in HTTP_PROXY_URL (expected http, https or socks5)", u.Scheme)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CABundle != "" {
+		pool, err := loadCABundle(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// テンプレートの誤りは起動時に検出する
+	for source, tmpl := range opts.SourceUserAgents {
+		if _, err := renderUserAgent(tmpl, userAgentData{Source: source}); err != nil {
+			return nil, fmt.Errorf("invalid User-Agent template for %s: %w", source, err)
+		}
+	}
+	if _, err := renderUserAgent(opts.UserAgent, userAgentData{}); err != nil {
+		return nil, fmt.Errorf("invalid User-Agent template: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	publicTransport := transport.Clone()
+	publicTransport.DialContext = (&publicDialer{
+		dialer:   dialer,
+		resolver: net.DefaultResolver,
+		proxies:  proxyAddrs(opts.ProxyURL),
+	}).DialContext
+
+	return &Factory{opts: opts, transport: transport, publicTransport: publicTransport}, nil
+}
+
+// Transport は共有のトランスポートを返す
+func (f *Factory) Transport() http.RoundTripper {
+	return f.transport
+}
+
+// UserAgent はデータソースのUser-Agentを返す
+func (f *Factory) UserAgent(source string) string {
+	tmpl, ok := f.opts.SourceUserAgents[source]
+	if !ok {
+		tmpl = f.opts.UserAgent
+	}
+	ua, err := renderUserAgent(tmpl, userAgentData{
+		Source:   source,
+		Version:  Version,
+		Username: f.opts.Usernames[source],
+	})
+	if err != nil {
+		// NewFactoryで検証済みのため通常は起こらない
+		return tmpl
+	}
+	return ua
+}
+
+// Client はデータソース用のクライアントを作る。User-Agentが未設定のリクエストにはデータソースのものを付ける。
+// middlewaresは内側から順に適用する（計測、キャッシュなど）
+func (f *Factory) Client(source string, middlewares ...func(http.RoundTripper) http.RoundTripper) *http.Client {
+	return f.client(source, f.transport, middlewares)
+}
+
+// PublicClient は Client と同じだが、ループバック・プライベート・リンクローカル（クラウドのメタデータを含む）の
+// アドレスには接続せず ErrNonPublicAddress を返す。投稿のリンク先など、外部から指定された宛先に接続する場合に使う。
+// プロキシを使う場合、プロキシへの接続は確認しないため、宛先の制限はプロキシで行う
+func (f *Factory) PublicClient(source string, middlewares ...func(http.RoundTripper) http.RoundTripper) *http.Client {
+	return f.client(source, f.publicTransport, middlewares)
+}
+
+func (f *Factory) client(source string, transport http.RoundTripper, middlewares []func(http.RoundTripper) http.RoundTripper) *http.Client {
+	var rt http.RoundTripper = &userAgentTransport{userAgent: f.UserAgent(source), next: transport}
+	for _, m := range middlewares {
+		rt = m(rt)
+	}
+	return &http.Client{Timeout: f.opts.Timeout, Transport: rt}
+}
+
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.next.RoundTrip(req)
+	}
+	// RoundTripperはリクエストを変更してはならないため複製する
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
+
+func renderUserAgent(tmpl string, data userAgentData) (string, error) {
+	t, err := template.New("user-agent").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// loadCABundle はシステムの証明書にファイルの証明書を追加したプールを返す
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP_CA_BUNDLE: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("HTTP_CA_BUNDLE contains no PEM certificates")
+	}
+	return pool, nil
+}
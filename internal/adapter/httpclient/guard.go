@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ErrNonPublicAddress は PublicClient が公開されていないアドレスへの接続を拒否した場合に返す
+var ErrNonPublicAddress = errors.New("connection to a non-public address is not allowed")
+
+// nonPublicPrefixes は IsGlobalUnicast と IsPrivate で判定できない、外部から指定された宛先として接続しない範囲
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // このネットワーク
+	netip.MustParsePrefix("100.64.0.0/10"), // キャリアグレードNAT（一部のクラウドのメタデータを含む）
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETFプロトコル割り当て
+	netip.MustParsePrefix("198.18.0.0/15"), // ベンチマーク
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// isPublicAddress はアドレスがインターネット上の宛先かを返す。
+// ループバック、プライベート、リンクローカル（169.254.169.254 などのメタデータ）、マルチキャスト、未指定のアドレスはfalse
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialer は名前解決したすべてのアドレスを確認してから、確認したアドレスに接続する。
+// 接続のたびに確認するため、リダイレクト先や、確認の後に名前解決の結果を変えるDNSリバインディングにも適用される
+type publicDialer struct {
+	dialer   *net.Dialer
+	resolver *net.Resolver
+	// proxies はプロキシの "host:port"。プロキシへの接続は確認しない
+	proxies map[string]bool
+}
+
+func (d *publicDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxies[addr] {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !isPublicAddress(ip) {
+			return nil, fmt.Errorf("%s resolves to %s: %w", host, ip, ErrNonPublicAddress)
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// proxyAddrs は設定と環境変数のプロキシの "host:port" を返す
+func proxyAddrs(proxyURL string) map[string]bool {
+	addrs := make(map[string]bool)
+	env := httpproxy.FromEnvironment()
+	for _, raw := range []string{proxyURL, env.HTTPProxy, env.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+			if port == "" {
+				port = "80"
+			}
+		}
+		addrs[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+	return addrs
+}
@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicClientRejectsNonPublicAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 名前で指定した場合も、名前解決の結果で判定する
+	for _, target := range []string{server.URL, "http://localhost:" + u.Port()} {
+		resp, err := factory.PublicClient("test").Get(target)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("GET %s succeeded", target)
+			continue
+		}
+		if !errors.Is(err, ErrNonPublicAddress) {
+			t.Errorf("GET %s: %v, want ErrNonPublicAddress", target, err)
+		}
+	}
+
+	resp, err := factory.Client("test").Get(server.URL)
+	if err != nil {
+		t.Fatalf("Client should connect to the local server: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,440 @@
+package normalizer
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s*(.+?)\s*#*\s*$`)
+	rulePattern      = regexp.MustCompile(`^ {0,3}([-*_])( *([-*_])){2,} *$`)
+	listItemPattern  = regexp.MustCompile(`^ {0,3}([*+-]|\d{1,9}[.)])\s+(.*)$`)
+	tableSepPattern  = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	fencePattern     = regexp.MustCompile("^ {0,3}(```|~~~)")
+	orderedListStart = regexp.MustCompile(`^ {0,3}\d`)
+	entityPattern    = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// 強調の区切り文字と対応するタグ（"~" は2つ続く場合だけ打ち消し線にする）
+var (
+	doubleDelimTags = map[byte]string{'*': "strong", '_': "strong", '~': "del"}
+	singleDelimTags = map[byte]string{'*': "em", '_': "em"}
+)
+
+// renderMarkdown はRedditで使われるMarkdownの記法（見出し、引用、リスト、コード、表、強調、リンク）をHTMLにする。
+// 生のHTMLは文字として扱い、未対応の記法はそのまま文字で残す。結果はsanitizeに通す前提
+func renderMarkdown(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>")
+			b.WriteString(renderParagraph(para))
+			b.WriteString("</p>\n")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+			i++
+
+		case fencePattern.MatchString(line):
+			flush()
+			fence := fencePattern.FindStringSubmatch(line)[1]
+			var code []string
+			i++
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // 閉じるフェンス
+			writeCode(b, code)
+
+		case len(para) == 0 && isIndentedCode(line):
+			var code []string
+			for i < len(lines) && (isIndentedCode(lines[i]) || strings.TrimSpace(lines[i]) == "") {
+				code = append(code, strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "    "))
+				i++
+			}
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			writeCode(b, code)
+
+		case headingPattern.MatchString(trimmed):
+			flush()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case rulePattern.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">") && !strings.HasPrefix(trimmed, ">!"):
+			flush()
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case listItemPattern.MatchString(line):
+			flush()
+			i = renderList(b, lines, i)
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableSepPattern.MatchString(strings.TrimSpace(lines[i+1])):
+			flush()
+			i = renderTable(b, lines, i)
+
+		default:
+			para = append(para, line)
+			i++
+		}
+	}
+	flush()
+}
+
+func isIndentedCode(line string) bool {
+	return (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) && strings.TrimSpace(line) != ""
+}
+
+func writeCode(b *strings.Builder, code []string) {
+	b.WriteString("<pre><code>")
+	b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+	b.WriteString("</code></pre>\n")
+}
+
+// renderList は連続するリスト項目を出力し、次の行の位置を返す。入れ子のリストは平坦にする。
+// 記号の種類（"-" と "*"、"1." と "1)" なども区別する）が変わった場合は別のリストとして終える
+func renderList(b *strings.Builder, lines []string, i int) int {
+	tag := "ul"
+	if orderedListStart.MatchString(lines[i]) {
+		tag = "ol"
+	}
+	kind := listMarkerKind(listItemPattern.FindStringSubmatch(lines[i])[1])
+	b.WriteString("<" + tag + ">\n")
+
+	var item []string
+	flush := func() {
+		if item != nil {
+			b.WriteString("<li>" + renderParagraph(item) + "</li>\n")
+			item = nil
+		}
+	}
+	for i < len(lines) {
+		line := lines[i]
+		if m := listItemPattern.FindStringSubmatch(line); m != nil {
+			if listMarkerKind(m[1]) != kind {
+				break
+			}
+			flush()
+			item = []string{m[2]}
+			i++
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			// 空行の後に同じ種類の項目が続く場合は同じリストとする
+			if i+1 < len(lines) {
+				if m := listItemPattern.FindStringSubmatch(lines[i+1]); m != nil && listMarkerKind(m[1]) == kind {
+					i++
+					continue
+				}
+			}
+			break
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && item == nil {
+			break
+		}
+		// 項目の続きの行
+		item = append(item, strings.TrimSpace(line))
+		i++
+	}
+	flush()
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// listMarkerKind はリストの記号の種類を返す。番号付きの場合は区切り（"." または ")"）、それ以外は記号そのもの
+func listMarkerKind(marker string) byte {
+	return marker[len(marker)-1]
+}
+
+// renderTable は見出し行と区切り行で始まる表を出力し、次の行の位置を返す
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for _, cell := range splitTableRow(lines[i]) {
+		b.WriteString("<th>" + renderInline(cell) + "</th>")
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	i += 2
+	for i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != "" {
+		b.WriteString("<tr>")
+		for _, cell := range splitTableRow(lines[i]) {
+			b.WriteString("<td>" + renderInline(cell) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+		i++
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+// renderParagraph は段落の行をつなぐ。行末の2つ以上の空白は改行にする
+func renderParagraph(lines []string) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		hardBreak := strings.HasSuffix(line, "  ") && i < len(lines)-1
+		parts[i] = renderInline(strings.TrimSpace(line))
+		if hardBreak {
+			parts[i] += "<br>"
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// renderInline は行内の記法（コード、リンク、画像、自動リンク、強調、打ち消し、エスケープ）をHTMLにする
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// 記法に関係しない文字はまとめてエスケープする
+		next := strings.IndexAny(s[i:], "\\`[!<*_~h&")
+		if next < 0 {
+			b.WriteString(html.EscapeString(s[i:]))
+			break
+		}
+		b.WriteString(html.EscapeString(s[i : i+next]))
+		i += next
+
+		if n := renderInlineAt(&b, s, i); n > 0 {
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// renderInlineAt はs[i]から始まる記法を出力して消費したバイト数を返す。記法でない場合は0を返す
+func renderInlineAt(b *strings.Builder, s string, i int) int {
+	rest := s[i:]
+	switch rest[0] {
+	case '\\':
+		if len(rest) > 1 && strings.ContainsRune("\\`*_{}[]()#+-.!|~>^", rune(rest[1])) {
+			b.WriteString(html.EscapeString(rest[1:2]))
+			return 2
+		}
+
+	case '`':
+		if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+			b.WriteString("<code>" + html.EscapeString(rest[1:end+1]) + "</code>")
+			return end + 2
+		}
+
+	case '!':
+		if text, src, n, ok := parseLink(rest[1:]); ok {
+			src = html.UnescapeString(src)
+			b.WriteString(`<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(text) + `">`)
+			return n + 1
+		}
+
+	case '[':
+		if text, href, n, ok := parseLink(rest); ok {
+			href = html.UnescapeString(href)
+			b.WriteString(`<a href="` + html.EscapeString(href) + `">` + renderInline(text) + "</a>")
+			return n
+		}
+
+	case '<':
+		if end := strings.IndexByte(rest, '>'); end > 0 {
+			if href := rest[1:end]; isAutolink(href) && !strings.ContainsAny(href, " <") {
+				writeAutolink(b, href)
+				return end + 1
+			}
+		}
+
+	case 'h':
+		if (i == 0 || !isWordByte(s[i-1])) && isAutolink(rest) {
+			href := autolinkAt(rest)
+			writeAutolink(b, href)
+			return len(href)
+		}
+
+	case '&':
+		// Markdownの文字に書かれた実体参照（Redditの "&#x200B;" や "&nbsp;" など）は文字として解釈する
+		if ref := entityPattern.FindString(rest); ref != "" {
+			if decoded := html.UnescapeString(ref); decoded != ref {
+				b.WriteString(html.EscapeString(decoded))
+				return len(ref)
+			}
+		}
+
+	case '*', '_', '~':
+		return renderEmphasis(b, s, i)
+	}
+	return 0
+}
+
+func renderEmphasis(b *strings.Builder, s string, i int) int {
+	rest := s[i:]
+	c := rest[0]
+	// "_" は snake_case のような語中では強調にしない
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0
+	}
+
+	for _, d := range []struct {
+		delim string
+		tag   string
+	}{
+		{strings.Repeat(string(c), 2), doubleDelimTags[c]},
+		{string(c), singleDelimTags[c]},
+	} {
+		if d.tag == "" || !strings.HasPrefix(rest, d.delim) {
+			continue
+		}
+		inner := rest[len(d.delim):]
+		if inner == "" || inner[0] == ' ' {
+			continue
+		}
+		if len(inner) > maxEmphasisLength {
+			inner = inner[:maxEmphasisLength]
+		}
+		end := strings.Index(inner, d.delim)
+		// 単独の区切りを探す場合は、2つ続く区切りの一部と一致させない
+		for end >= 0 && len(d.delim) == 1 && end+1 < len(inner) && inner[end+1] == c {
+			next := strings.Index(inner[end+2:], d.delim)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 2 + next
+		}
+		if end <= 0 || inner[end-1] == ' ' {
+			continue
+		}
+		after := i + len(d.delim) + end + len(d.delim)
+		if c == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+		b.WriteString("<" + d.tag + ">" + renderInline(inner[:end]) + "</" + d.tag + ">")
+		return after - i
+	}
+	return 0
+}
+
+// 記法の終わりを探す長さの上限。閉じていない [ や ( 、対応しない強調の区切りが多い本文で、
+// 記号ごとに本文の最後まで探して処理時間が本文の長さの2乗に比例しないようにする
+const (
+	maxLinkTextLength        = 1000
+	maxLinkDestinationLength = 2048
+	maxEmphasisLength        = 1000
+)
+
+// parseLink は [text](url "title") 形式のリンクを解析する
+func parseLink(s string) (text, href string, n int, ok bool) {
+	depth := 0
+	closeText := -1
+	for i := 0; i < len(s) && i < maxLinkTextLength && closeText < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = i
+			}
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return "", "", 0, false
+	}
+
+	// URLに含まれる括弧（Wikipediaなど）に対応する
+	depth = 0
+	for i := closeText + 1; i < len(s) && i <= closeText+maxLinkDestinationLength; i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				dest := strings.TrimSpace(s[closeText+2 : i])
+				if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+					dest = dest[:sp] // タイトルは使わない
+				}
+				dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+				if dest == "" {
+					return "", "", 0, false
+				}
+				return s[1:closeText], dest, i + 1, true
+			}
+		}
+	}
+	return "", "", 0, false
+}
+
+func isAutolink(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// autolinkAt は文中のURLを空白まで取り出す。末尾の句読点と対応しない閉じ括弧は含めない
+func autolinkAt(s string) string {
+	end := strings.IndexAny(s, " \t\n<")
+	if end < 0 {
+		end = len(s)
+	}
+	href := s[:end]
+	for len(href) > 0 {
+		last := href[len(href)-1]
+		if strings.IndexByte(".,;:!?'\"*_~", last) >= 0 ||
+			(last == ')' && strings.Count(href, "(") < strings.Count(href, ")")) {
+			href = href[:len(href)-1]
+			continue
+		}
+		break
+	}
+	return href
+}
+
+func writeAutolink(b *strings.Builder, href string) {
+	escaped := html.EscapeString(href)
+	b.WriteString(`<a href="` + escaped + `">` + escaped + "</a>")
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,78 @@
+package normalizer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"段落と改行", "a  \nb\n\nc", "<p>a<br>\nb</p>\n<p>c</p>\n"},
+		{"見出し", "## Title ##", "<h2>Title</h2>\n"},
+		{"区切り線", "---", "<hr>\n"},
+		{"引用", "> quoted\n> **bold**", "<blockquote>\n<p>quoted\n<strong>bold</strong></p>\n</blockquote>\n"},
+		{"フェンスのコード", "```\n<b>&\n```", "<pre><code>&lt;b&gt;&amp;</code></pre>\n"},
+		{"インデントのコード", "    x := 1\n\n    y := 2", "<pre><code>x := 1\n\ny := 2</code></pre>\n"},
+		{"箇条書き", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"空行を挟んだ項目は同じリスト", "1. a\n\n2. b", "<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"番号付きから箇条書きに変わる", "1. a\n- b", "<ol>\n<li>a</li>\n</ol>\n<ul>\n<li>b</li>\n</ul>\n"},
+		{"箇条書きの記号が変わる", "- a\n* b", "<ul>\n<li>a</li>\n</ul>\n<ul>\n<li>b</li>\n</ul>\n"},
+		{"番号の区切りが変わる", "1. a\n\n2) b", "<ol>\n<li>a</li>\n</ol>\n<ol>\n<li>b</li>\n</ol>\n"},
+		{"表", "a | b\n--|--\n1 | *2*", "<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td><em>2</em></td></tr>\n</tbody>\n</table>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.src); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got: %q\nwant: %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderInline(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"強調", "**a** *b* ~~c~~", "<strong>a</strong> <em>b</em> <del>c</del>"},
+		{"語中の _ は強調にしない", "snake_case_name", "snake_case_name"},
+		{"空白で始まる強調は文字のまま", "** a**", "** a**"},
+		{"エスケープ", `\*a\*`, "*a*"},
+		{"コードは記法を解釈しない", "`**a** &amp;`", "<code>**a** &amp;amp;</code>"},
+		{"生のHTMLは文字にする", "<b>x</b>", "&lt;b&gt;x&lt;/b&gt;"},
+		{"リンク", "[a *b*](https://example.com/x_(y) \"title\")", `<a href="https://example.com/x_(y)">a <em>b</em></a>`},
+		{"リンクのURLの実体参照", "[a](https://example.com/?a=1&amp;b=2)", `<a href="https://example.com/?a=1&amp;b=2">a</a>`},
+		{"画像", "![alt](https://example.com/a.png)", `<img src="https://example.com/a.png" alt="alt">`},
+		{"自動リンク", "see https://example.com/a.", `see <a href="https://example.com/a">https://example.com/a</a>.`},
+		{"実体参照", "a&#x200B;b&nbsp;c", "a\u200bb\u00a0c"},
+		{"実体参照で書かれた記号は記法にしない", "&lt;b&gt; &#42;a&#42;", "&lt;b&gt; *a*"},
+		{"実体参照でない &", "a & b &unknown;", "a &amp; b &amp;unknown;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderInline(tt.src); got != tt.want {
+				t.Errorf("renderInline(%q)\n got: %q\nwant: %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// 閉じていない記法が繰り返される本文でも、処理時間が本文の長さの2乗に比例しない
+func TestRenderMarkdownUnclosedSyntax(t *testing.T) {
+	for _, unit := range []string{"[a](", "![a](", "**a ", "*x**", "[", "<http:"} {
+		t.Run(unit, func(t *testing.T) {
+			src := strings.Repeat(unit, 80_000/len(unit))
+			start := time.Now()
+			renderMarkdown(src)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("rendering %d bytes took %v", len(src), elapsed)
+			}
+		})
+	}
+}
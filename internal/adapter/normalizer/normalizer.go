@@ -0,0 +1,134 @@
+// Package normalizer は取得したアイテムの本文を、データソースによらない形式（サニタイズ済みのHTML、
+// プレーンテキスト、抜粋、リンクと画像）にする。Webアプリがデータソースごとに同じ処理を持たなくてよいようにする
+package normalizer
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// Format は本文の形式
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatText     Format = "text"
+)
+
+// DefaultExcerptLength は抜粋の既定の文字数
+const DefaultExcerptLength = 200
+
+// ContentNormalizer はデータソースごとの形式で本文を解釈する。登録されていないデータソースはHTMLとして扱う
+type ContentNormalizer struct {
+	formats       map[string]Format
+	excerptLength int
+}
+
+func NewContentNormalizer() *ContentNormalizer {
+	return &ContentNormalizer{
+		formats: map[string]Format{
+			// selftextはMarkdownで、&amp; などの実体参照を含む
+			"reddit": FormatMarkdown,
+		},
+		excerptLength: DefaultExcerptLength,
+	}
+}
+
+// SetFormat はデータソースの本文の形式を設定する
+func (n *ContentNormalizer) SetFormat(source string, format Format) {
+	n.formats[source] = format
+}
+
+// SetExcerptLength は抜粋の文字数を変更する
+func (n *ContentNormalizer) SetExcerptLength(length int) {
+	if length > 0 {
+		n.excerptLength = length
+	}
+}
+
+var _ output.ContentNormalizer = (*ContentNormalizer)(nil)
+
+func (n *ContentNormalizer) Normalize(ctx context.Context, item *model.FetchedData) error {
+	item.Title = strings.TrimSpace(html.UnescapeString(item.Title))
+	if strings.TrimSpace(item.Content) == "" {
+		return nil
+	}
+
+	var fragment string
+	switch n.format(item.Source) {
+	case FormatMarkdown:
+		// Markdownの文字として書かれた < や & を実体参照から戻してから解釈する。
+		// 本文に書かれた実体参照（戻すと "&#x200B;" になるもの）はrenderMarkdownが文字にする
+		fragment = renderMarkdown(html.UnescapeString(item.Content))
+	case FormatText:
+		fragment = renderText(item.Content)
+	default:
+		fragment = item.Content
+	}
+
+	// 相対URLはアイテムのURLを基準にする
+	var base *url.URL
+	if item.URL != "" {
+		if u, err := url.Parse(item.URL); err == nil && u.IsAbs() {
+			base = u
+		}
+	}
+
+	doc, err := sanitize(fragment, base)
+	if err != nil {
+		return fmt.Errorf("failed to normalize content of %s: %w", item.ID, err)
+	}
+	item.ContentHTML = doc.html
+	item.ContentText = doc.text
+	item.Excerpt = excerpt(doc.text, n.excerptLength)
+	item.Links = doc.links
+	item.Images = doc.images
+	return nil
+}
+
+func (n *ContentNormalizer) format(source string) Format {
+	if f, ok := n.formats[source]; ok {
+		return f
+	}
+	return FormatHTML
+}
+
+// renderText はプレーンテキストを段落ごとのHTMLにする
+func renderText(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(para), "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>\n")
+	}
+	return b.String()
+}
+
+// excerpt はテキストを1行にして先頭からlength文字で切る。
+// 単語の途中で切らないよう、後半に空白があればそこで切り、切った場合は末尾に「…」を付ける
+func excerpt(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:length])
+	if i := strings.LastIndex(cut, " "); i > len(cut)*2/3 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:、。") + "…"
+}
@@ -0,0 +1,29 @@
+package normalizer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// Redditのselftextは実体参照をさらにエスケープしているため、2回戻して文字にする
+func TestNormalizeRedditEntities(t *testing.T) {
+	item := &model.FetchedData{
+		Source:  "reddit",
+		Title:   "Q &amp; A",
+		Content: "a &amp;lt;b&amp;gt;&amp;#x200B;\n\n&amp;nbsp;\n\n**c** &amp; d",
+	}
+	if err := NewContentNormalizer().Normalize(context.Background(), item); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if want := "Q & A"; item.Title != want {
+		t.Errorf("Title = %q, want %q", item.Title, want)
+	}
+	if want := "<p>a &lt;b&gt;\u200b</p>\n<p>\u00a0</p>\n<p><strong>c</strong> &amp; d</p>"; item.ContentHTML != want {
+		t.Errorf("ContentHTML\n got: %q\nwant: %q", item.ContentHTML, want)
+	}
+	if want := "a <b>\u200b\n\nc & d"; item.ContentText != want {
+		t.Errorf("ContentText\n got: %q\nwant: %q", item.ContentText, want)
+	}
+}
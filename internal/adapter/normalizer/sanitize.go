@@ -0,0 +1,322 @@
+package normalizer
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedAttrs は残す要素と、その要素で残す属性。ここにない要素はタグだけを取り除き、中身は残す
+var allowedAttrs = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil, atom.Section: nil, atom.Article: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Blockquote: nil, atom.Pre: nil, atom.Code: nil,
+	atom.Em: nil, atom.Strong: nil, atom.B: nil, atom.I: nil, atom.U: nil, atom.S: nil, atom.Del: nil,
+	atom.Sup: nil, atom.Sub: nil,
+	atom.Ul: nil, atom.Ol: nil, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"},
+	atom.Figure: nil, atom.Figcaption: nil,
+	atom.A:   {"href", "title"},
+	atom.Img: {"src", "alt", "title"},
+}
+
+// droppedElements は中身ごと取り除く要素
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Math: true, atom.Head: true,
+	atom.Title: true, atom.Form: true, atom.Button: true, atom.Select: true, atom.Textarea: true,
+}
+
+// blockElements はプレーンテキストで段落として区切る要素
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Aside: true, atom.Main: true, atom.Nav: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Table: true,
+	atom.Figure: true, atom.Hr: true, atom.Dl: true,
+}
+
+// lineElements はプレーンテキストで改行する要素
+var lineElements = map[atom.Atom]bool{
+	atom.Li: true, atom.Tr: true, atom.Br: true, atom.Figcaption: true, atom.Dt: true, atom.Dd: true,
+}
+
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true,
+}
+
+var (
+	spacePattern     = regexp.MustCompile(`[ \t\r\n\f\v\x{00a0}]+`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// document はサニタイズの結果
+type document struct {
+	html   string
+	text   string
+	links  []string
+	images []string
+}
+
+// sanitize はHTMLの断片を許可した要素と属性だけにし、同時にプレーンテキストとリンク・画像を取り出す。
+// 相対URLはbaseで解決し、http(s)以外のURL（javascript: など）は取り除く
+func sanitize(fragment string, base *url.URL) (*document, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sanitizer{base: base, seen: make(map[string]bool)}
+	for _, n := range nodes {
+		s.walk(n)
+	}
+
+	text := blankLinePattern.ReplaceAllString(s.text.String(), "\n\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return &document{
+		html:   strings.TrimSpace(s.html.String()),
+		text:   strings.TrimSpace(strings.Join(lines, "\n")),
+		links:  s.links,
+		images: s.images,
+	}, nil
+}
+
+type sanitizer struct {
+	base *url.URL
+	html strings.Builder
+	text strings.Builder
+
+	links  []string
+	images []string
+	seen   map[string]bool
+
+	inPre int
+	// pendingBreak は次のテキストの前に入れる改行
+	pendingBreak  string
+	trailingSpace bool
+}
+
+func (s *sanitizer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		s.html.WriteString(html.EscapeString(n.Data))
+		s.writeText(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		// コメントやDOCTYPEは出力しない
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			s.walk(c)
+		}
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	if blockElements[n.DataAtom] {
+		s.breakText("\n\n")
+	} else if lineElements[n.DataAtom] {
+		s.breakText("\n")
+	}
+	switch n.DataAtom {
+	case atom.Li:
+		s.writeText(listMarker(n))
+	case atom.Td, atom.Th:
+		// 同じ行のセルは空白で区切る
+		if hasPreviousElement(n) {
+			s.writeText(" ")
+		}
+	}
+	if n.DataAtom == atom.Pre {
+		s.inPre++
+		defer func() { s.inPre-- }()
+	}
+
+	attrs, keep := s.attributes(n)
+	if keep {
+		s.html.WriteString("<" + n.Data + attrs + ">")
+	}
+	if n.DataAtom == atom.Img {
+		if alt := attr(n, "alt"); alt != "" && !keep {
+			s.writeText(alt)
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.walk(c)
+	}
+	if keep && !isVoid(n.DataAtom) {
+		s.html.WriteString("</" + n.Data + ">")
+	}
+	if blockElements[n.DataAtom] {
+		s.breakText("\n\n")
+	}
+}
+
+// attributes は残す属性を返す。許可していない要素と、安全なURLのないリンク・画像は残さない
+func (s *sanitizer) attributes(n *html.Node) (string, bool) {
+	allowed, ok := allowedAttrs[n.DataAtom]
+	if !ok {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, name := range allowed {
+		value := attr(n, name)
+		if value == "" {
+			continue
+		}
+		switch name {
+		case "href", "src":
+			resolved, ok := s.resolve(value, name == "href")
+			if !ok {
+				continue
+			}
+			value = resolved
+		case "colspan", "rowspan":
+			if _, err := strconv.Atoi(value); err != nil {
+				continue
+			}
+		}
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		href, ok := s.resolve(attr(n, "href"), true)
+		if !ok {
+			return "", false
+		}
+		if strings.HasPrefix(href, "http") {
+			s.addLink(href)
+		}
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	case atom.Img:
+		src, ok := s.resolve(attr(n, "src"), false)
+		if !ok {
+			return "", false
+		}
+		s.addImage(src)
+	}
+	return b.String(), true
+}
+
+// resolve は相対URLを解決し、http(s)（リンクの場合はmailtoも）の場合だけ返す
+func (s *sanitizer) resolve(raw string, isLink bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if s.base != nil {
+		u = s.base.ResolveReference(u)
+	} else if u.Scheme == "" && u.Host != "" {
+		u.Scheme = "https" // プロトコル相対URL
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+		return u.String(), true
+	case "mailto":
+		return u.String(), isLink
+	default:
+		return "", false
+	}
+}
+
+func (s *sanitizer) addLink(link string) {
+	if !s.seen["link:"+link] {
+		s.seen["link:"+link] = true
+		s.links = append(s.links, link)
+	}
+	// 画像ファイルへのリンク（i.redd.it など）は画像としても扱う
+	if u, err := url.Parse(link); err == nil && imageExtensions[strings.ToLower(path.Ext(u.Path))] {
+		s.addImage(link)
+	}
+}
+
+func (s *sanitizer) addImage(src string) {
+	if !s.seen["image:"+src] {
+		s.seen["image:"+src] = true
+		s.images = append(s.images, src)
+	}
+}
+
+// writeText はプレーンテキストに書き込む。pre以外では連続する空白を1つにする
+func (s *sanitizer) writeText(t string) {
+	if s.inPre == 0 {
+		t = spacePattern.ReplaceAllString(t, " ")
+		if s.text.Len() == 0 || s.pendingBreak != "" || s.trailingSpace {
+			t = strings.TrimLeft(t, " ")
+		}
+	}
+	if t == "" {
+		return
+	}
+	if s.pendingBreak != "" && s.text.Len() > 0 {
+		s.text.WriteString(s.pendingBreak)
+	}
+	s.pendingBreak = ""
+	s.text.WriteString(t)
+	s.trailingSpace = strings.HasSuffix(t, " ") || strings.HasSuffix(t, "\n")
+}
+
+// breakText は次のテキストの前に改行を入れる。続けて呼ばれた場合は多い方にする
+func (s *sanitizer) breakText(brk string) {
+	if len(brk) > len(s.pendingBreak) {
+		s.pendingBreak = brk
+	}
+}
+
+// listMarker はプレーンテキストでのリスト項目の記号を返す（番号付きリストは番号）
+func listMarker(li *html.Node) string {
+	if li.Parent == nil || li.Parent.DataAtom != atom.Ol {
+		return "- "
+	}
+	n := 1
+	for c := li.PrevSibling; c != nil; c = c.PrevSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Li {
+			n++
+		}
+	}
+	return strconv.Itoa(n) + ". "
+}
+
+func hasPreviousElement(n *html.Node) bool {
+	for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+		if c.Type == html.ElementNode {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isVoid(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
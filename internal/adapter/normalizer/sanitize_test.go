@@ -0,0 +1,85 @@
+package normalizer
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	tests := []struct {
+		name   string
+		src    string
+		html   string
+		text   string
+		links  []string
+		images []string
+	}{
+		{
+			name: "scriptとstyleは中身ごと取り除く",
+			src:  `<p>a<script>alert(1)</script><style>p{}</style>b</p>`,
+			html: "<p>ab</p>",
+			text: "ab",
+		},
+		{
+			name: "許可していない要素はタグだけ取り除く",
+			src:  `<font color="red">a</font><p class="x" onclick="y()">b</p>`,
+			html: "a<p>b</p>",
+			text: "a\n\nb",
+		},
+		{
+			name:  "リンクには rel を付け、相対URLを解決する",
+			src:   `<a href="/about" title="t" target="_blank">about</a>`,
+			html:  `<a href="https://example.com/about" title="t" rel="nofollow noopener noreferrer">about</a>`,
+			text:  "about",
+			links: []string{"https://example.com/about"},
+		},
+		{
+			name: "javascript: のリンクはタグを取り除く",
+			src:  `<a href="javascript:alert(1)">x</a>`,
+			html: "x",
+			text: "x",
+		},
+		{
+			name:   "画像と画像へのリンク",
+			src:    `<img src="a.png" alt="a" onerror="x()"><a href="https://i.redd.it/b.jpg">b</a><a href="https://i.redd.it/b.jpg">b</a>`,
+			html:   `<img src="https://example.com/posts/a.png" alt="a"><a href="https://i.redd.it/b.jpg" rel="nofollow noopener noreferrer">b</a><a href="https://i.redd.it/b.jpg" rel="nofollow noopener noreferrer">b</a>`,
+			text:   "bb",
+			links:  []string{"https://i.redd.it/b.jpg"},
+			images: []string{"https://example.com/posts/a.png", "https://i.redd.it/b.jpg"},
+		},
+		{
+			name: "数値でない colspan は取り除く",
+			src:  `<table><tr><td colspan="2" rowspan="x">a</td></tr></table>`,
+			html: `<table><tbody><tr><td colspan="2">a</td></tr></tbody></table>`,
+			text: "a",
+		},
+		{
+			name: "リストと改行のプレーンテキスト",
+			src:  "<ol><li>a</li><li>b</li></ol><p>c<br>d</p>",
+			html: "<ol><li>a</li><li>b</li></ol><p>c<br>d</p>",
+			text: "1. a\n2. b\n\nc\nd",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := sanitize(tt.src, base)
+			if err != nil {
+				t.Fatalf("sanitize: %v", err)
+			}
+			if doc.html != tt.html {
+				t.Errorf("html\n got: %q\nwant: %q", doc.html, tt.html)
+			}
+			if doc.text != tt.text {
+				t.Errorf("text\n got: %q\nwant: %q", doc.text, tt.text)
+			}
+			if !slices.Equal(doc.links, tt.links) {
+				t.Errorf("links = %v, want %v", doc.links, tt.links)
+			}
+			if !slices.Equal(doc.images, tt.images) {
+				t.Errorf("images = %v, want %v", doc.images, tt.images)
+			}
+		})
+	}
+}
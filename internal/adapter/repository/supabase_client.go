@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabaseAuthMode はSupabaseへの接続に使う認証方式
+type SupabaseAuthMode string
+
+const (
+	// SupabaseAuthServiceRole はservice_roleキーでRLSをバイパスする。全ユーザーを対象とするバッチ処理に使う
+	SupabaseAuthServiceRole SupabaseAuthMode = "service_role"
+	// SupabaseAuthUserJWT はユーザーのJWTで接続する。RLSにより本人の設定のみ参照・更新できる
+	SupabaseAuthUserJWT SupabaseAuthMode = "user_jwt"
+)
+
+// SupabaseAccess はコマンドが必要とするデータベースの権限
+type SupabaseAccess int
+
+const (
+	// AccessUserScoped は自分の設定の参照・更新のみを行う。RLSの範囲内で動作する
+	AccessUserScoped SupabaseAccess = iota
+	// AccessBatch は全ユーザーの設定を参照し、取得データや実行履歴を書き込む。RLSのバイパスが必要
+	AccessBatch
+)
+
+// SupabaseAuthConfig はSupabaseクライアントの接続設定
+type SupabaseAuthConfig struct {
+	URL  string
+	Mode SupabaseAuthMode
+	// ServiceKey はservice_roleモードで使うキー（SUPABASE_SERVICE_KEY）
+	ServiceKey string
+	// AnonKey はuser_jwtモードでapikeyヘッダーに使う公開キー（SUPABASE_ANON_KEY）
+	AnonKey string
+	// UserJWT はuser_jwtモードでAuthorizationヘッダーに使うユーザーのアクセストークン（SUPABASE_USER_JWT）
+	UserJWT string
+}
+
+// jwtClaims はキーの種類の確認に使うJWTのクレーム
+type jwtClaims struct {
+	Role    string `json:"role"`
+	Subject string `json:"sub"`
+	Expiry  int64  `json:"exp"`
+}
+
+// NewSupabaseClient は認証方式に応じたSupabaseクライアントを作る
+func NewSupabaseClient(cfg SupabaseAuthConfig) (*supabase.Client, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("SUPABASE_URL must be set")
+	}
+
+	switch cfg.Mode {
+	case SupabaseAuthServiceRole, "":
+		if cfg.ServiceKey == "" {
+			if cfg.AnonKey != "" {
+				return nil, errors.New("SUPABASE_SERVICE_KEY must be set: SUPABASE_ANON_KEY is no longer used for batch access because RLS prevents it from reading other users' configs and writing fetched_data (set SUPABASE_AUTH_MODE=user_jwt for user-scoped access)")
+			}
+			return nil, errors.New("SUPABASE_SERVICE_KEY must be set in service_role mode")
+		}
+		return supabase.NewClient(cfg.URL, cfg.ServiceKey, nil)
+	case SupabaseAuthUserJWT:
+		if cfg.AnonKey == "" || cfg.UserJWT == "" {
+			return nil, errors.New("SUPABASE_ANON_KEY and SUPABASE_USER_JWT must be set in user_jwt mode")
+		}
+		// apikeyはプロジェクトの公開キー、Authorizationはユーザーのトークンにする
+		return supabase.NewClient(cfg.URL, cfg.AnonKey, &supabase.ClientOptions{
+			Headers: map[string]string{"Authorization": "Bearer " + cfg.UserJWT},
+		})
+	default:
+		return nil, fmt.Errorf("invalid SUPABASE_AUTH_MODE %q (expected %q or %q)", cfg.Mode, SupabaseAuthServiceRole, SupabaseAuthUserJWT)
+	}
+}
+
+// CheckSupabaseAccess は設定されたキーがコマンドに必要な権限を持っているかを起動時に確認する。
+// キーの種類をクレームから判定したうえで、テーブルを参照できるかを問い合わせ、
+// check_table_access 関数（docs/db.md）で書き込むテーブルへの挿入が権限とRLSのポリシーで許可されているかを確認する
+func CheckSupabaseAccess(ctx context.Context, client *supabase.Client, cfg SupabaseAuthConfig, access SupabaseAccess) error {
+	ctx, span := tracing.Start(ctx, "db.check_access")
+	defer span.End()
+
+	if err := checkSupabaseKey(cfg, access, time.Now()); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	// 接続先とキーが正しいかを確認する。RLSで拒否された参照はエラーにならず空になるため、権限は下で確認する
+	readTables := []string{"user_fetch_configs"}
+	writeTables := []string{"user_fetch_configs", "reddit_fetch_configs"}
+	if access == AccessBatch {
+		readTables = append(readTables, "fetched_data", "fetch_runs")
+		writeTables = []string{"fetched_data", "fetch_runs", "fetch_run_items"}
+	}
+	for _, table := range readTables {
+		var rows []struct {
+			ID interface{} `json:"id"`
+		}
+		if _, err := client.From(table).Select("id", "", false).Limit(1, "").ExecuteTo(&rows); err != nil {
+			return tracing.Fail(span, fmt.Errorf("supabase access check failed: cannot read %s in %s mode (check SUPABASE_URL and the key): %w", table, modeName(cfg.Mode), err))
+		}
+	}
+
+	var results []tableAccess
+	err := callRPC(ctx, client, "check_table_access", map[string]interface{}{
+		"p_tables": lo.Uniq(append(readTables, writeTables...)),
+	}, &results)
+	if isMissingFunction(err) {
+		slog.WarnContext(ctx, "check_table_access is not installed, skipping the write access check (see docs/db.md)")
+		return nil
+	}
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("supabase access check failed: %w", err))
+	}
+	return tracing.Fail(span, checkTableAccess(results, readTables, writeTables, cfg.Mode))
+}
+
+// tableAccess は check_table_access 関数の結果の1行
+type tableAccess struct {
+	Table    string `json:"table_name"`
+	CanRead  bool   `json:"can_read"`
+	CanWrite bool   `json:"can_write"`
+}
+
+// checkTableAccess は参照・挿入できないテーブルがあればまとめてエラーにする
+func checkTableAccess(results []tableAccess, readTables, writeTables []string, mode SupabaseAuthMode) error {
+	byTable := lo.KeyBy(results, func(a tableAccess) string { return a.Table })
+	var problems []string
+	for _, table := range readTables {
+		if !byTable[table].CanRead {
+			problems = append(problems, "cannot read "+table)
+		}
+	}
+	for _, table := range writeTables {
+		if !byTable[table].CanWrite {
+			problems = append(problems, "cannot insert into "+table)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("supabase access check failed in %s mode: %s", modeName(mode), strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// isMissingFunction はRPCの関数がデータベースに作られていない（PGRST202）かを返す
+func isMissingFunction(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "(PGRST202)")
+}
+
+// checkSupabaseKey はキーの種類が認証方式と必要な権限に合っているかを確認する。
+// 署名の検証はSupabase側で行われるため、ここでは内容のみを確認する
+func checkSupabaseKey(cfg SupabaseAuthConfig, access SupabaseAccess, now time.Time) error {
+	switch cfg.Mode {
+	case SupabaseAuthUserJWT:
+		if access == AccessBatch {
+			return errors.New("this command needs SUPABASE_AUTH_MODE=service_role: under RLS a user JWT can only access its own configs and cannot write fetched_data or fetch runs")
+		}
+		claims, err := parseJWTClaims(cfg.UserJWT)
+		if err != nil {
+			return fmt.Errorf("invalid SUPABASE_USER_JWT: %w", err)
+		}
+		if claims.Role != "authenticated" {
+			return fmt.Errorf("SUPABASE_USER_JWT must be a signed-in user's access token (role %q, expected \"authenticated\")", claims.Role)
+		}
+		if _, err := uuid.Parse(claims.Subject); err != nil {
+			return fmt.Errorf("SUPABASE_USER_JWT has no valid user ID in its sub claim: %w", err)
+		}
+		if claims.Expiry != 0 && now.After(time.Unix(claims.Expiry, 0)) {
+			return fmt.Errorf("SUPABASE_USER_JWT expired at %s", time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339))
+		}
+		return nil
+	default:
+		key := cfg.ServiceKey
+		// 新形式のAPIキーは接頭辞で種類がわかる
+		if strings.HasPrefix(key, "sb_publishable_") {
+			return errors.New("SUPABASE_SERVICE_KEY is a publishable key; use the secret (service_role) key so the batch can bypass RLS")
+		}
+		if strings.HasPrefix(key, "sb_secret_") {
+			return nil
+		}
+		claims, err := parseJWTClaims(key)
+		if err != nil {
+			return fmt.Errorf("invalid SUPABASE_SERVICE_KEY: %w", err)
+		}
+		if claims.Role != "service_role" {
+			return fmt.Errorf("SUPABASE_SERVICE_KEY has role %q; the batch needs the service_role key because RLS hides other users' configs and rejects writes to fetched_data", claims.Role)
+		}
+		return nil
+	}
+}
+
+// parseJWTClaims は署名を検証せずにJWTのペイロードを読む
+func parseJWTClaims(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT claims: %w", err)
+	}
+	return &claims, nil
+}
+
+func modeName(mode SupabaseAuthMode) string {
+	if mode == "" {
+		return string(SupabaseAuthServiceRole)
+	}
+	return string(mode)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/supabase-community/supabase-go"
 )
 
@@ -19,28 +20,37 @@ func NewSupabaseDataSourceRepository(client *supabase.Client) output.DataSourceR
 }
 
 func (r *SupabaseDataSourceRepository) GetByID(ctx context.Context, id string) (*model.DataSource, error) {
+	_, span := startSpan(ctx, "data_sources", "select")
+	defer span.End()
+
 	var dataSource model.DataSource
 	_, err := r.client.From("data_sources").Select("*", "", false).Eq("id", id).Single().ExecuteTo(&dataSource)
 	if err != nil {
-		return nil, err
+		return nil, tracing.Fail(span, err)
 	}
 	return &dataSource, nil
 }
 
 func (r *SupabaseDataSourceRepository) GetAll(ctx context.Context) ([]*model.DataSource, error) {
+	_, span := startSpan(ctx, "data_sources", "select")
+	defer span.End()
+
 	var dataSources []*model.DataSource
 	_, err := r.client.From("data_sources").Select("*", "", false).ExecuteTo(&dataSources)
 	if err != nil {
-		return nil, err
+		return nil, tracing.Fail(span, err)
 	}
 	return dataSources, nil
 }
 
 func (r *SupabaseDataSourceRepository) GetActive(ctx context.Context) ([]*model.DataSource, error) {
+	_, span := startSpan(ctx, "data_sources", "select")
+	defer span.End()
+
 	var dataSources []*model.DataSource
 	_, err := r.client.From("data_sources").Select("*", "", false).Eq("is_active", "true").ExecuteTo(&dataSources)
 	if err != nil {
-		return nil, err
+		return nil, tracing.Fail(span, err)
 	}
 	return dataSources, nil
 }
@@ -3,9 +3,14 @@ package repository
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
 )
 
@@ -20,14 +25,191 @@ func NewSupabaseFetchConfigRepository(client *supabase.Client) output.FetchConfi
 }
 
 func (r *SupabaseFetchConfigRepository) GetByUserID(ctx context.Context, userID model.UserID) ([]model.UserFetchConfig, error) {
+	ctx, span := startSpan(ctx, "user_fetch_configs", "select")
+	defer span.End()
+
 	var fetchConfigs []model.UserFetchConfig
-	_, err := r.client.From("user_fetch_configs").Select("*", "", false).Eq("is_active", "true").ExecuteTo(&fetchConfigs)
+	_, err := r.client.From("user_fetch_configs").Select("*", "", false).Eq("user_id", string(userID)).Eq("is_active", "true").Is("suspended_at", "null").ExecuteTo(&fetchConfigs)
 	if err != nil {
-		return nil, fmt.Errorf("an error occurred during GetByUserID(user_fetch_config): %w", err)
+		return nil, tracing.Fail(span, fmt.Errorf("an error occurred during GetByUserID(user_fetch_config): %w", err))
 	}
 	if len(fetchConfigs) == 0 {
-		fmt.Printf("no configs found")
+		slog.DebugContext(ctx, "No active configs found", "user_id", userID)
 		return nil, nil
 	}
 	return fetchConfigs, nil
 }
+
+func (r *SupabaseFetchConfigRepository) GetByID(ctx context.Context, configID uuid.UUID) (*model.UserFetchConfig, error) {
+	_, span := startSpan(ctx, "user_fetch_configs", "select")
+	defer span.End()
+
+	var fetchConfig model.UserFetchConfig
+	_, err := r.client.From("user_fetch_configs").Select("*", "", false).Eq("id", configID.String()).Single().ExecuteTo(&fetchConfig)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("an error occurred during GetByID(user_fetch_config): %w", err))
+	}
+	return &fetchConfig, nil
+}
+
+func (r *SupabaseFetchConfigRepository) List(ctx context.Context, filter output.FetchConfigFilter) ([]model.UserFetchConfig, error) {
+	_, span := startSpan(ctx, "user_fetch_configs", "select")
+	defer span.End()
+
+	query := r.client.From("user_fetch_configs").Select("*", "", false)
+	if filter.UserID != nil {
+		query = query.Eq("user_id", filter.UserID.String())
+	}
+	if filter.DataSourceID != "" {
+		query = query.Eq("data_source_id", filter.DataSourceID)
+	}
+	if filter.ActiveOnly {
+		query = query.Eq("is_active", "true").Is("suspended_at", "null")
+	}
+
+	var fetchConfigs []model.UserFetchConfig
+	_, err := query.Order("created_at", &postgrest.OrderOpts{Ascending: true}).ExecuteTo(&fetchConfigs)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("an error occurred during List(user_fetch_config): %w", err))
+	}
+	return fetchConfigs, nil
+}
+
+func (r *SupabaseFetchConfigRepository) Create(ctx context.Context, config *model.UserFetchConfig) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "insert")
+	defer span.End()
+
+	// Supabaseに保存するための構造体（時刻はUTCのオフセット付きで保存する）
+	insert := struct {
+		ID           uuid.UUID       `json:"id"`
+		UserID       uuid.UUID       `json:"user_id"`
+		Name         string          `json:"name"`
+		DataSourceID string          `json:"data_source_id"`
+		IsActive     bool            `json:"is_active"`
+		Schedule     string          `json:"schedule"`
+		CreatedAt    model.Timestamp `json:"created_at"`
+		UpdatedAt    model.Timestamp `json:"updated_at"`
+	}{
+		ID:           config.ID,
+		UserID:       config.UserID,
+		Name:         config.Name,
+		DataSourceID: config.DataSourceID,
+		IsActive:     config.IsActive,
+		Schedule:     config.Schedule,
+		CreatedAt:    model.NewTimestamp(config.CreatedAt),
+		UpdatedAt:    model.NewTimestamp(config.UpdatedAt),
+	}
+
+	_, err := r.client.From("user_fetch_configs").Insert(insert, false, "", "", "").ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during Create(user_fetch_config): %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchConfigRepository) Update(ctx context.Context, config *model.UserFetchConfig) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "update")
+	defer span.End()
+
+	update := map[string]interface{}{
+		"name":       config.Name,
+		"is_active":  config.IsActive,
+		"schedule":   config.Schedule,
+		"updated_at": model.FormatTimestamp(config.UpdatedAt),
+	}
+
+	// 更新された行を返させ、対象が存在しない場合を検出する
+	var updated []model.UserFetchConfig
+	_, err := r.client.From("user_fetch_configs").Update(update, "", "").Eq("id", config.ID.String()).ExecuteTo(&updated)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during Update(user_fetch_config): %w", err))
+	}
+	if len(updated) == 0 {
+		return tracing.Fail(span, fmt.Errorf("config %s: %w", config.ID, output.ErrNotFound))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchConfigRepository) Delete(ctx context.Context, configID uuid.UUID) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "delete")
+	defer span.End()
+
+	var deleted []model.UserFetchConfig
+	_, err := r.client.From("user_fetch_configs").Delete("", "").Eq("id", configID.String()).ExecuteTo(&deleted)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during Delete(user_fetch_config): %w", err))
+	}
+	if len(deleted) == 0 {
+		return tracing.Fail(span, fmt.Errorf("config %s: %w", configID, output.ErrNotFound))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchConfigRepository) GetSuspended(ctx context.Context) ([]model.UserFetchConfig, error) {
+	_, span := startSpan(ctx, "user_fetch_configs", "select")
+	defer span.End()
+
+	var fetchConfigs []model.UserFetchConfig
+	_, err := r.client.From("user_fetch_configs").Select("*", "", false).Not("suspended_at", "is", "null").ExecuteTo(&fetchConfigs)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("an error occurred during GetSuspended(user_fetch_config): %w", err))
+	}
+	return fetchConfigs, nil
+}
+
+func (r *SupabaseFetchConfigRepository) RecordSuccess(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "update")
+	defer span.End()
+
+	update := map[string]interface{}{
+		"last_fetched_at":      model.FormatTimestamp(fetchedAt),
+		"consecutive_failures": 0,
+		"last_error_kind":      nil,
+		"last_error":           nil,
+	}
+	_, err := r.client.From("user_fetch_configs").Update(update, "", "").Eq("id", configID.String()).ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during RecordSuccess(user_fetch_config): %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchConfigRepository) RecordFailure(ctx context.Context, configID uuid.UUID, failure model.FetchFailure) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "update")
+	defer span.End()
+
+	update := map[string]interface{}{
+		"consecutive_failures": failure.ConsecutiveFailures,
+		"last_error_kind":      failure.LastErrorKind,
+		"last_error":           failure.LastError,
+		"suspended_at":         model.NewTimestampPtr(failure.SuspendedAt),
+		"suspended_reason":     failure.SuspendedReason,
+	}
+	_, err := r.client.From("user_fetch_configs").Update(update, "", "").Eq("id", configID.String()).ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during RecordFailure(user_fetch_config): %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchConfigRepository) Unsuspend(ctx context.Context, configID uuid.UUID) error {
+	_, span := startSpan(ctx, "user_fetch_configs", "update")
+	defer span.End()
+
+	update := map[string]interface{}{
+		"consecutive_failures": 0,
+		"last_error_kind":      nil,
+		"last_error":           nil,
+		"suspended_at":         nil,
+		"suspended_reason":     nil,
+	}
+	var updated []model.UserFetchConfig
+	_, err := r.client.From("user_fetch_configs").Update(update, "", "").Eq("id", configID.String()).ExecuteTo(&updated)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("an error occurred during Unsuspend(user_fetch_config): %w", err))
+	}
+	if len(updated) == 0 {
+		return tracing.Fail(span, fmt.Errorf("config %s: %w", configID, output.ErrNotFound))
+	}
+	return nil
+}
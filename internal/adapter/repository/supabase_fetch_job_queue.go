@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabaseFetchJobQueue はfetch_jobsテーブルとclaim_fetch_jobs等のPostgres関数によるジョブキュー。
+// ジョブの取得は FOR UPDATE SKIP LOCKED で行うため、複数のワーカーから同時に呼び出せる
+type SupabaseFetchJobQueue struct {
+	client *supabase.Client
+}
+
+func NewSupabaseFetchJobQueue(client *supabase.Client) output.FetchJobQueue {
+	return &SupabaseFetchJobQueue{
+		client: client,
+	}
+}
+
+func (q *SupabaseFetchJobQueue) Enqueue(ctx context.Context, configID uuid.UUID, maxAttempts int) (bool, error) {
+	var enqueued bool
+	err := callRPC(ctx, q.client, "enqueue_fetch_job", map[string]interface{}{
+		"p_config_id":    configID.String(),
+		"p_max_attempts": maxAttempts,
+	}, &enqueued)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue fetch job for config %s: %w", configID, err)
+	}
+	return enqueued, nil
+}
+
+func (q *SupabaseFetchJobQueue) Claim(ctx context.Context, workerID string, limit int, lease time.Duration) ([]model.FetchJob, error) {
+	var jobs []model.FetchJob
+	err := callRPC(ctx, q.client, "claim_fetch_jobs", map[string]interface{}{
+		"p_worker_id":     workerID,
+		"p_limit":         limit,
+		"p_lease_seconds": int(lease.Seconds()),
+	}, &jobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim fetch jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (q *SupabaseFetchJobQueue) Heartbeat(ctx context.Context, jobID uuid.UUID, workerID string, lease time.Duration) error {
+	var ok bool
+	err := callRPC(ctx, q.client, "heartbeat_fetch_job", map[string]interface{}{
+		"p_job_id":        jobID.String(),
+		"p_worker_id":     workerID,
+		"p_lease_seconds": int(lease.Seconds()),
+	}, &ok)
+	if err != nil {
+		return fmt.Errorf("failed to heartbeat fetch job %s: %w", jobID, err)
+	}
+	if !ok {
+		return fmt.Errorf("fetch job %s, worker %s: %w", jobID, workerID, output.ErrLeaseLost)
+	}
+	return nil
+}
+
+func (q *SupabaseFetchJobQueue) Complete(ctx context.Context, jobID uuid.UUID, workerID string) error {
+	var ok bool
+	err := callRPC(ctx, q.client, "complete_fetch_job", map[string]interface{}{
+		"p_job_id":    jobID.String(),
+		"p_worker_id": workerID,
+	}, &ok)
+	if err != nil {
+		return fmt.Errorf("failed to complete fetch job %s: %w", jobID, err)
+	}
+	if !ok {
+		return fmt.Errorf("fetch job %s, worker %s: %w", jobID, workerID, output.ErrLeaseLost)
+	}
+	return nil
+}
+
+func (q *SupabaseFetchJobQueue) Fail(ctx context.Context, jobID uuid.UUID, workerID string, reason string, retryAfter time.Duration) error {
+	var ok bool
+	err := callRPC(ctx, q.client, "fail_fetch_job", map[string]interface{}{
+		"p_job_id":        jobID.String(),
+		"p_worker_id":     workerID,
+		"p_error":         reason,
+		"p_retry_seconds": int(retryAfter.Seconds()),
+	}, &ok)
+	if err != nil {
+		return fmt.Errorf("failed to record failure of fetch job %s: %w", jobID, err)
+	}
+	if !ok {
+		return fmt.Errorf("fetch job %s, worker %s: %w", jobID, workerID, output.ErrLeaseLost)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+type SupabaseFetchRunRepository struct {
+	client *supabase.Client
+}
+
+func NewSupabaseFetchRunRepository(client *supabase.Client) output.FetchRunRepository {
+	return &SupabaseFetchRunRepository{
+		client: client,
+	}
+}
+
+// Supabaseに保存するための構造体（時刻はUTCのオフセット付きで保存する）
+type fetchRunRow struct {
+	ID         uuid.UUID            `json:"id"`
+	Status     model.FetchRunStatus `json:"status"`
+	StartedAt  model.Timestamp      `json:"started_at"`
+	FinishedAt *model.Timestamp     `json:"finished_at"`
+}
+
+type fetchRunItemRow struct {
+	RunID      uuid.UUID                `json:"run_id"`
+	ConfigID   uuid.UUID                `json:"config_id"`
+	Status     model.FetchRunItemStatus `json:"status"`
+	ItemsSaved int                      `json:"items_saved"`
+	Error      *string                  `json:"error"`
+	StartedAt  *model.Timestamp         `json:"started_at"`
+	FinishedAt *model.Timestamp         `json:"finished_at"`
+}
+
+func toFetchRunRow(run *model.FetchRun) fetchRunRow {
+	return fetchRunRow{
+		ID:         run.ID,
+		Status:     run.Status,
+		StartedAt:  model.NewTimestamp(run.StartedAt),
+		FinishedAt: model.NewTimestampPtr(run.FinishedAt),
+	}
+}
+
+func toFetchRunItemRow(item *model.FetchRunItem) fetchRunItemRow {
+	return fetchRunItemRow{
+		RunID:      item.RunID,
+		ConfigID:   item.ConfigID,
+		Status:     item.Status,
+		ItemsSaved: item.ItemsSaved,
+		Error:      item.Error,
+		StartedAt:  model.NewTimestampPtr(item.StartedAt),
+		FinishedAt: model.NewTimestampPtr(item.FinishedAt),
+	}
+}
+
+func (r *SupabaseFetchRunRepository) Create(ctx context.Context, run *model.FetchRun) error {
+	_, span := startSpan(ctx, "fetch_runs", "insert")
+	defer span.End()
+
+	_, err := r.client.From("fetch_runs").Insert(toFetchRunRow(run), false, "", "", "").ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to insert fetch run: %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchRunRepository) Update(ctx context.Context, run *model.FetchRun) error {
+	_, span := startSpan(ctx, "fetch_runs", "update")
+	defer span.End()
+
+	_, err := r.client.From("fetch_runs").Update(toFetchRunRow(run), "", "").Eq("id", run.ID.String()).ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to update fetch run %s: %w", run.ID, err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchRunRepository) GetByID(ctx context.Context, runID uuid.UUID) (*model.FetchRun, error) {
+	_, span := startSpan(ctx, "fetch_runs", "select")
+	defer span.End()
+
+	var run model.FetchRun
+	_, err := r.client.From("fetch_runs").Select("*", "", false).Eq("id", runID.String()).Single().ExecuteTo(&run)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("failed to get fetch run %s: %w", runID, err))
+	}
+	return &run, nil
+}
+
+func (r *SupabaseFetchRunRepository) List(ctx context.Context, limit int) ([]model.FetchRun, error) {
+	_, span := startSpan(ctx, "fetch_runs", "select")
+	defer span.End()
+
+	var runs []model.FetchRun
+	_, err := r.client.From("fetch_runs").
+		Select("*", "", false).
+		Order("started_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&runs)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("failed to list fetch runs: %w", err))
+	}
+	return runs, nil
+}
+
+func (r *SupabaseFetchRunRepository) CreateItems(ctx context.Context, items []model.FetchRunItem) error {
+	_, span := startSpan(ctx, "fetch_run_items", "insert")
+	defer span.End()
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	rows := make([]fetchRunItemRow, len(items))
+	for i := range items {
+		rows[i] = toFetchRunItemRow(&items[i])
+	}
+
+	_, err := r.client.From("fetch_run_items").Insert(rows, false, "", "", "").ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to insert fetch run items: %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchRunRepository) UpdateItem(ctx context.Context, item *model.FetchRunItem) error {
+	_, span := startSpan(ctx, "fetch_run_items", "update")
+	defer span.End()
+
+	_, err := r.client.From("fetch_run_items").
+		Update(toFetchRunItemRow(item), "", "").
+		Eq("run_id", item.RunID.String()).
+		Eq("config_id", item.ConfigID.String()).
+		ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to update fetch run item %s/%s: %w", item.RunID, item.ConfigID, err))
+	}
+	return nil
+}
+
+func (r *SupabaseFetchRunRepository) GetItems(ctx context.Context, runID uuid.UUID) ([]model.FetchRunItem, error) {
+	_, span := startSpan(ctx, "fetch_run_items", "select")
+	defer span.End()
+
+	var items []model.FetchRunItem
+	_, err := r.client.From("fetch_run_items").Select("*", "", false).Eq("run_id", runID.String()).ExecuteTo(&items)
+	if err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("failed to get items for fetch run %s: %w", runID, err))
+	}
+	return items, nil
+}
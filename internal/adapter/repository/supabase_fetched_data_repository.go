@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/google/uuid"
+	"github.com/samber/lo"
 	"github.com/supabase-community/supabase-go"
 )
 
@@ -21,6 +24,9 @@ func NewSupabaseFetchedDataRepository(client *supabase.Client) output.FetchedDat
 }
 
 func (r *SupabaseFetchedDataRepository) Create(ctx context.Context, data *model.FetchedData) error {
+	_, span := startSpan(ctx, "fetched_data", "insert")
+	defer span.End()
+
 	// Supabaseに保存するための構造体（時刻フィールドを文字列に変換）
 	type fetchedDataInsert struct {
 		ID           uuid.UUID              `json:"id"`
@@ -31,15 +37,24 @@ func (r *SupabaseFetchedDataRepository) Create(ctx context.Context, data *model.
 		URL          string                 `json:"url,omitempty"`
 		AuthorName   string                 `json:"author_name,omitempty"`
 		SourceItemID string                 `json:"source_item_id,omitempty"`
-		PublishedAt  *string                `json:"published_at,omitempty"`
+		PublishedAt  *model.Timestamp       `json:"published_at,omitempty"`
 		Tags         []string               `json:"tags"`
 		MediaURLs    []string               `json:"media_urls"`
+		ContentHTML  string                 `json:"content_html,omitempty"`
+		ContentText  string                 `json:"content_text,omitempty"`
+		Excerpt      string                 `json:"excerpt,omitempty"`
+		Links        []string               `json:"links,omitempty"`
+		Images       []string               `json:"images,omitempty"`
+		CanonicalURL string                 `json:"canonical_url,omitempty"`
+		StoryID      *uuid.UUID             `json:"story_id,omitempty"`
+		Fingerprint  *int64                 `json:"fingerprint,omitempty"`
+		DuplicateOf  *uuid.UUID             `json:"duplicate_of,omitempty"`
 		Metadata     map[string]interface{} `json:"metadata"`
-		FetchedAt    string                 `json:"fetched_at"`
-		CreatedAt    string                 `json:"created_at"`
+		FetchedAt    model.Timestamp        `json:"fetched_at"`
+		CreatedAt    model.Timestamp        `json:"created_at"`
 	}
 
-	// 時刻をISO 8601形式の文字列に変換
+	// 時刻はUTCのオフセット付きで保存する
 	insertData := fetchedDataInsert{
 		ID:           data.ID,
 		ConfigID:     data.ConfigID,
@@ -51,22 +66,71 @@ func (r *SupabaseFetchedDataRepository) Create(ctx context.Context, data *model.
 		SourceItemID: data.SourceItemID,
 		Tags:         data.Tags,
 		MediaURLs:    data.MediaURLs,
+		ContentHTML:  data.ContentHTML,
+		ContentText:  data.ContentText,
+		Excerpt:      data.Excerpt,
+		Links:        data.Links,
+		Images:       data.Images,
+		CanonicalURL: data.CanonicalURL,
+		StoryID:      data.StoryID,
+		Fingerprint:  data.Fingerprint,
+		DuplicateOf:  data.DuplicateOf,
 		Metadata:     data.Metadata,
-		FetchedAt:    data.FetchedAt.Format("2006-01-02T15:04:05"),
-		CreatedAt:    data.CreatedAt.Format("2006-01-02T15:04:05"),
-	}
-
-	// PublishedAtがnilでない場合のみ変換
-	if data.PublishedAt != nil {
-		publishedAtStr := data.PublishedAt.Format("2006-01-02T15:04:05")
-		insertData.PublishedAt = &publishedAtStr
+		PublishedAt:  model.NewTimestampPtr(data.PublishedAt),
+		FetchedAt:    model.NewTimestamp(data.FetchedAt),
+		CreatedAt:    model.NewTimestamp(data.CreatedAt),
 	}
 
 	// Supabaseにデータを挿入
 	_, err := r.client.From("fetched_data").Insert(insertData, false, "", "", "").ExecuteTo(nil)
 	if err != nil {
-		return fmt.Errorf("failed to insert fetched data: %w", err)
+		return tracing.Fail(span, fmt.Errorf("failed to insert fetched data: %w", err))
 	}
 
 	return nil
 }
+
+// existingIDsChunk は1回の問い合わせで確認するIDの数。IDはURLのクエリに入るため長くなりすぎないようにする
+const existingIDsChunk = 100
+
+func (r *SupabaseFetchedDataRepository) ExistingIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	_, span := startSpan(ctx, "fetched_data", "select")
+	defer span.End()
+
+	existing := make(map[uuid.UUID]bool)
+	for _, chunk := range lo.Chunk(ids, existingIDsChunk) {
+		var rows []struct {
+			ID uuid.UUID `json:"id"`
+		}
+		values := lo.Map(chunk, func(id uuid.UUID, _ int) string { return id.String() })
+		if _, err := r.client.From("fetched_data").Select("id", "", false).In("id", values).ExecuteTo(&rows); err != nil {
+			return nil, tracing.Fail(span, fmt.Errorf("failed to look up stored items: %w", err))
+		}
+		for _, row := range rows {
+			existing[row.ID] = true
+		}
+	}
+	return existing, nil
+}
+
+// ListRecentFingerprints はユーザーの設定をたどる必要があるため recent_fingerprints 関数で取得する
+func (r *SupabaseFetchedDataRepository) ListRecentFingerprints(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.ItemFingerprint, error) {
+	var rows []struct {
+		ID          uuid.UUID       `json:"id"`
+		Fingerprint int64           `json:"fingerprint"`
+		FetchedAt   model.Timestamp `json:"fetched_at"`
+	}
+	err := callRPC(ctx, r.client, "recent_fingerprints", map[string]interface{}{
+		"p_user_id": userID,
+		"p_since":   model.NewTimestamp(since),
+	}, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent fingerprints: %w", err)
+	}
+
+	fingerprints := make([]model.ItemFingerprint, len(rows))
+	for i, row := range rows {
+		fingerprints[i] = model.ItemFingerprint{ID: row.ID, Fingerprint: row.Fingerprint, FetchedAt: row.FetchedAt.Time}
+	}
+	return fingerprints, nil
+}
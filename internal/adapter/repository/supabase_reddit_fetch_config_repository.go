@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/supabase-community/supabase-go"
 )
@@ -20,10 +22,80 @@ func NewSupabaseRedditFetchConfigRepository(client *supabase.Client) output.Redd
 }
 
 func (r *SupabaseRedditFetchConfigRepository) GetByUserFetchConfigID(ctx context.Context, userFetchConfigID uuid.UUID) (*model.RedditFetchConfigDetail, error) {
+	_, span := startSpan(ctx, "reddit_fetch_configs", "select")
+	defer span.End()
+
 	var config model.RedditFetchConfigDetail
 	_, err := r.client.From("reddit_fetch_configs").Select("*", "", false).Eq("user_fetch_config_id", userFetchConfigID.String()).Single().ExecuteTo(&config)
 	if err != nil {
-		return nil, err
+		return nil, tracing.Fail(span, err)
 	}
 	return &config, nil
 }
+
+// Supabaseに保存するための構造体（時刻フィールドを文字列に変換）
+type redditFetchConfigRow struct {
+	ID                uuid.UUID              `json:"id"`
+	UserFetchConfigID uuid.UUID              `json:"user_fetch_config_id"`
+	Subreddit         *string                `json:"subreddit"`
+	SortBy            model.RedditSort       `json:"sort_by"`
+	TimeFilter        model.RedditTimeFilter `json:"time_filter"`
+	LimitCount        int                    `json:"limit_count"`
+	Keywords          []string               `json:"keywords"`
+	CreatedAt         model.Timestamp        `json:"created_at"`
+}
+
+func toRedditFetchConfigRow(detail *model.RedditFetchConfigDetail) redditFetchConfigRow {
+	row := redditFetchConfigRow{
+		ID:                detail.ID,
+		UserFetchConfigID: detail.UserFetchConfigID,
+		SortBy:            detail.SortBy,
+		TimeFilter:        detail.TimeFilter,
+		LimitCount:        detail.LimitCount,
+		Keywords:          detail.Keywords,
+		CreatedAt:         model.NewTimestamp(detail.CreatedAt),
+	}
+	// サブレディット未指定（全体検索）はNULLとして保存する
+	if detail.Subreddit != "" {
+		row.Subreddit = &detail.Subreddit
+	}
+	if row.Keywords == nil {
+		row.Keywords = []string{}
+	}
+	return row
+}
+
+func (r *SupabaseRedditFetchConfigRepository) Create(ctx context.Context, detail *model.RedditFetchConfigDetail) error {
+	_, span := startSpan(ctx, "reddit_fetch_configs", "insert")
+	defer span.End()
+
+	_, err := r.client.From("reddit_fetch_configs").Insert(toRedditFetchConfigRow(detail), false, "", "", "").ExecuteTo(nil)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to insert reddit fetch config: %w", err))
+	}
+	return nil
+}
+
+func (r *SupabaseRedditFetchConfigRepository) Update(ctx context.Context, detail *model.RedditFetchConfigDetail) error {
+	_, span := startSpan(ctx, "reddit_fetch_configs", "update")
+	defer span.End()
+
+	row := toRedditFetchConfigRow(detail)
+	update := map[string]interface{}{
+		"subreddit":   row.Subreddit,
+		"sort_by":     row.SortBy,
+		"time_filter": row.TimeFilter,
+		"limit_count": row.LimitCount,
+		"keywords":    row.Keywords,
+	}
+
+	var updated []model.RedditFetchConfigDetail
+	_, err := r.client.From("reddit_fetch_configs").Update(update, "", "").Eq("user_fetch_config_id", detail.UserFetchConfigID.String()).ExecuteTo(&updated)
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("failed to update reddit fetch config: %w", err))
+	}
+	if len(updated) == 0 {
+		return tracing.Fail(span, fmt.Errorf("reddit fetch config for %s: %w", detail.UserFetchConfigID, output.ErrNotFound))
+	}
+	return nil
+}
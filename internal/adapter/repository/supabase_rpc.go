@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
+	"github.com/supabase-community/supabase-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rpcError はPostgRESTが関数呼び出しの失敗時に返すエラーレスポンス
+type rpcError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details"`
+	Hint    string `json:"hint"`
+}
+
+// startSpan はテーブルへの操作1回分のスパンを開始する
+func startSpan(ctx context.Context, table, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "db."+table+"."+operation,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBCollectionName(table),
+		semconv.DBOperationName(operation))
+}
+
+// callRPC はPostgres関数を呼び出し、結果をoutにデコードする。
+// supabase-goのRpcは通信エラーを返さないため、空のレスポンスは失敗として扱う
+func callRPC(ctx context.Context, client *supabase.Client, name string, params interface{}, out interface{}) error {
+	_, span := tracing.Start(ctx, "db.rpc."+name,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName(name))
+	defer span.End()
+
+	return tracing.Fail(span, doRPC(client, name, params, out))
+}
+
+func doRPC(client *supabase.Client, name string, params interface{}, out interface{}) error {
+	body := strings.TrimSpace(client.Rpc(name, "", params))
+	if body == "" {
+		return fmt.Errorf("rpc %s returned an empty response", name)
+	}
+
+	if strings.HasPrefix(body, "{") {
+		var rpcErr rpcError
+		if err := json.Unmarshal([]byte(body), &rpcErr); err == nil && rpcErr.Code != "" && rpcErr.Message != "" {
+			return fmt.Errorf("rpc %s failed (%s): %s", name, rpcErr.Code, rpcErr.Message)
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(body), out); err != nil {
+		return fmt.Errorf("failed to decode rpc %s response: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabaseRunLockRepository はrun_locksテーブルのリース行によるロック。
+// PostgRESTはリクエストごとに接続が変わるためアドバイザリーロックは保持できず、TTL付きの行で表現する
+type SupabaseRunLockRepository struct {
+	client *supabase.Client
+}
+
+func NewSupabaseRunLockRepository(client *supabase.Client) output.RunLockRepository {
+	return &SupabaseRunLockRepository{
+		client: client,
+	}
+}
+
+func (r *SupabaseRunLockRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (*model.RunLockAcquisition, error) {
+	var result model.RunLockAcquisition
+	err := callRPC(ctx, r.client, "acquire_run_lock", map[string]interface{}{
+		"p_name":        name,
+		"p_holder":      holder,
+		"p_ttl_seconds": int(ttl.Seconds()),
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire run lock %s: %w", name, err)
+	}
+	return &result, nil
+}
+
+func (r *SupabaseRunLockRepository) Refresh(ctx context.Context, name, holder string, ttl time.Duration) error {
+	var ok bool
+	err := callRPC(ctx, r.client, "refresh_run_lock", map[string]interface{}{
+		"p_name":        name,
+		"p_holder":      holder,
+		"p_ttl_seconds": int(ttl.Seconds()),
+	}, &ok)
+	if err != nil {
+		return fmt.Errorf("failed to refresh run lock %s: %w", name, err)
+	}
+	if !ok {
+		return fmt.Errorf("run lock %s is no longer held by %s: %w", name, holder, output.ErrLockNotHeld)
+	}
+	return nil
+}
+
+func (r *SupabaseRunLockRepository) Release(ctx context.Context, name, holder string) error {
+	var ok bool
+	err := callRPC(ctx, r.client, "release_run_lock", map[string]interface{}{
+		"p_name":   name,
+		"p_holder": holder,
+	}, &ok)
+	if err != nil {
+		return fmt.Errorf("failed to release run lock %s: %w", name, err)
+	}
+	if !ok {
+		return fmt.Errorf("run lock %s is no longer held by %s: %w", name, holder, output.ErrLockNotHeld)
+	}
+	return nil
+}
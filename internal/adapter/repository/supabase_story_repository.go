@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabaseStoryRepository はstoriesテーブルを link_story 関数で更新する。
+// 複数のワーカーが同じURLを同時に処理しても1つのストーリーになるよう、作成と追加を1回の呼び出しで行う
+type SupabaseStoryRepository struct {
+	client *supabase.Client
+}
+
+func NewSupabaseStoryRepository(client *supabase.Client) output.StoryRepository {
+	return &SupabaseStoryRepository{
+		client: client,
+	}
+}
+
+func (r *SupabaseStoryRepository) Link(ctx context.Context, userID uuid.UUID, canonicalURL, title string, discussion model.StoryDiscussion) (uuid.UUID, error) {
+	var storyID uuid.UUID
+	err := callRPC(ctx, r.client, "link_story", map[string]interface{}{
+		"p_user_id":       userID,
+		"p_canonical_url": canonicalURL,
+		"p_title":         title,
+		"p_discussion":    discussion,
+	}, &storyID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to link story %s: %w", canonicalURL, err)
+	}
+	return storyID, nil
+}
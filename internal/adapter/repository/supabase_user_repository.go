@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/supabase-community/supabase-go"
 )
 
@@ -20,16 +22,19 @@ func NewSupabaseUserRepository(client *supabase.Client) output.UserRepository {
 }
 
 func (r *SupabaseUserRepository) GetActiveUserIDs(ctx context.Context) ([]model.UserID, error) {
+	ctx, span := startSpan(ctx, "users", "select")
+	defer span.End()
+
 	var users []struct {
 		ID model.UserID `json:"id"`
 	}
 	_, err := r.client.From("users").Select("id", "", false).ExecuteTo(&users)
 	if err != nil {
-		return nil, fmt.Errorf("an error occurred during GetActiveUserIDs: %w", err)
+		return nil, tracing.Fail(span, fmt.Errorf("an error occurred during GetActiveUserIDs: %w", err))
 	}
 
 	if len(users) == 0 {
-		fmt.Println("no record found.")
+		slog.DebugContext(ctx, "No users found")
 		return nil, nil
 	}
 
@@ -0,0 +1,87 @@
+// Package urlresolver は短縮URLのリダイレクトをたどって元のURLを調べる
+package urlresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// maxRedirects はたどるリダイレクトの上限
+const maxRedirects = 5
+
+// HTTPResolver は短縮URLにリクエストを送り、リダイレクト先を返す。
+// 短縮URLでないものはそのまま返し、同じURLの結果はプロセス内で再利用する
+type HTTPResolver struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+func NewHTTPResolver(client *http.Client) output.URLResolver {
+	// 呼び出し元のクライアントの設定を変えないよう、リダイレクトの上限だけを差し替えた複製を使う
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &HTTPResolver{client: &c, resolved: make(map[string]string)}
+}
+
+func (r *HTTPResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	if !model.IsShortenerURL(rawURL) {
+		return rawURL, nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.resolved[rawURL]
+	r.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	// HEADを受け付けないサービスもあるため、失敗した場合はGETで再試行する
+	final, err := r.follow(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		final, err = r.follow(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rawURL, err)
+	}
+
+	r.mu.Lock()
+	r.resolved[rawURL] = final
+	r.mu.Unlock()
+	return final, nil
+}
+
+func (r *HTTPResolver) follow(ctx context.Context, method, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// 本文は不要。接続を再利用できるよう少しだけ読み捨てる
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if resp.Request == nil || resp.Request.URL == nil {
+		return "", errors.New("no final URL")
+	}
+	return resp.Request.URL.String(), nil
+}
@@ -0,0 +1,181 @@
+package urlresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpclient"
+)
+
+// shortener は短縮URLのサービスの代わりにリダイレクトを返す。
+// redirects にないURLへのリクエストは next に渡す
+type shortener struct {
+	next      http.RoundTripper
+	redirects map[string]string
+	// headStatus を指定するとHEADにこのステータスを返す
+	headStatus int
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (s *shortener) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, ok := s.redirects[req.URL.String()]
+	if !ok {
+		if s.next == nil {
+			return nil, fmt.Errorf("unexpected request to %s", req.URL)
+		}
+		return s.next.RoundTrip(req)
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.String())
+	s.mu.Unlock()
+
+	resp := &http.Response{
+		StatusCode: http.StatusMovedPermanently,
+		Header:     http.Header{"Location": {target}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	if req.Method == http.MethodHead && s.headStatus != 0 {
+		resp.StatusCode = s.headStatus
+		resp.Header = http.Header{}
+	}
+	return resp, nil
+}
+
+// final はリダイレクトの最後に到達するページ
+type final struct{}
+
+func (final) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+}
+
+func TestHTTPResolverResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		redirects  map[string]string
+		headStatus int
+		raw        string
+		want       string
+		// requests は短縮URLのサービスへのリクエスト
+		requests []string
+	}{
+		{
+			name:      "リダイレクト先を返す",
+			redirects: map[string]string{"https://bit.ly/a": "https://example.com/article"},
+			raw:       "https://bit.ly/a",
+			want:      "https://example.com/article",
+			requests:  []string{"HEAD https://bit.ly/a"},
+		},
+		{
+			name: "短縮URLが続く場合は最後までたどる",
+			redirects: map[string]string{
+				"https://t.co/a":   "https://bit.ly/b",
+				"https://bit.ly/b": "https://example.com/article",
+			},
+			raw:      "https://t.co/a",
+			want:     "https://example.com/article",
+			requests: []string{"HEAD https://t.co/a", "HEAD https://bit.ly/b"},
+		},
+		{
+			name:       "HEADを受け付けない場合はGETで再試行する",
+			redirects:  map[string]string{"https://bit.ly/a": "https://example.com/article"},
+			headStatus: http.StatusMethodNotAllowed,
+			raw:        "https://bit.ly/a",
+			want:       "https://example.com/article",
+			requests:   []string{"HEAD https://bit.ly/a", "GET https://bit.ly/a"},
+		},
+		{
+			name: "短縮URLでないものはリクエストしない",
+			raw:  "https://example.com/article",
+			want: "https://example.com/article",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &shortener{next: final{}, redirects: tt.redirects, headStatus: tt.headStatus}
+			r := NewHTTPResolver(&http.Client{Transport: s})
+
+			got, err := r.Resolve(context.Background(), tt.raw)
+			if err != nil {
+				t.Fatalf("Resolve(%s): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%s) = %s, want %s", tt.raw, got, tt.want)
+			}
+			if strings.Join(s.requests, ", ") != strings.Join(tt.requests, ", ") {
+				t.Errorf("requests = %v, want %v", s.requests, tt.requests)
+			}
+
+			// 同じURLの結果は再利用する
+			before := len(s.requests)
+			if again, err := r.Resolve(context.Background(), tt.raw); err != nil || again != got {
+				t.Errorf("second Resolve = %s, %v, want %s", again, err, got)
+			}
+			if len(s.requests) != before {
+				t.Errorf("second Resolve sent %d more requests", len(s.requests)-before)
+			}
+		})
+	}
+}
+
+func TestHTTPResolverStopsAfterMaxRedirects(t *testing.T) {
+	// 短縮URL同士が循環する
+	s := &shortener{redirects: map[string]string{
+		"https://bit.ly/a": "https://t.co/b",
+		"https://t.co/b":   "https://bit.ly/a",
+	}}
+	r := NewHTTPResolver(&http.Client{Transport: s})
+
+	if got, err := r.Resolve(context.Background(), "https://bit.ly/a"); err == nil {
+		t.Fatalf("Resolve = %s, want an error", got)
+	}
+	// HEADとGETのそれぞれで、上限の回数までしかリクエストしない
+	if want := 2 * maxRedirects; len(s.requests) != want {
+		t.Errorf("sent %d requests, want %d", len(s.requests), want)
+	}
+}
+
+// 短縮URLのリダイレクト先が内部のアドレスの場合は接続しない
+func TestHTTPResolverRejectsRedirectToNonPublicAddress(t *testing.T) {
+	var hits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(internal.Close)
+
+	factory, err := httpclient.NewFactory(httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := map[string]string{
+		"loopback":   internal.URL + "/admin",
+		"クラウドのメタデータ": "http://169.254.169.254/latest/meta-data/",
+	}
+	for name, target := range targets {
+		t.Run(name, func(t *testing.T) {
+			// 短縮URLのサービスだけを差し替え、リダイレクト先には公開アドレスに限るトランスポートで接続する
+			client := factory.PublicClient("urlresolver", func(next http.RoundTripper) http.RoundTripper {
+				return &shortener{next: next, redirects: map[string]string{"https://bit.ly/a": target}}
+			})
+			r := NewHTTPResolver(client)
+
+			got, err := r.Resolve(context.Background(), "https://bit.ly/a")
+			if !errors.Is(err, httpclient.ErrNonPublicAddress) {
+				t.Errorf("Resolve = %q, %v, want ErrNonPublicAddress", got, err)
+			}
+		})
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("internal server received %d requests", n)
+	}
+}
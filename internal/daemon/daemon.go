@@ -0,0 +1,239 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+)
+
+const (
+	defaultInterval     = time.Minute
+	defaultDrainTimeout = 5 * time.Minute
+)
+
+// Runner は1回分のバッチ処理（設定の再読み込みと実行時刻を迎えた設定の取得）を表す。
+// stopが閉じられた場合は処理中の設定を終えたところで残りの設定を処理せずに戻る
+type Runner interface {
+	ExecuteUntil(ctx context.Context, stop <-chan struct{}) (*model.FetchRunReport, error)
+}
+
+type Options struct {
+	// Interval は設定を再読み込みして実行対象を判定する間隔
+	Interval time.Duration
+	// DrainTimeout は停止要求後に実行中の取得の完了を待つ最大時間
+	DrainTimeout time.Duration
+}
+
+// Daemon は一定間隔でRunnerを実行し続ける常駐スケジューラ
+type Daemon struct {
+	runner       Runner
+	interval     time.Duration
+	drainTimeout time.Duration
+	locks        *service.RunLockService
+	lockTTL      time.Duration
+
+	mu          sync.RWMutex
+	startedAt   time.Time
+	running     bool
+	lastRunAt   *time.Time
+	lastRunTook time.Duration
+	lastOutcome model.FetchRunOutcome
+	lastErr     error
+	draining    bool
+}
+
+func New(runner Runner, opts Options) *Daemon {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
+
+	return &Daemon{
+		runner:       runner,
+		interval:     opts.Interval,
+		drainTimeout: opts.DrainTimeout,
+	}
+}
+
+// SetRunLock は実行ごとに feedle fetch と同じ実行ロックを取得するようにする。
+// 他のプロセス（手動の feedle fetch など）がロックを保持している間の実行はスキップする
+func (d *Daemon) SetRunLock(locks *service.RunLockService, ttl time.Duration) {
+	d.locks = locks
+	d.lockTTL = ttl
+}
+
+// Run はctxがキャンセルされるまでRunnerを定期実行する。
+// キャンセル後は実行中の処理をDrainTimeoutまで待ち、超過した場合は処理を中断させる
+func (d *Daemon) Run(ctx context.Context) error {
+	d.mu.Lock()
+	d.startedAt = time.Now()
+	d.mu.Unlock()
+
+	// 実行中の設定は停止シグナルで即座に中断させず、ドレイン時に個別にキャンセルする。
+	// 停止シグナル後は未着手の設定を処理しない
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRun()
+	stop := ctx.Done()
+
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.tryRun(runCtx, stop, &wg)
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.drain(&wg, cancelRun)
+			return nil
+		case <-ticker.C:
+			d.tryRun(runCtx, stop, &wg)
+		}
+	}
+}
+
+// tryRun は前回の処理が終わっていればRunnerを非同期に実行する
+func (d *Daemon) tryRun(ctx context.Context, stop <-chan struct{}, wg *sync.WaitGroup) {
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		slog.WarnContext(ctx, "Previous run is still in progress, skipping this tick")
+		return
+	}
+	d.running = true
+	d.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		start := time.Now()
+		report, err := d.execute(ctx, stop)
+		if errors.Is(err, service.ErrRunLocked) {
+			slog.InfoContext(ctx, "Run lock is held by another process, skipping this tick", "error", err)
+			d.mu.Lock()
+			d.running = false
+			d.mu.Unlock()
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Scheduled run failed", "error", err)
+		}
+
+		d.mu.Lock()
+		d.running = false
+		d.lastRunAt = &start
+		d.lastRunTook = time.Since(start)
+		d.lastOutcome = ""
+		if report != nil {
+			d.lastOutcome = report.Outcome
+		}
+		d.lastErr = err
+		d.mu.Unlock()
+	}()
+}
+
+// execute は実行ロックを取得してRunnerを実行する。ロックを失った場合は実行を中断させる
+func (d *Daemon) execute(ctx context.Context, stop <-chan struct{}) (*model.FetchRunReport, error) {
+	if d.locks == nil {
+		return d.runner.ExecuteUntil(ctx, stop)
+	}
+
+	lock, err := d.locks.Acquire(ctx, service.FetchRunLockName, service.RunLockOptions{TTL: d.lockTTL})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			slog.WarnContext(ctx, "Failed to release run lock", "error", err)
+		}
+	}()
+
+	runCtx, cancel := lock.Bind(ctx)
+	defer cancel()
+	report, err := d.runner.ExecuteUntil(runCtx, stop)
+	return report, errors.Join(err, lock.Err())
+}
+
+// drain は処理中の設定の完了を待つ。タイムアウトした場合は処理をキャンセルして終了を待つ
+func (d *Daemon) drain(wg *sync.WaitGroup, cancelRun context.CancelFunc) {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	slog.Info("Shutting down, waiting for in-flight fetches", "drain_timeout", d.drainTimeout)
+
+	select {
+	case <-done:
+		slog.Info("In-flight fetches drained")
+	case <-time.After(d.drainTimeout):
+		slog.Warn("Drain timeout exceeded, cancelling in-flight fetches")
+		cancelRun()
+		<-done
+	}
+}
+
+// HealthStatus はヘルスエンドポイントのレスポンス
+type HealthStatus struct {
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"started_at"`
+	Running     bool       `json:"running"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastRunTook string     `json:"last_run_took,omitempty"`
+	// LastOutcome は直近の実行結果（success, partial_failure, failure）
+	LastOutcome model.FetchRunOutcome `json:"last_outcome,omitempty"`
+	LastError   string                `json:"last_error,omitempty"`
+}
+
+func (d *Daemon) Health() HealthStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	status := HealthStatus{
+		Status:      "ok",
+		StartedAt:   d.startedAt,
+		Running:     d.running,
+		LastRunAt:   d.lastRunAt,
+		LastOutcome: d.lastOutcome,
+	}
+	if d.lastRunAt != nil {
+		status.LastRunTook = d.lastRunTook.String()
+	}
+	if d.lastErr != nil {
+		status.LastError = d.lastErr.Error()
+	}
+	if d.draining {
+		status.Status = "draining"
+	}
+	return status
+}
+
+// HealthHandler はデーモンの状態をJSONで返す。停止処理中は503を返す
+func (d *Daemon) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := d.Health()
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write health response", "error", err)
+		}
+	})
+}
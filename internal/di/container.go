@@ -1,13 +1,23 @@
 package di
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/article"
 	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher"
 	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher/reddit"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpcache"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpclient"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/normalizer"
 	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/repository"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/urlresolver"
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
 	"github.com/YamaguchiKoki/feedle_batch/internal/usecase"
 	"github.com/samber/do"
@@ -19,14 +29,19 @@ func NewContainer() (*do.Injector, error) {
 	injector := do.New()
 
 	// Register Supabase client
-	do.Provide(injector, func(i *do.Injector) (*supabase.Client, error) {
-		supabaseURL := viper.GetString("SUPABASE_URL")
-		supabaseKey := viper.GetString("SUPABASE_ANON_KEY")
-		if supabaseURL == "" || supabaseKey == "" {
-			return nil, fmt.Errorf("SUPABASE_URL and SUPABASE_ANON_KEY must be set")
-		}
+	do.Provide(injector, func(i *do.Injector) (repository.SupabaseAuthConfig, error) {
+		return repository.SupabaseAuthConfig{
+			URL:        viper.GetString("SUPABASE_URL"),
+			Mode:       repository.SupabaseAuthMode(viper.GetString("SUPABASE_AUTH_MODE")),
+			ServiceKey: viper.GetString("SUPABASE_SERVICE_KEY"),
+			AnonKey:    viper.GetString("SUPABASE_ANON_KEY"),
+			UserJWT:    viper.GetString("SUPABASE_USER_JWT"),
+		}, nil
+	})
 
-		return supabase.NewClient(supabaseURL, supabaseKey, nil)
+	do.Provide(injector, func(i *do.Injector) (*supabase.Client, error) {
+		cfg := do.MustInvoke[repository.SupabaseAuthConfig](i)
+		return repository.NewSupabaseClient(cfg)
 	})
 
 	// Register repositories
@@ -55,17 +70,156 @@ func NewContainer() (*do.Injector, error) {
 		return repository.NewSupabaseRedditFetchConfigRepository(client), nil
 	})
 
+	do.Provide(injector, func(i *do.Injector) (output.FetchJobQueue, error) {
+		client := do.MustInvoke[*supabase.Client](i)
+		return repository.NewSupabaseFetchJobQueue(client), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (output.FetchRunRepository, error) {
+		client := do.MustInvoke[*supabase.Client](i)
+		return repository.NewSupabaseFetchRunRepository(client), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (output.StoryRepository, error) {
+		client := do.MustInvoke[*supabase.Client](i)
+		return repository.NewSupabaseStoryRepository(client), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (output.RunLockRepository, error) {
+		client := do.MustInvoke[*supabase.Client](i)
+		return repository.NewSupabaseRunLockRepository(client), nil
+	})
+
 	// Register services
 	do.Provide(injector, func(i *do.Injector) (*service.FetchConfigService, error) {
 		userRepo := do.MustInvoke[output.UserRepository](i)
 		configRepo := do.MustInvoke[output.FetchConfigRepository](i)
 		redditConfigRepo := do.MustInvoke[output.RedditFetchConfigRepository](i)
 
-		return service.NewFetchConfigService(
+		svc := service.NewFetchConfigService(
 			userRepo,
 			configRepo,
 			redditConfigRepo,
-		), nil
+		)
+		if viper.IsSet("FETCH_SUSPEND_AFTER") {
+			svc.SetSuspendThreshold(viper.GetInt("FETCH_SUSPEND_AFTER"))
+		}
+		return svc, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*service.StoryService, error) {
+		storyRepo := do.MustInvoke[output.StoryRepository](i)
+
+		// 短縮URLの展開は外部への通信が発生するため無効にできる
+		var resolver output.URLResolver
+		if !viper.IsSet("STORY_RESOLVE_SHORT_URLS") || viper.GetBool("STORY_RESOLVE_SHORT_URLS") {
+			clients, err := do.Invoke[*httpclient.Factory](i)
+			if err != nil {
+				return nil, err
+			}
+			resolver = urlresolver.NewHTTPResolver(clients.PublicClient("urlresolver"))
+		}
+		return service.NewStoryService(storyRepo, resolver), nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (output.ContentNormalizer, error) {
+		n := normalizer.NewContentNormalizer()
+		if viper.IsSet("CONTENT_EXCERPT_LENGTH") {
+			n.SetExcerptLength(viper.GetInt("CONTENT_EXCERPT_LENGTH"))
+		}
+		return n, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*service.ArticleService, error) {
+		clients, err := do.Invoke[*httpclient.Factory](i)
+		if err != nil {
+			return nil, err
+		}
+		extractor := article.NewExtractor(clients.PublicClient("article"))
+		extractor.SetTimeout(viper.GetDuration("ARTICLE_TIMEOUT"))
+		extractor.SetMaxBytes(viper.GetInt64("ARTICLE_MAX_BYTES"))
+		extractor.SetMaxTextLength(viper.GetInt("ARTICLE_MAX_TEXT_LENGTH"))
+
+		svc := service.NewArticleService(extractor)
+		svc.SetConcurrency(viper.GetInt("ARTICLE_CONCURRENCY"))
+		return svc, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*service.DuplicateService, error) {
+		dataRepo := do.MustInvoke[output.FetchedDataRepository](i)
+		svc := service.NewDuplicateService(dataRepo)
+		if viper.IsSet("DUPLICATE_HAMMING_THRESHOLD") {
+			svc.SetThreshold(viper.GetInt("DUPLICATE_HAMMING_THRESHOLD"))
+		}
+		if viper.IsSet("DUPLICATE_WINDOW") {
+			svc.SetWindow(viper.GetDuration("DUPLICATE_WINDOW"))
+		}
+		return svc, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*service.RunLockService, error) {
+		lockRepo := do.MustInvoke[output.RunLockRepository](i)
+		return service.NewRunLockService(lockRepo), nil
+	})
+
+	// フェッチャーと認証で共有するHTTPクライアント
+	do.Provide(injector, func(i *do.Injector) (*httpclient.Factory, error) {
+		opts := httpclient.Options{
+			ProxyURL:              viper.GetString("HTTP_PROXY_URL"),
+			CABundle:              viper.GetString("HTTP_CA_BUNDLE"),
+			Timeout:               viper.GetDuration("HTTP_TIMEOUT"),
+			DialTimeout:           viper.GetDuration("HTTP_DIAL_TIMEOUT"),
+			TLSHandshakeTimeout:   viper.GetDuration("HTTP_TLS_HANDSHAKE_TIMEOUT"),
+			ResponseHeaderTimeout: viper.GetDuration("HTTP_RESPONSE_HEADER_TIMEOUT"),
+			IdleConnTimeout:       viper.GetDuration("HTTP_IDLE_CONN_TIMEOUT"),
+			MaxIdleConns:          viper.GetInt("HTTP_MAX_IDLE_CONNS"),
+			MaxIdleConnsPerHost:   viper.GetInt("HTTP_MAX_IDLE_CONNS_PER_HOST"),
+			MaxConnsPerHost:       viper.GetInt("HTTP_MAX_CONNS_PER_HOST"),
+			UserAgent:             viper.GetString("HTTP_USER_AGENT"),
+			SourceUserAgents:      make(map[string]string),
+			Usernames:             make(map[string]string),
+		}
+		for _, source := range httpSources {
+			key := strings.ToUpper(source)
+			if ua := viper.GetString("HTTP_USER_AGENT_" + key); ua != "" {
+				opts.SourceUserAgents[source] = ua
+			}
+			if name := viper.GetString(key + "_USERNAME"); name != "" {
+				opts.Usernames[source] = name
+			}
+		}
+		return httpclient.NewFactory(opts)
+	})
+
+	// HTTPキャッシュ。無効の場合はnil
+	do.Provide(injector, func(i *do.Injector) (*httpcache.Store, error) {
+		if viper.GetBool("HTTP_CACHE_DISABLED") {
+			return nil, nil
+		}
+		dir := viper.GetString("HTTP_CACHE_DIR")
+		if dir == "" {
+			var err error
+			if dir, err = httpcache.DefaultDir(); err != nil {
+				slog.Warn("No cache directory available, HTTP cache is disabled", "error", err)
+				return nil, nil
+			}
+		}
+		store, err := httpcache.NewStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		if viper.IsSet("HTTP_CACHE_MAX_AGE") {
+			store.SetMaxAge(viper.GetDuration("HTTP_CACHE_MAX_AGE"))
+		}
+		if viper.IsSet("HTTP_CACHE_MAX_BYTES") {
+			store.SetMaxBytes(viper.GetInt64("HTTP_CACHE_MAX_BYTES"))
+		}
+		if removed, err := store.Prune(time.Now()); err != nil {
+			slog.Warn("Failed to prune HTTP cache", "error", err)
+		} else if removed > 0 {
+			slog.Debug("Pruned HTTP cache", "removed", removed)
+		}
+		return store, nil
 	})
 
 	// Register fetchers
@@ -73,27 +227,144 @@ func NewContainer() (*do.Injector, error) {
 		redditClientID := viper.GetString("REDDIT_CLIENT_ID")
 		redditClientSecret := viper.GetString("REDDIT_CLIENT_SECRET")
 		redditUsername := viper.GetString("REDDIT_USERNAME")
+		// 検証用のサーバーなどに向ける場合に指定する。トークンも同じホストから発行する
+		redditBaseURL := strings.TrimRight(viper.GetString("REDDIT_BASE_URL"), "/")
 
-		auth := reddit.NewRedditAuth(redditClientID, redditClientSecret, redditUsername)
+		clients, err := do.Invoke[*httpclient.Factory](i)
+		if err != nil {
+			return nil, err
+		}
+		userAgent := clients.UserAgent("reddit")
 
-		return reddit.NewRedditFetcher(
-			"",
+		// 認証情報がない場合は公開APIを使う（レート制限が厳しいため動作確認用）
+		var auth *reddit.RedditAuth
+		if redditClientID != "" && redditClientSecret != "" {
+			auth = reddit.NewRedditAuth(redditClientID, redditClientSecret, redditUsername)
+			auth.SetUserAgent(userAgent)
+			auth.SetHTTPClient(clients.Client("reddit"))
+			if redditBaseURL != "" {
+				auth.SetTokenURL(redditBaseURL + "/api/v1/access_token")
+			}
+		} else {
+			slog.Warn("REDDIT_CLIENT_ID or REDDIT_CLIENT_SECRET is not set, using the unauthenticated Reddit API")
+		}
+
+		client := clients.Client("reddit",
+			func(next http.RoundTripper) http.RoundTripper { return metrics.InstrumentRoundTripper("reddit", next) },
+			func(next http.RoundTripper) http.RoundTripper { return cachedTransport(i, "reddit", next) },
+		)
+
+		redditFetcher := reddit.NewRedditFetcherWithClient(
+			userAgent,
 			auth,
-		), nil
+			client,
+		)
+		redditFetcher.SetBaseURL(redditBaseURL)
+		return redditFetcher, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*fetcher.Registry, error) {
+		redditFetcher, err := do.Invoke[fetcher.Fetcher[model.RedditFetchConfigDetail]](i)
+		if err != nil {
+			return nil, err
+		}
+
+		breakerOpts := fetcher.CircuitBreakerOptions{
+			FailureThreshold: viper.GetInt("CIRCUIT_BREAKER_THRESHOLD"),
+			Cooldown:         viper.GetDuration("CIRCUIT_BREAKER_COOLDOWN"),
+		}
+
+		registry := fetcher.NewRegistry()
+		registry.Register("reddit", fetcher.WithCircuitBreaker(fetcher.Adapt(redditFetcher), breakerOpts))
+		return registry, nil
 	})
 
 	// Register usecase
 	do.Provide(injector, func(i *do.Injector) (*usecase.FetchAndSaveUsecase, error) {
 		fetchConfigService := do.MustInvoke[*service.FetchConfigService](i)
 		dataRepo := do.MustInvoke[output.FetchedDataRepository](i)
-		redditFetcher := do.MustInvoke[fetcher.Fetcher[model.RedditFetchConfigDetail]](i)
+		runRepo := do.MustInvoke[output.FetchRunRepository](i)
+		fetchers := do.MustInvoke[*fetcher.Registry](i)
 
-		return usecase.NewFetchAndSaveUsecase(
+		uc := usecase.NewFetchAndSaveUsecase(
 			fetchConfigService,
 			dataRepo,
-			redditFetcher,
+			runRepo,
+			fetchers,
+		)
+		uc.SetNormalizer(do.MustInvoke[output.ContentNormalizer](i))
+		uc.SetStoryService(do.MustInvoke[*service.StoryService](i))
+		// リンク先のサイトへの通信が増えるため、明示的に有効にした場合だけ行う
+		if viper.GetBool("ARTICLE_EXTRACTION_ENABLED") {
+			uc.SetArticleService(do.MustInvoke[*service.ArticleService](i))
+		}
+		if !viper.IsSet("DUPLICATE_DETECTION_ENABLED") || viper.GetBool("DUPLICATE_DETECTION_ENABLED") {
+			uc.SetDuplicateService(do.MustInvoke[*service.DuplicateService](i))
+		}
+		return uc, nil
+	})
+
+	// アドホック取得はデータベースを使わない
+	do.Provide(injector, func(i *do.Injector) (*usecase.AdHocFetchUsecase, error) {
+		fetchers := do.MustInvoke[*fetcher.Registry](i)
+		uc := usecase.NewAdHocFetchUsecase(fetchers)
+		uc.SetNormalizer(do.MustInvoke[output.ContentNormalizer](i))
+		return uc, nil
+	})
+
+	do.Provide(injector, func(i *do.Injector) (*usecase.EnqueueDueConfigsUsecase, error) {
+		fetchConfigService := do.MustInvoke[*service.FetchConfigService](i)
+		queue := do.MustInvoke[output.FetchJobQueue](i)
+
+		return usecase.NewEnqueueDueConfigsUsecase(
+			fetchConfigService,
+			queue,
 		), nil
 	})
 
 	return injector, nil
 }
+
+// コマンドが必要とするSupabaseの権限
+const (
+	AccessUserScoped = repository.AccessUserScoped
+	AccessBatch      = repository.AccessBatch
+)
+
+// CheckSupabaseAccess はSupabaseの接続設定を検証し、コマンドに必要な権限があるかを確認する
+func CheckSupabaseAccess(ctx context.Context, injector *do.Injector, access repository.SupabaseAccess) error {
+	cfg := do.MustInvoke[repository.SupabaseAuthConfig](injector)
+	client, err := do.Invoke[*supabase.Client](injector)
+	if err != nil {
+		return err
+	}
+	return repository.CheckSupabaseAccess(ctx, client, cfg, access)
+}
+
+// CheckFetchers はフェッチャーを組み立て、HTTPクライアントの設定（プロキシ、CA証明書、User-Agent）の誤りを起動時に検出する
+func CheckFetchers(injector *do.Injector) error {
+	_, err := do.Invoke[*fetcher.Registry](injector)
+	return err
+}
+
+// httpSources はHTTPクライアントを使うデータソース。HTTP_USER_AGENT_<SOURCE> と <SOURCE>_USERNAME を読む
+var httpSources = []string{"reddit"}
+
+// cachedTransport はHTTPキャッシュが有効な場合にnextをキャッシュで包む。
+// 有効期限はデータソースごとに HTTP_CACHE_TTL_<SOURCE>、なければ HTTP_CACHE_TTL で指定する
+func cachedTransport(i *do.Injector, source string, next http.RoundTripper) http.RoundTripper {
+	store, err := do.Invoke[*httpcache.Store](i)
+	if err != nil {
+		// キャッシュが使えなくても取得はできるため、警告のみにする
+		slog.Warn("HTTP cache is unavailable, fetching without it", "source", source, "error", err)
+		return next
+	}
+	if store == nil {
+		return next
+	}
+	ttl := viper.GetDuration("HTTP_CACHE_TTL")
+	if key := "HTTP_CACHE_TTL_" + strings.ToUpper(source); viper.IsSet(key) {
+		ttl = viper.GetDuration(key)
+	}
+	return httpcache.NewTransport(source, store, ttl, next)
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// MetadataArticle はリンク先の記事を保存するメタデータのキー
+const MetadataArticle = "article"
+
+// Article はリンク投稿のリンク先のページから取り出した記事。
+// Title・Description・Image・SiteName はOpenGraph（なければ title 要素や meta description）から、
+// Text はページの本文と判断した部分のプレーンテキスト
+type Article struct {
+	URL         string     `json:"url"`
+	Title       string     `json:"title,omitempty"`
+	Byline      string     `json:"byline,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	SiteName    string     `json:"site_name,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Text        string     `json:"text,omitempty"`
+	WordCount   int        `json:"word_count"`
+	ExtractedAt time.Time  `json:"extracted_at"`
+}
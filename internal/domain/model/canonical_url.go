@@ -0,0 +1,156 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// trackingParams は内容に影響しない計測用のクエリパラメータ
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "_ga": true, "_gl": true,
+	"ref": true, "ref_src": true, "ref_url": true, "referrer": true,
+	"cmpid": true, "spm": true, "share": true, "si": true, "feature": true,
+}
+
+// trackingPrefixes はこの接頭辞で始まるパラメータを計測用とみなす（utm_source など）
+var trackingPrefixes = []string{"utm_", "hmb_", "oly_", "__s"}
+
+// hostPrefixes はモバイル版などの別名として取り除くホスト名の接頭辞
+var hostPrefixes = []string{"www.", "m.", "mobile.", "amp."}
+
+// hostAliases は同じサイトの別のホスト名
+var hostAliases = map[string]string{
+	"old.reddit.com": "reddit.com",
+	"new.reddit.com": "reddit.com",
+	"np.reddit.com":  "reddit.com",
+	"twitter.com":    "x.com",
+}
+
+// shortenerHosts はリダイレクトをたどらないと元のURLがわからない短縮URLのホスト
+var shortenerHosts = map[string]bool{
+	"bit.ly": true, "bitly.com": true, "t.co": true, "tinyurl.com": true, "goo.gl": true,
+	"ow.ly": true, "buff.ly": true, "dlvr.it": true, "lnkd.in": true, "is.gd": true,
+	"trib.al": true, "ift.tt": true, "shorturl.at": true, "rebrand.ly": true, "cutt.ly": true,
+}
+
+// CanonicalizeURL は同じ記事を指すURLが同じ文字列になるよう正規化する。
+// スキームはhttpsに揃え、ホスト名の別名と既定のポート、計測用のパラメータ、フラグメント、末尾のスラッシュを取り除く。
+// 短縮URLの展開は行わない（IsShortenerURL で判定し、リダイレクト先を正規化する）
+func CanonicalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("empty URL")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("URL %q has no host", raw)
+	}
+
+	host := canonicalHost(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	p := u.EscapedPath()
+	if p != "" {
+		p = path.Clean(p)
+	}
+	p = strings.TrimSuffix(p, "/")
+
+	query := u.Query()
+	switch host {
+	case "youtu.be":
+		// 共有用の短いURLは通常の動画ページにする
+		if id := strings.Trim(p, "/"); id != "" {
+			host, p = "youtube.com", "/watch"
+			query.Set("v", id)
+		}
+	case "redd.it":
+		if id := strings.Trim(p, "/"); id != "" {
+			host, p = "reddit.com", "/comments/"+id
+		}
+	}
+
+	return "https://" + host + p + canonicalQuery(query), nil
+}
+
+// IsShortenerURL は短縮URLのサービスのURLかを返す
+func IsShortenerURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return shortenerHosts[canonicalHost(u.Hostname())]
+}
+
+func canonicalHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, prefix := range hostPrefixes {
+		// "m.co" のような短いドメインそのものは変えない
+		if rest := strings.TrimPrefix(host, prefix); rest != host && strings.Contains(rest, ".") {
+			host = rest
+			break
+		}
+	}
+	if alias, ok := hostAliases[host]; ok {
+		host = alias
+	}
+	return host
+}
+
+// canonicalQuery は計測用のパラメータを除き、キーの順に並べたクエリを返す
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if isTrackingParam(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			if v != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(v))
+			}
+		}
+	}
+	return "?" + b.String()
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if trackingParams[name] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package model
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"フラグメントと末尾のスラッシュを取り除く", "https://example.com/a/b/#comments", "https://example.com/a/b"},
+		{"計測用のパラメータを取り除く", "https://example.com/a?utm_source=x&UTM_Medium=y&fbclid=z&ref=hn", "https://example.com/a"},
+		{"内容に関わるパラメータは並べ替えて残す", "https://example.com/a?page=2&id=1&utm_campaign=c", "https://example.com/a?id=1&page=2"},
+		{"同じキーの値も並べ替える", "https://example.com/a?t=b&t=a", "https://example.com/a?t=a&t=b"},
+		{"スキームをhttpsに揃える", "HTTP://Example.COM/a", "https://example.com/a"},
+		{"既定のポートを取り除く", "https://example.com:443/a", "https://example.com/a"},
+		{"既定でないポートは残す", "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"モバイル版のホスト名", "https://m.example.com/a", "https://example.com/a"},
+		{"短いドメインそのものは変えない", "https://m.co/a", "https://m.co/a"},
+		{"ホスト名の別名", "https://old.reddit.com/r/golang/comments/abc/title/", "https://reddit.com/r/golang/comments/abc/title"},
+		{"パスの . と .. を解決する", "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"YouTubeの共有用URL", "https://youtu.be/dQw4w9WgXcQ?si=share", "https://youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"redd.itの短いURL", "https://redd.it/abc123", "https://reddit.com/comments/abc123"},
+		{"前後の空白", "  https://example.com/a  ", "https://example.com/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeURL(tt.raw)
+			if err != nil {
+				t.Fatalf("CanonicalizeURL(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalizeURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeURLRejectsInvalidURLs(t *testing.T) {
+	for _, raw := range []string{"", "   ", "ftp://example.com/a", "javascript:alert(1)", "/relative/path", "https://"} {
+		if got, err := CanonicalizeURL(raw); err == nil {
+			t.Errorf("CanonicalizeURL(%q) = %q, want an error", raw, got)
+		}
+	}
+}
+
+func TestIsShortenerURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"https://bit.ly/3abc", true},
+		{"https://t.co/xyz", true},
+		{"http://WWW.TinyURL.com/abc", true},
+		{"https://example.com/bit.ly", false},
+		{"https://notbit.ly/abc", false},
+		{"https://youtu.be/abc", false},
+		{"%zz", false},
+	}
+	for _, tt := range tests {
+		if got := IsShortenerURL(tt.raw); got != tt.want {
+			t.Errorf("IsShortenerURL(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
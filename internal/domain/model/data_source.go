@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type DataSource struct {
 	ID        string    `json:"id" db:"id"`
@@ -9,3 +12,27 @@ type DataSource struct {
 	IsActive  bool      `json:"is_active" db:"is_active"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (d *DataSource) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Icon      string    `json:"icon"`
+		IsActive  bool      `json:"is_active"`
+		CreatedAt Timestamp `json:"created_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	d.ID = aux.ID
+	d.Name = aux.Name
+	d.Icon = aux.Icon
+	d.IsActive = aux.IsActive
+
+	d.CreatedAt = aux.CreatedAt.Time
+
+	return nil
+}
@@ -2,32 +2,69 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
 )
 
+// DefaultFetchSchedule はスケジュール未設定の設定に適用される実行間隔
+const DefaultFetchSchedule = "@daily"
+
+// ScheduleGrace は実行予定時刻より前でも実行済みとみなす猶予。
+// GitHub Actionsのcronは数分〜十数分遅れて起動するため、前回の実行が遅れると次の起動時刻が予定時刻の直前になり、
+// 猶予がないと1回分の実行が飛ばされる。実行間隔の半分を超える猶予は取らない
+const ScheduleGrace = 15 * time.Minute
+
+// scheduleParser は標準の5フィールドcron式と @every/@hourly 等の記述子を受け付ける
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 type UserFetchConfig struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	UserID       uuid.UUID `json:"user_id" db:"user_id"`
-	Name         string    `json:"name" db:"name"`
-	DataSourceID string    `json:"data_source_id" db:"data_source_id"`
-	IsActive     bool      `json:"is_active" db:"is_active"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID  `json:"id" db:"id"`
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	Name          string     `json:"name" db:"name"`
+	DataSourceID  string     `json:"data_source_id" db:"data_source_id"`
+	IsActive      bool       `json:"is_active" db:"is_active"`
+	Schedule      string     `json:"schedule" db:"schedule"`
+	LastFetchedAt *time.Time `json:"last_fetched_at" db:"last_fetched_at"`
+	FetchFailure
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FetchFailure は設定の連続失敗の状況。恒久的な失敗が続いた設定は自動的に停止される
+type FetchFailure struct {
+	ConsecutiveFailures int             `json:"consecutive_failures" db:"consecutive_failures"`
+	LastErrorKind       *FetchErrorKind `json:"last_error_kind" db:"last_error_kind"`
+	LastError           *string         `json:"last_error" db:"last_error"`
+	SuspendedAt         *time.Time      `json:"suspended_at" db:"suspended_at"`
+	SuspendedReason     *string         `json:"suspended_reason" db:"suspended_reason"`
+}
+
+// IsSuspended は連続失敗により自動停止されているかを返す
+func (f FetchFailure) IsSuspended() bool {
+	return f.SuspendedAt != nil
 }
 
 // UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
 func (u *UserFetchConfig) UnmarshalJSON(data []byte) error {
-	// Temporary struct with string timestamps
 	aux := &struct {
-		ID           uuid.UUID `json:"id"`
-		UserID       uuid.UUID `json:"user_id"`
-		Name         string    `json:"name"`
-		DataSourceID string    `json:"data_source_id"`
-		IsActive     bool      `json:"is_active"`
-		CreatedAt    string    `json:"created_at"`
-		UpdatedAt    string    `json:"updated_at"`
+		ID            uuid.UUID  `json:"id"`
+		UserID        uuid.UUID  `json:"user_id"`
+		Name          string     `json:"name"`
+		DataSourceID  string     `json:"data_source_id"`
+		IsActive      bool       `json:"is_active"`
+		Schedule      string     `json:"schedule"`
+		LastFetchedAt *Timestamp `json:"last_fetched_at"`
+		CreatedAt     Timestamp  `json:"created_at"`
+		UpdatedAt     Timestamp  `json:"updated_at"`
+
+		ConsecutiveFailures int             `json:"consecutive_failures"`
+		LastErrorKind       *FetchErrorKind `json:"last_error_kind"`
+		LastError           *string         `json:"last_error"`
+		SuspendedAt         *Timestamp      `json:"suspended_at"`
+		SuspendedReason     *string         `json:"suspended_reason"`
 	}{}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -39,23 +76,16 @@ func (u *UserFetchConfig) UnmarshalJSON(data []byte) error {
 	u.Name = aux.Name
 	u.DataSourceID = aux.DataSourceID
 	u.IsActive = aux.IsActive
+	u.Schedule = aux.Schedule
+	u.ConsecutiveFailures = aux.ConsecutiveFailures
+	u.LastErrorKind = aux.LastErrorKind
+	u.LastError = aux.LastError
+	u.SuspendedReason = aux.SuspendedReason
 
-	// Parse timestamps without timezone (take first 19 chars)
-	if len(aux.CreatedAt) >= 19 {
-		t, err := time.Parse("2006-01-02T15:04:05", aux.CreatedAt[:19])
-		if err != nil {
-			return err
-		}
-		u.CreatedAt = t
-	}
-
-	if len(aux.UpdatedAt) >= 19 {
-		t, err := time.Parse("2006-01-02T15:04:05", aux.UpdatedAt[:19])
-		if err != nil {
-			return err
-		}
-		u.UpdatedAt = t
-	}
+	u.CreatedAt = aux.CreatedAt.Time
+	u.UpdatedAt = aux.UpdatedAt.Time
+	u.LastFetchedAt = aux.LastFetchedAt.TimePtr()
+	u.SuspendedAt = aux.SuspendedAt.TimePtr()
 
 	return nil
 }
@@ -68,7 +98,66 @@ func NewUserFetchConfig(userID uuid.UUID, name string, dataSourceID string) *Use
 		Name:         name,
 		DataSourceID: dataSourceID,
 		IsActive:     true,
+		Schedule:     DefaultFetchSchedule,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
 }
+
+// ParseFetchSchedule はcron式（"0 * * * *"）または間隔指定（"@every 1h", "@weekly"）を解釈する
+// @every の予定時刻は前回の取得時刻からではなく、間隔の倍数の時刻（"@every 6h" ならUTCの0時・6時・12時・18時）とする
+func ParseFetchSchedule(spec string) (cron.Schedule, error) {
+	if spec == "" {
+		spec = DefaultFetchSchedule
+	}
+	schedule, err := scheduleParser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// NextRunAt は最後に成功した取得時刻から次回の実行予定時刻を求める。未実行の場合はnilを返す
+func (u UserFetchConfig) NextRunAt() (*time.Time, error) {
+	if u.LastFetchedAt == nil {
+		return nil, nil
+	}
+	schedule, err := ParseFetchSchedule(u.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	next, _ := nextSlot(schedule, *u.LastFetchedAt)
+	return &next, nil
+}
+
+// IsDue は指定時刻において取得を実行すべきかを判定する。実行予定時刻の ScheduleGrace 前から実行する
+func (u UserFetchConfig) IsDue(now time.Time) (bool, error) {
+	schedule, err := ParseFetchSchedule(u.Schedule)
+	if err != nil {
+		return false, err
+	}
+	if u.LastFetchedAt == nil {
+		return true, nil
+	}
+	next, grace := nextSlot(schedule, *u.LastFetchedAt)
+	return !now.Before(next.Add(-grace)), nil
+}
+
+// nextSlot は最後の取得がまだ済ませていない最初の実行予定時刻と、その猶予を返す。
+// 取得は予定時刻の猶予の間に行えばその回を済ませたものとし、猶予の中で取得した場合も同じ予定時刻を再び実行しない。
+// 予定時刻を最後の取得時刻からの相対で決めると、猶予の分だけ間隔が縮み続けるため、予定時刻は取得時刻によらず固定する
+func nextSlot(schedule cron.Schedule, last time.Time) (time.Time, time.Duration) {
+	next := scheduledAfter(schedule, last)
+	grace := min(ScheduleGrace, scheduledAfter(schedule, next).Sub(next)/2)
+	// last が next の猶予の中にあれば next は済んでいる
+	return scheduledAfter(schedule, last.Add(grace)), grace
+}
+
+// scheduledAfter はtより後の最初の実行予定時刻を返す。
+// @every の間隔は前回の時刻からの相対になるため、ゼロ時刻から数えた間隔の倍数の時刻を予定時刻とする
+func scheduledAfter(schedule cron.Schedule, t time.Time) time.Time {
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return t.Truncate(every.Delay).Add(every.Delay)
+	}
+	return schedule.Next(t)
+}
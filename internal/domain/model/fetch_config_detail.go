@@ -2,7 +2,11 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -10,31 +14,74 @@ import (
 type FetchConfigDetail interface {
 	GetUserFetchConfigID() uuid.UUID
 	GetDataSourceID() string
+	// Validate は取得前に検出できる設定の誤りを返す。複数ある場合はerrors.Joinでまとめる
+	Validate() error
 }
 
+// RedditSort は並び順。キーワード検索とサブレディットの一覧取得で使える値が異なる
+type RedditSort string
+
+const (
+	RedditSortRelevance     RedditSort = "relevance"
+	RedditSortHot           RedditSort = "hot"
+	RedditSortNew           RedditSort = "new"
+	RedditSortTop           RedditSort = "top"
+	RedditSortRising        RedditSort = "rising"
+	RedditSortControversial RedditSort = "controversial"
+	RedditSortComments      RedditSort = "comments"
+)
+
+var (
+	redditSearchSorts  = []RedditSort{RedditSortRelevance, RedditSortHot, RedditSortTop, RedditSortNew, RedditSortComments}
+	redditListingSorts = []RedditSort{RedditSortHot, RedditSortNew, RedditSortTop, RedditSortRising, RedditSortControversial}
+)
+
+// RedditTimeFilter は top/controversial で対象とする期間
+type RedditTimeFilter string
+
+const (
+	RedditTimeHour  RedditTimeFilter = "hour"
+	RedditTimeDay   RedditTimeFilter = "day"
+	RedditTimeWeek  RedditTimeFilter = "week"
+	RedditTimeMonth RedditTimeFilter = "month"
+	RedditTimeYear  RedditTimeFilter = "year"
+	RedditTimeAll   RedditTimeFilter = "all"
+)
+
+var redditTimeFilters = []RedditTimeFilter{RedditTimeHour, RedditTimeDay, RedditTimeWeek, RedditTimeMonth, RedditTimeYear, RedditTimeAll}
+
+// Reddit APIの制約に合わせた上限
+const (
+	RedditMaxLimit         = 100
+	RedditMaxKeywords      = 10
+	RedditMaxKeywordLength = 256
+)
+
+// サブレディット名は英数字とアンダースコアで3〜21文字（先頭はアンダースコア不可）
+var subredditNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{2,20}$`)
+
 type RedditFetchConfigDetail struct {
-	ID                uuid.UUID `json:"id" db:"id"`
-	UserFetchConfigID uuid.UUID `json:"user_fetch_config_id" db:"user_fetch_config_id"`
-	Subreddit         string    `json:"subreddit" db:"subreddit"`
-	SortBy            string    `json:"sort_by" db:"sort_by"`
-	TimeFilter        string    `json:"time_filter" db:"time_filter"`
-	LimitCount        int       `json:"limit_count" db:"limit_count"`
-	Keywords          []string  `json:"keywords" db:"keywords"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	ID                uuid.UUID        `json:"id" db:"id"`
+	UserFetchConfigID uuid.UUID        `json:"user_fetch_config_id" db:"user_fetch_config_id"`
+	Subreddit         string           `json:"subreddit" db:"subreddit"`
+	SortBy            RedditSort       `json:"sort_by" db:"sort_by"`
+	TimeFilter        RedditTimeFilter `json:"time_filter" db:"time_filter"`
+	LimitCount        int              `json:"limit_count" db:"limit_count"`
+	Keywords          []string         `json:"keywords" db:"keywords"`
+	CreatedAt         time.Time        `json:"created_at" db:"created_at"`
 }
 
 // UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
 func (r *RedditFetchConfigDetail) UnmarshalJSON(data []byte) error {
-	// Temporary struct with string timestamp
 	aux := &struct {
-		ID                uuid.UUID `json:"id"`
-		UserFetchConfigID uuid.UUID `json:"user_fetch_config_id"`
-		Subreddit         string    `json:"subreddit"`
-		SortBy            string    `json:"sort_by"`
-		TimeFilter        string    `json:"time_filter"`
-		LimitCount        int       `json:"limit_count"`
-		Keywords          []string  `json:"keywords"`
-		CreatedAt         string    `json:"created_at"`
+		ID                uuid.UUID        `json:"id"`
+		UserFetchConfigID uuid.UUID        `json:"user_fetch_config_id"`
+		Subreddit         string           `json:"subreddit"`
+		SortBy            RedditSort       `json:"sort_by"`
+		TimeFilter        RedditTimeFilter `json:"time_filter"`
+		LimitCount        int              `json:"limit_count"`
+		Keywords          []string         `json:"keywords"`
+		CreatedAt         Timestamp        `json:"created_at"`
 	}{}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -48,19 +95,23 @@ func (r *RedditFetchConfigDetail) UnmarshalJSON(data []byte) error {
 	r.TimeFilter = aux.TimeFilter
 	r.LimitCount = aux.LimitCount
 	r.Keywords = aux.Keywords
-
-	// Parse timestamp without timezone (take first 19 chars)
-	if len(aux.CreatedAt) >= 19 {
-		t, err := time.Parse("2006-01-02T15:04:05", aux.CreatedAt[:19])
-		if err != nil {
-			return err
-		}
-		r.CreatedAt = t
-	}
+	r.CreatedAt = aux.CreatedAt.Time
 
 	return nil
 }
 
+// NewRedditFetchConfigDetail はテーブルの既定値と同じ値で検索条件を作る
+func NewRedditFetchConfigDetail(userFetchConfigID uuid.UUID) *RedditFetchConfigDetail {
+	return &RedditFetchConfigDetail{
+		ID:                uuid.New(),
+		UserFetchConfigID: userFetchConfigID,
+		SortBy:            RedditSortHot,
+		TimeFilter:        RedditTimeDay,
+		LimitCount:        25,
+		CreatedAt:         time.Now(),
+	}
+}
+
 func (r RedditFetchConfigDetail) GetUserFetchConfigID() uuid.UUID {
 	return r.UserFetchConfigID
 }
@@ -68,3 +119,75 @@ func (r RedditFetchConfigDetail) GetUserFetchConfigID() uuid.UUID {
 func (r RedditFetchConfigDetail) GetDataSourceID() string {
 	return "reddit"
 }
+
+// Validate はサブレディット名・並び順・期間・取得件数・キーワードを検証する。
+// キーワードがある場合は検索、ない場合はサブレディットの一覧取得として並び順を判定する
+func (r RedditFetchConfigDetail) Validate() error {
+	var errs []error
+
+	if r.Subreddit == "" && len(r.Keywords) == 0 {
+		errs = append(errs, newValidationError("subreddit", "subreddit or keywords is required"))
+	}
+	if r.Subreddit != "" {
+		// "golang+rust" のような複数指定も受け付ける
+		for _, name := range strings.Split(r.Subreddit, "+") {
+			if !subredditNamePattern.MatchString(name) {
+				errs = append(errs, newValidationError("subreddit",
+					"%q is not a valid subreddit name (3-21 letters, digits or underscores, without the r/ prefix)", name))
+			}
+		}
+	}
+
+	if r.SortBy != "" {
+		allowed := redditListingSorts
+		mode := "subreddit listings"
+		if len(r.Keywords) > 0 {
+			allowed = redditSearchSorts
+			mode = "keyword search"
+		}
+		if !containsValue(allowed, r.SortBy) {
+			errs = append(errs, newValidationError("sort_by", "%q is not supported for %s (expected one of %s)",
+				r.SortBy, mode, joinValues(allowed)))
+		}
+	}
+
+	if r.TimeFilter != "" && !containsValue(redditTimeFilters, r.TimeFilter) {
+		errs = append(errs, newValidationError("time_filter", "%q is not supported (expected one of %s)",
+			r.TimeFilter, joinValues(redditTimeFilters)))
+	}
+
+	if r.LimitCount < 1 || r.LimitCount > RedditMaxLimit {
+		errs = append(errs, newValidationError("limit_count", "must be between 1 and %d (got %d)", RedditMaxLimit, r.LimitCount))
+	}
+
+	if len(r.Keywords) > RedditMaxKeywords {
+		errs = append(errs, newValidationError("keywords", "at most %d keywords are allowed (got %d)", RedditMaxKeywords, len(r.Keywords)))
+	}
+	for i, keyword := range r.Keywords {
+		switch n := utf8.RuneCountInString(strings.TrimSpace(keyword)); {
+		case n == 0:
+			errs = append(errs, newValidationError("keywords", "keyword #%d is empty", i+1))
+		case n > RedditMaxKeywordLength:
+			errs = append(errs, newValidationError("keywords", "keyword #%d is longer than %d characters", i+1, RedditMaxKeywordLength))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func containsValue[T ~string](values []T, v T) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func joinValues[T ~string](values []T) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = string(v)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserFetchConfigIsDue(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		last     string
+		now      string
+		want     bool
+	}{
+		{name: "未実行", schedule: "@hourly", now: "2026-01-01T10:00:00Z", want: true},
+		{name: "遅れて起動した次の回も実行する", schedule: "@every 1h", last: "2026-01-01T10:12:00Z", now: "2026-01-01T11:03:00Z", want: true},
+		{name: "猶予より前は実行しない", schedule: "@every 1h", last: "2026-01-01T10:12:00Z", now: "2026-01-01T10:40:00Z", want: false},
+		{name: "cron式の予定時刻の直前", schedule: "0 * * * *", last: "2026-01-01T10:12:00Z", now: "2026-01-01T10:50:00Z", want: true},
+		{name: "cron式の予定時刻より十分前", schedule: "0 * * * *", last: "2026-01-01T10:12:00Z", now: "2026-01-01T10:40:00Z", want: false},
+		{name: "短い間隔では間隔の半分まで", schedule: "*/10 * * * *", last: "2026-01-01T10:12:00Z", now: "2026-01-01T10:14:00Z", want: false},
+		{name: "短い間隔の猶予内", schedule: "*/10 * * * *", last: "2026-01-01T10:12:00Z", now: "2026-01-01T10:16:00Z", want: true},
+		{name: "日次", schedule: "@daily", last: "2026-01-01T00:20:00Z", now: "2026-01-01T23:50:00Z", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := UserFetchConfig{Schedule: tt.schedule}
+			if tt.last != "" {
+				last := at(tt.last)
+				cfg.LastFetchedAt = &last
+			}
+			got, err := cfg.IsDue(at(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 起動の間隔ごとに1日分時刻を進め、実行した回数を数える。猶予の中で実行した回を次の起動で再び実行しない
+func TestUserFetchConfigIsDueOverADay(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		// tick は起動の間隔、offset は毎回の起動の遅れ、previous は開始時刻の何前に最後の取得をしたか
+		tick     time.Duration
+		offset   time.Duration
+		previous time.Duration
+		want     int
+	}{
+		{"毎時のcron式を毎分確認", "0 * * * *", time.Minute, 0, time.Minute, 24},
+		{"日次を毎分確認", "@daily", time.Minute, 0, time.Minute, 1},
+		{"1時間間隔を毎分確認", "@every 1h", time.Minute, 0, time.Minute, 24},
+		{"6時間間隔を毎分確認", "@every 6h", time.Minute, 0, time.Minute, 4},
+		{"10分ごとを毎分確認", "*/10 * * * *", time.Minute, 0, time.Minute, 144},
+		{"毎時のcron式を遅れて起動する毎時の実行で確認", "0 * * * *", time.Hour, 12 * time.Minute, 48 * time.Minute, 24},
+		{"1時間間隔を遅れて起動する毎時の実行で確認", "@every 1h", time.Hour, 12 * time.Minute, 48 * time.Minute, 24},
+		{"日次を遅れて起動する毎時の実行で確認", "@daily", time.Hour, 3 * time.Minute, 24*time.Hour - 3*time.Minute, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := start.Add(-tt.previous)
+			cfg := UserFetchConfig{Schedule: tt.schedule, LastFetchedAt: &last}
+
+			runs := 0
+			for now := start.Add(tt.offset); now.Before(start.Add(24 * time.Hour)); now = now.Add(tt.tick) {
+				due, err := cfg.IsDue(now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if due {
+					runs++
+					fetchedAt := now
+					cfg.LastFetchedAt = &fetchedAt
+				}
+			}
+			if runs != tt.want {
+				t.Errorf("ran %d times in a day, want %d", runs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FetchErrorKind は取得失敗の種類。設定の自動停止や再試行の判断に使う
+type FetchErrorKind string
+
+const (
+	// FetchErrorKindNotFound は対象（サブレディット等）が存在しない
+	FetchErrorKindNotFound FetchErrorKind = "not_found"
+	// FetchErrorKindForbidden はアクセスを拒否された。対象が非公開・BANされている場合のほか、
+	// データソース全体のブロックやAPIキーの失効でも返るため、設定固有の失敗とはみなさない
+	FetchErrorKindForbidden FetchErrorKind = "forbidden"
+	// FetchErrorKindRateLimited はAPIのレート制限に達した
+	FetchErrorKindRateLimited FetchErrorKind = "rate_limited"
+	// FetchErrorKindTransient は時間をおけば解消する可能性がある失敗
+	FetchErrorKindTransient FetchErrorKind = "transient"
+)
+
+// IsPermanent は再試行しても成功する見込みがない（設定の修正が必要な）種類かを返す。
+// 対象そのものが存在しない場合（404/410）だけが該当する
+func (k FetchErrorKind) IsPermanent() bool {
+	return k == FetchErrorKindNotFound
+}
+
+// FetchError はFetcherが返す分類済みのエラー
+type FetchError struct {
+	Kind       FetchErrorKind
+	StatusCode int
+	Message    string
+}
+
+func (e *FetchError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (status %d): %s", e.Kind, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// NewFetchErrorFromStatus はHTTPステータスコードから分類済みのエラーを作る
+func NewFetchErrorFromStatus(statusCode int, message string) *FetchError {
+	kind := FetchErrorKindTransient
+	switch statusCode {
+	case http.StatusNotFound, http.StatusGone:
+		kind = FetchErrorKindNotFound
+	case http.StatusForbidden:
+		kind = FetchErrorKindForbidden
+	case http.StatusTooManyRequests:
+		kind = FetchErrorKindRateLimited
+	}
+
+	return &FetchError{
+		Kind:       kind,
+		StatusCode: statusCode,
+		Message:    message,
+	}
+}
+
+// ClassifyFetchError はエラーの種類を判定する。分類されていないエラーは一時的な失敗として扱う
+func ClassifyFetchError(err error) FetchErrorKind {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr.Kind
+	}
+	return FetchErrorKindTransient
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FetchJobStatus string
+
+const (
+	FetchJobStatusQueued    FetchJobStatus = "queued"
+	FetchJobStatusRunning   FetchJobStatus = "running"
+	FetchJobStatusSucceeded FetchJobStatus = "succeeded"
+	FetchJobStatusDead      FetchJobStatus = "dead"
+)
+
+// FetchJob はワーカーが取得処理を行う単位となるキュー上のジョブ
+type FetchJob struct {
+	ID             uuid.UUID      `json:"id" db:"id"`
+	ConfigID       uuid.UUID      `json:"config_id" db:"config_id"`
+	Status         FetchJobStatus `json:"status" db:"status"`
+	Attempts       int            `json:"attempts" db:"attempts"`
+	MaxAttempts    int            `json:"max_attempts" db:"max_attempts"`
+	RunAfter       time.Time      `json:"run_after" db:"run_after"`
+	LeasedBy       *string        `json:"leased_by" db:"leased_by"`
+	LeaseExpiresAt *time.Time     `json:"lease_expires_at" db:"lease_expires_at"`
+	LastError      *string        `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (j *FetchJob) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		ID             uuid.UUID      `json:"id"`
+		ConfigID       uuid.UUID      `json:"config_id"`
+		Status         FetchJobStatus `json:"status"`
+		Attempts       int            `json:"attempts"`
+		MaxAttempts    int            `json:"max_attempts"`
+		RunAfter       Timestamp      `json:"run_after"`
+		LeasedBy       *string        `json:"leased_by"`
+		LeaseExpiresAt *Timestamp     `json:"lease_expires_at"`
+		LastError      *string        `json:"last_error"`
+		CreatedAt      Timestamp      `json:"created_at"`
+		UpdatedAt      Timestamp      `json:"updated_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	j.ID = aux.ID
+	j.ConfigID = aux.ConfigID
+	j.Status = aux.Status
+	j.Attempts = aux.Attempts
+	j.MaxAttempts = aux.MaxAttempts
+	j.LeasedBy = aux.LeasedBy
+	j.LastError = aux.LastError
+
+	j.RunAfter = aux.RunAfter.Time
+	j.LeaseExpiresAt = aux.LeaseExpiresAt.TimePtr()
+	j.CreatedAt = aux.CreatedAt.Time
+	j.UpdatedAt = aux.UpdatedAt.Time
+
+	return nil
+}
+
+// IsLastAttempt は失敗した場合に再試行されずdeadになるかを返す
+func (j FetchJob) IsLastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
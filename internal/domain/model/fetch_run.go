@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FetchRunStatus string
+
+const (
+	FetchRunStatusRunning   FetchRunStatus = "running"
+	FetchRunStatusCompleted FetchRunStatus = "completed"
+	FetchRunStatusFailed    FetchRunStatus = "failed"
+	// FetchRunStatusInterrupted は停止の要求やキャンセルにより途中で終了した。残りの設定は Resume で再開できる
+	FetchRunStatusInterrupted FetchRunStatus = "interrupted"
+)
+
+type FetchRunItemStatus string
+
+const (
+	FetchRunItemStatusPending   FetchRunItemStatus = "pending"
+	FetchRunItemStatusSucceeded FetchRunItemStatus = "succeeded"
+	FetchRunItemStatusFailed    FetchRunItemStatus = "failed"
+	// FetchRunItemStatusSkipped はデータソースの障害（サーキットブレーカー）により取得しなかった
+	FetchRunItemStatusSkipped FetchRunItemStatus = "skipped"
+)
+
+// FetchRun は feedle fetch の1回の実行
+type FetchRun struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	Status     FetchRunStatus `json:"status" db:"status"`
+	StartedAt  time.Time      `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at" db:"finished_at"`
+}
+
+// FetchRunItem は実行中の1つの設定の進捗。中断した実行はsucceeded以外の項目から再開する
+type FetchRunItem struct {
+	RunID      uuid.UUID          `json:"run_id" db:"run_id"`
+	ConfigID   uuid.UUID          `json:"config_id" db:"config_id"`
+	Status     FetchRunItemStatus `json:"status" db:"status"`
+	ItemsSaved int                `json:"items_saved" db:"items_saved"`
+	Error      *string            `json:"error" db:"error"`
+	StartedAt  *time.Time         `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time         `json:"finished_at" db:"finished_at"`
+}
+
+func NewFetchRun(startedAt time.Time) *FetchRun {
+	return &FetchRun{
+		ID:        uuid.New(),
+		Status:    FetchRunStatusRunning,
+		StartedAt: startedAt,
+	}
+}
+
+// IsCompleted は再開時に処理を省略してよいかを返す
+func (i FetchRunItem) IsCompleted() bool {
+	return i.Status == FetchRunItemStatusSucceeded
+}
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (r *FetchRun) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		ID         uuid.UUID      `json:"id"`
+		Status     FetchRunStatus `json:"status"`
+		StartedAt  Timestamp      `json:"started_at"`
+		FinishedAt *Timestamp     `json:"finished_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.ID = aux.ID
+	r.Status = aux.Status
+
+	r.StartedAt = aux.StartedAt.Time
+	r.FinishedAt = aux.FinishedAt.TimePtr()
+
+	return nil
+}
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (i *FetchRunItem) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		RunID      uuid.UUID          `json:"run_id"`
+		ConfigID   uuid.UUID          `json:"config_id"`
+		Status     FetchRunItemStatus `json:"status"`
+		ItemsSaved int                `json:"items_saved"`
+		Error      *string            `json:"error"`
+		StartedAt  *Timestamp         `json:"started_at"`
+		FinishedAt *Timestamp         `json:"finished_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	i.RunID = aux.RunID
+	i.ConfigID = aux.ConfigID
+	i.Status = aux.Status
+	i.ItemsSaved = aux.ItemsSaved
+	i.Error = aux.Error
+
+	i.StartedAt = aux.StartedAt.TimePtr()
+	i.FinishedAt = aux.FinishedAt.TimePtr()
+
+	return nil
+}
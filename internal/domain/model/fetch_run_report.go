@@ -0,0 +1,122 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FetchRunOutcome は実行全体の結果。終了コードの判定に使う
+type FetchRunOutcome string
+
+const (
+	FetchRunOutcomeSuccess        FetchRunOutcome = "success"
+	FetchRunOutcomePartialFailure FetchRunOutcome = "partial_failure"
+	FetchRunOutcomeFailure        FetchRunOutcome = "failure"
+)
+
+// FetchRunReport は実行結果の集計。JSONとしてCIのアーティファクトに保存できる
+type FetchRunReport struct {
+	RunID           uuid.UUID            `json:"run_id"`
+	Status          FetchRunStatus       `json:"status"`
+	Outcome         FetchRunOutcome      `json:"outcome"`
+	StartedAt       time.Time            `json:"started_at"`
+	FinishedAt      *time.Time           `json:"finished_at"`
+	DurationSeconds float64              `json:"duration_seconds"`
+	Totals          FetchRunTotals       `json:"totals"`
+	Configs         []FetchRunReportItem `json:"configs"`
+	// CircuitBreakers は実行終了時のデータソースごとのサーキットブレーカーの状態
+	CircuitBreakers map[string]CircuitBreakerReport `json:"circuit_breakers,omitempty"`
+}
+
+// CircuitBreakerReport はデータソースのサーキットブレーカーの状態。Skipped はこの実行でスキップした設定の数
+type CircuitBreakerReport struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Skipped             int        `json:"skipped"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type FetchRunTotals struct {
+	Configs    int `json:"configs"`
+	Succeeded  int `json:"succeeded"`
+	Failed     int `json:"failed"`
+	Skipped    int `json:"skipped"`
+	Pending    int `json:"pending"`
+	ItemsSaved int `json:"items_saved"`
+}
+
+// FetchRunReportItem は設定1件分の結果
+type FetchRunReportItem struct {
+	ConfigID        uuid.UUID          `json:"config_id"`
+	Source          string             `json:"source,omitempty"`
+	Status          FetchRunItemStatus `json:"status"`
+	ItemsSaved      int                `json:"items_saved"`
+	Error           string             `json:"error,omitempty"`
+	StartedAt       *time.Time         `json:"started_at"`
+	FinishedAt      *time.Time         `json:"finished_at"`
+	DurationSeconds float64            `json:"duration_seconds"`
+}
+
+// NewFetchRunReport は実行と設定ごとの進捗からレポートを作る
+func NewFetchRunReport(run *FetchRun, items []FetchRunItem) *FetchRunReport {
+	report := &FetchRunReport{
+		RunID:      run.ID,
+		Status:     run.Status,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Configs:    make([]FetchRunReportItem, 0, len(items)),
+	}
+	if run.FinishedAt != nil {
+		report.DurationSeconds = run.FinishedAt.Sub(run.StartedAt).Seconds()
+	}
+
+	for _, item := range items {
+		ri := FetchRunReportItem{
+			ConfigID:   item.ConfigID,
+			Status:     item.Status,
+			ItemsSaved: item.ItemsSaved,
+			StartedAt:  item.StartedAt,
+			FinishedAt: item.FinishedAt,
+		}
+		if item.Error != nil {
+			ri.Error = *item.Error
+		}
+		if item.StartedAt != nil && item.FinishedAt != nil {
+			ri.DurationSeconds = item.FinishedAt.Sub(*item.StartedAt).Seconds()
+		}
+		report.Configs = append(report.Configs, ri)
+
+		report.Totals.Configs++
+		report.Totals.ItemsSaved += item.ItemsSaved
+		switch item.Status {
+		case FetchRunItemStatusSucceeded:
+			report.Totals.Succeeded++
+		case FetchRunItemStatusFailed:
+			report.Totals.Failed++
+		case FetchRunItemStatusSkipped:
+			report.Totals.Skipped++
+		default:
+			report.Totals.Pending++
+		}
+	}
+
+	report.Outcome = report.outcome()
+	return report
+}
+
+// outcome は全設定が成功した場合のみsuccess、1件も成功しなかった場合はfailureとする。
+// 対象の設定がない実行は成功として扱う
+func (r *FetchRunReport) outcome() FetchRunOutcome {
+	switch {
+	case r.Status == FetchRunStatusFailed:
+		return FetchRunOutcomeFailure
+	case r.Totals.Succeeded == r.Totals.Configs:
+		return FetchRunOutcomeSuccess
+	case r.Totals.Succeeded == 0:
+		return FetchRunOutcomeFailure
+	default:
+		return FetchRunOutcomePartialFailure
+	}
+}
@@ -1,11 +1,17 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// FetchedData はデータソースから取得した1件のアイテム。
+// CanonicalURL はアイテムが指す記事の正規化したURLで、同じユーザーの同じURLのアイテムは StoryID で1つのストーリーにまとめる。
+// Fingerprint はタイトルと本文のSimHashで、内容がほぼ同じ以前のアイテムがある場合は DuplicateOf にそのIDを設定する。
+// Content はデータソースから取得したままの本文で、ContentHTML（サニタイズ済みのHTML）、ContentText（プレーンテキスト）、
+// Excerpt（プレーンテキストの抜粋）、Links と Images（本文中のリンクと画像）はそれを正規化した結果
 type FetchedData struct {
 	ID           uuid.UUID              `json:"id"`
 	ConfigID     uuid.UUID              `json:"config_id"`
@@ -18,7 +24,38 @@ type FetchedData struct {
 	PublishedAt  *time.Time             `json:"published_at,omitempty"`
 	Tags         []string               `json:"tags"`
 	MediaURLs    []string               `json:"media_urls"`
+	ContentHTML  string                 `json:"content_html,omitempty"`
+	ContentText  string                 `json:"content_text,omitempty"`
+	Excerpt      string                 `json:"excerpt,omitempty"`
+	Links        []string               `json:"links,omitempty"`
+	Images       []string               `json:"images,omitempty"`
+	CanonicalURL string                 `json:"canonical_url,omitempty"`
+	StoryID      *uuid.UUID             `json:"story_id,omitempty"`
+	Fingerprint  *int64                 `json:"fingerprint,omitempty"`
+	DuplicateOf  *uuid.UUID             `json:"duplicate_of,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata"`
 	FetchedAt    time.Time              `json:"fetched_at"`
 	CreatedAt    time.Time              `json:"created_at"`
 }
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (d *FetchedData) UnmarshalJSON(data []byte) error {
+	// 時刻以外の列はそのまま読み込むため、メソッドを持たない別名の型に委ねる
+	type fetchedData FetchedData
+	aux := &struct {
+		*fetchedData
+		PublishedAt *Timestamp `json:"published_at"`
+		FetchedAt   Timestamp  `json:"fetched_at"`
+		CreatedAt   Timestamp  `json:"created_at"`
+	}{fetchedData: (*fetchedData)(d)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	d.PublishedAt = aux.PublishedAt.TimePtr()
+	d.FetchedAt = aux.FetchedAt.Time
+	d.CreatedAt = aux.CreatedAt.Time
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RunLock はバッチの多重実行を防ぐためのTTL付きロック
+type RunLock struct {
+	Name       string    `json:"name" db:"name"`
+	Holder     string    `json:"holder" db:"holder"`
+	AcquiredAt time.Time `json:"acquired_at" db:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// RunLockAcquisition はロック取得の試行結果
+type RunLockAcquisition struct {
+	Acquired bool `json:"acquired"`
+	// Lock は取得後の（取得できなかった場合は現在の保持者の）ロック
+	Lock RunLock `json:"lock"`
+	// TakenOverFrom は期限切れのロックを引き継いだ場合の前の保持者
+	TakenOverFrom *string `json:"taken_over_from"`
+}
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (l *RunLock) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Name       string    `json:"name"`
+		Holder     string    `json:"holder"`
+		AcquiredAt Timestamp `json:"acquired_at"`
+		ExpiresAt  Timestamp `json:"expires_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	l.Name = aux.Name
+	l.Holder = aux.Holder
+
+	l.AcquiredAt = aux.AcquiredAt.Time
+	l.ExpiresAt = aux.ExpiresAt.Time
+
+	return nil
+}
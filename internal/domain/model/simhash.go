@@ -0,0 +1,124 @@
+package model
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// minFingerprintFeatures より特徴の少ない（短すぎる）テキストは、無関係な投稿同士が近くなりやすいため指紋を作らない
+const minFingerprintFeatures = 4
+
+// titleWeight はタイトルの特徴の重み。転載では本文よりタイトルが残りやすいため重くする
+const titleWeight = 2
+
+// ItemFingerprint は保存済みのアイテムの指紋
+type ItemFingerprint struct {
+	ID          uuid.UUID `json:"id"`
+	Fingerprint int64     `json:"fingerprint"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// ContentFingerprint はタイトルと本文から64ビットのSimHashを作る。
+// 小文字にした単語と連続する2単語（日本語などの分かち書きしない文字は2文字ずつ）を特徴とするため、
+// 語の追加や句読点・大文字小文字の違いではハミング距離が少し変わるだけになる。
+// 特徴が少なすぎる場合はfalseを返す。データベースのBIGINTに保存できるよう符号付きで返す
+func ContentFingerprint(title, content string) (int64, bool) {
+	var weights [64]int
+	features := 0
+	add := func(text string, weight int) {
+		for _, f := range fingerprintFeatures(text) {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(f))
+			sum := h.Sum64()
+			for bit := 0; bit < 64; bit++ {
+				if sum&(1<<uint(bit)) != 0 {
+					weights[bit] += weight
+				} else {
+					weights[bit] -= weight
+				}
+			}
+			features++
+		}
+	}
+	add(title, titleWeight)
+	add(content, 1)
+	if features < minFingerprintFeatures {
+		return 0, false
+	}
+
+	var fingerprint uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return int64(fingerprint), true
+}
+
+// HammingDistance は2つの指紋の異なるビットの数を返す
+func HammingDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// fingerprintFeatures はテキストを単語に分け、単語と連続する2単語を返す
+func fingerprintFeatures(text string) []string {
+	tokens := fingerprintTokens(text)
+	features := make([]string, 0, len(tokens)*2)
+	for i, t := range tokens {
+		features = append(features, t)
+		if i > 0 {
+			features = append(features, tokens[i-1]+" "+t)
+		}
+	}
+	return features
+}
+
+// fingerprintTokens は英数字の連続を1単語とする。分かち書きしない文字は2文字ずつの組にする
+func fingerprintTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	var cjk []rune
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isUnsegmented(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word.WriteRune(r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isUnsegmented(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	releaseTitle = "Go 1.24 released with generic type aliases and faster maps"
+	releaseBody  = "The Go team announced the release of Go 1.24 today. Highlights include full support for generic type aliases, " +
+		"a new Swiss table based map implementation, and improvements to the runtime."
+)
+
+func TestContentFingerprintDistance(t *testing.T) {
+	tests := []struct {
+		name        string
+		title, body string
+		minDistance int
+		maxDistance int
+	}{
+		{"同じ内容", releaseTitle, releaseBody, 0, 0},
+		{"大文字小文字と句読点だけが違う", "go 1.24 RELEASED, with generic type-aliases and faster maps!", releaseBody, 0, 0},
+		{"タイトルにタグを付けた転載", "[News] " + releaseTitle, releaseBody, 1, 8},
+		{"タイトルの語を変えた転載", "Go 1.24 released: generic type aliases and faster maps", releaseBody, 1, 8},
+		{"本文に一文を足した転載", releaseTitle, releaseBody + " Discuss below.", 1, 8},
+		{"無関係な投稿", "Show HN: a tiny terminal spreadsheet written in Rust",
+			"I built a small spreadsheet that runs in the terminal. It supports formulas, CSV import and vim key bindings. Feedback welcome.", 20, 64},
+	}
+	original, ok := ContentFingerprint(releaseTitle, releaseBody)
+	if !ok {
+		t.Fatal("ContentFingerprint returned no fingerprint for the original")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp, ok := ContentFingerprint(tt.title, tt.body)
+			if !ok {
+				t.Fatal("ContentFingerprint returned no fingerprint")
+			}
+			if d := HammingDistance(original, fp); d < tt.minDistance || d > tt.maxDistance {
+				t.Errorf("distance = %d, want %d..%d", d, tt.minDistance, tt.maxDistance)
+			}
+		})
+	}
+}
+
+// 分かち書きしない文字は2文字ずつの組を特徴にするため、語の追加は距離を少し変えるだけになる
+func TestContentFingerprintUnsegmentedText(t *testing.T) {
+	body := "ジェネリックな型エイリアスとマップの高速化が含まれています。"
+	original, ok := ContentFingerprint("Go 1.24 がリリースされました", body)
+	if !ok {
+		t.Fatal("ContentFingerprint returned no fingerprint")
+	}
+	repost, _ := ContentFingerprint("【速報】Go 1.24 がリリースされました", body)
+	unrelated, _ := ContentFingerprint("今日の東京の天気は晴れのち雨", "午後から雨が降る見込みなので傘を持って出かけてください。")
+
+	if d := HammingDistance(original, repost); d > 8 {
+		t.Errorf("distance to the repost = %d, want at most 8", d)
+	}
+	if d := HammingDistance(original, unrelated); d <= 20 {
+		t.Errorf("distance to an unrelated post = %d, want more than 20", d)
+	}
+}
+
+func TestContentFingerprintNeedsEnoughFeatures(t *testing.T) {
+	tests := []struct {
+		name        string
+		title, body string
+		want        bool
+	}{
+		{"空", "", "", false},
+		{"記号だけ", "!!! ???", "---", false},
+		{"2単語", "hello world", "", false},
+		{"1文字の漢字", "", "雨", false},
+		{"3単語", "a b c", "", true},
+		{"タイトルと本文を合わせて数える", "hello world", "again", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ContentFingerprint(tt.title, tt.body); ok != tt.want {
+				t.Errorf("ContentFingerprint(%q, %q) ok = %v, want %v", tt.title, tt.body, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b int64
+		want int
+	}{
+		{0, 0, 0},
+		{0b1010, 0b1010, 0},
+		{0b1, 0b11, 1},
+		{0b1010, 0b0101, 4},
+		{0, -1, 64},
+		{math.MinInt64, 0, 1},
+		{math.MaxInt64, math.MinInt64, 64},
+	}
+	for _, tt := range tests {
+		if got := HammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := HammingDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("HammingDistance(%#x, %#x) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
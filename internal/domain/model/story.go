@@ -0,0 +1,24 @@
+package model
+
+import "github.com/google/uuid"
+
+// StoryDiscussion はストーリーに含まれる各データソースの投稿（議論のリンク）。
+// storiesテーブルの metadata.discussions に保存する
+type StoryDiscussion struct {
+	Source   string    `json:"source"`
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	ItemID   uuid.UUID `json:"item_id"`
+	ConfigID uuid.UUID `json:"config_id"`
+}
+
+// NewStoryDiscussion は取得したアイテムを議論のリンクにする
+func NewStoryDiscussion(item *FetchedData) StoryDiscussion {
+	return StoryDiscussion{
+		Source:   item.Source,
+		URL:      item.URL,
+		Title:    item.Title,
+		ItemID:   item.ID,
+		ConfigID: item.ConfigID,
+	}
+}
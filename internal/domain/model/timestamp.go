@@ -0,0 +1,102 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// timestampLayout はデータベースへ書き込む時刻の形式。常にUTCで、オフセットを明示する（+00:00）
+const timestampLayout = "2006-01-02T15:04:05.999999-07:00"
+
+// timestampLayouts は読み込みを受け付ける形式。PostgRESTが返すRFC 3339形式に加えて、
+// Postgresのテキスト形式（空白区切り、時のみのオフセット）とタイムゾーンなしの形式を受け付ける
+var timestampLayouts = []struct {
+	layout string
+	zoned  bool
+}{
+	{time.RFC3339Nano, true},
+	{"2006-01-02T15:04:05.999999999-07", true},
+	{"2006-01-02T15:04:05.999999999-0700", true},
+	{"2006-01-02 15:04:05.999999999Z07:00", true},
+	{"2006-01-02 15:04:05.999999999-07", true},
+	{"2006-01-02 15:04:05.999999999-0700", true},
+	{"2006-01-02T15:04:05.999999999", false},
+	{"2006-01-02 15:04:05.999999999", false},
+}
+
+// Timestamp はSupabaseとやり取りする時刻。
+// タイムゾーン付き・なしのどちらの形式も読み込み、常にUTCのオフセット付きで書き出す
+type Timestamp struct {
+	time.Time
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t.UTC()}
+}
+
+// NewTimestampPtr はtがnilの場合にnilを返す
+func NewTimestampPtr(t *time.Time) *Timestamp {
+	if t == nil {
+		return nil
+	}
+	ts := NewTimestamp(*t)
+	return &ts
+}
+
+// ParseTimestamp は時刻を解釈してUTCで返す。
+// タイムゾーンのない値（TIMESTAMP列）はバッチがUTCで書き込んでいるためUTCとみなす
+func ParseTimestamp(s string) (time.Time, error) {
+	for _, l := range timestampLayouts {
+		var t time.Time
+		var err error
+		if l.zoned {
+			t, err = time.Parse(l.layout, s)
+		} else {
+			t, err = time.ParseInLocation(l.layout, s, time.UTC)
+		}
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// FormatTimestamp は時刻をデータベースへ書き込む形式にする
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// TimePtr はtがnilまたはゼロ値の場合にnilを返す
+func (t *Timestamp) TimePtr() *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(FormatTimestamp(t.Time))
+}
+
+// UnmarshalJSON はnullと空文字列をゼロ値として扱う
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("timestamp must be a string: %w", err)
+	}
+	if s == "" {
+		return nil
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"RFC 3339", "2024-01-02T03:04:05.123456Z"},
+		{"オフセット付き", "2024-01-02T12:04:05.123456+09:00"},
+		{"Postgresのテキスト形式", "2024-01-02 03:04:05.123456+00"},
+		{"タイムゾーンなしはUTC", "2024-01-02T03:04:05.123456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.value)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) error: %v", tt.value, err)
+			}
+			if !got.Equal(want) || got.Location() != time.UTC {
+				t.Errorf("ParseTimestamp(%q) = %v, want %v", tt.value, got, want)
+			}
+		})
+	}
+}
+
+func TestFetchedDataUnmarshalJSON(t *testing.T) {
+	var d FetchedData
+	data := `{"title":"t","tags":["go"],"published_at":"2024-01-02 03:04:05","fetched_at":"2024-01-02T12:04:05+09:00","created_at":null}`
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if d.Title != "t" || len(d.Tags) != 1 {
+		t.Errorf("fields = %q %v, want t [go]", d.Title, d.Tags)
+	}
+	if d.PublishedAt == nil || !d.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", d.PublishedAt, want)
+	}
+	if !d.FetchedAt.Equal(want) {
+		t.Errorf("FetchedAt = %v, want %v", d.FetchedAt, want)
+	}
+	if !d.CreatedAt.IsZero() {
+		t.Errorf("CreatedAt = %v, want zero", d.CreatedAt)
+	}
+}
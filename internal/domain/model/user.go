@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -23,3 +24,27 @@ func NewUser(id UserID, name string) *User {
 		UpdatedAt: now,
 	}
 }
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (u *User) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		ID        UserID    `json:"id"`
+		Name      string    `json:"name"`
+		AvatarURL *string   `json:"avatar_url"`
+		CreatedAt Timestamp `json:"created_at"`
+		UpdatedAt Timestamp `json:"updated_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	u.ID = aux.ID
+	u.Name = aux.Name
+	u.AvatarURL = aux.AvatarURL
+
+	u.CreatedAt = aux.CreatedAt.Time
+	u.UpdatedAt = aux.UpdatedAt.Time
+
+	return nil
+}
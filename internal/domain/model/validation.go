@@ -0,0 +1,36 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidationError は設定の項目ごとの検証エラー
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+func newValidationError(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationErrors はerrors.Joinでまとめられた検証エラーを1件ずつに分解する
+func ValidationErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, ValidationErrors(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,3 +30,35 @@ func NewYouTubeFetchConfig(userFetchConfigID uuid.UUID, channelID *string, keywo
 		CreatedAt:         time.Now(),
 	}
 }
+
+// UnmarshalJSON custom unmarshaler to handle Supabase timestamp format
+func (y *YouTubeFetchConfig) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		ID                uuid.UUID  `json:"id"`
+		UserFetchConfigID uuid.UUID  `json:"user_fetch_config_id"`
+		ChannelID         *string    `json:"channel_id"`
+		PlaylistID        *string    `json:"playlist_id"`
+		Keywords          []string   `json:"keywords"`
+		MaxResults        int        `json:"max_results"`
+		OrderBy           string     `json:"order_by"`
+		PublishedAfter    *Timestamp `json:"published_after"`
+		CreatedAt         Timestamp  `json:"created_at"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	y.ID = aux.ID
+	y.UserFetchConfigID = aux.UserFetchConfigID
+	y.ChannelID = aux.ChannelID
+	y.PlaylistID = aux.PlaylistID
+	y.Keywords = aux.Keywords
+	y.MaxResults = aux.MaxResults
+	y.OrderBy = aux.OrderBy
+
+	y.PublishedAfter = aux.PublishedAfter.TimePtr()
+	y.CreatedAt = aux.CreatedAt.Time
+
+	return nil
+}
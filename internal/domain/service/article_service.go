@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// DefaultArticleConcurrency は同時に取得するページの数の既定値
+const DefaultArticleConcurrency = 4
+
+// mediaHosts は記事ではなく画像・動画・投稿そのものを指すホスト
+var mediaHosts = map[string]bool{
+	"reddit.com": true, "i.redd.it": true, "v.redd.it": true, "preview.redd.it": true,
+	"imgur.com": true, "i.imgur.com": true, "gfycat.com": true, "redgifs.com": true, "streamable.com": true,
+	"youtube.com": true, "youtu.be": true, "x.com": true, "instagram.com": true, "tiktok.com": true,
+}
+
+// mediaExtensions は記事ではないファイルの拡張子
+var mediaExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".gifv": true, ".webp": true, ".avif": true,
+	".mp4": true, ".webm": true, ".mov": true, ".mp3": true, ".pdf": true, ".zip": true,
+}
+
+// ArticleService はリンク投稿のリンク先の記事を取得し、アイテムのメタデータ（"article"）に保存する
+type ArticleService struct {
+	extractor   output.ArticleExtractor
+	concurrency int
+}
+
+func NewArticleService(extractor output.ArticleExtractor) *ArticleService {
+	return &ArticleService{
+		extractor:   extractor,
+		concurrency: DefaultArticleConcurrency,
+	}
+}
+
+// SetConcurrency は同時に取得するページの数を変更する
+func (s *ArticleService) SetConcurrency(n int) {
+	if n > 0 {
+		s.concurrency = n
+	}
+}
+
+// Enrich はリンク先が記事と思われるアイテムについて記事を取り出し、取り出せた件数を返す。
+// 同じURLのページは1回だけ取得する。robots.txtで許可されていないページや記事として読めないページは失敗として扱わない
+func (s *ArticleService) Enrich(ctx context.Context, items []*model.FetchedData) (int, error) {
+	targets := make(map[string][]*model.FetchedData)
+	var urls []string
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if u := articleURL(item); u != "" {
+			if _, ok := targets[u]; !ok {
+				urls = append(urls, u)
+			}
+			targets[u] = append(targets[u], item)
+		}
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+
+	articles := make([]*model.Article, len(urls))
+	errs := make([]error, len(urls))
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			articles[i], errs[i] = s.extractor.Extract(ctx, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	enriched := 0
+	var failed []error
+	for i, u := range urls {
+		if err := errs[i]; err != nil {
+			if errors.Is(err, output.ErrRobotsDisallowed) || errors.Is(err, output.ErrNotArticle) {
+				slog.DebugContext(ctx, "Skipped linked article", "url", u, "reason", err)
+			} else {
+				failed = append(failed, fmt.Errorf("%s: %w", u, err))
+			}
+			continue
+		}
+		for _, item := range targets[u] {
+			if item.Metadata == nil {
+				item.Metadata = make(map[string]interface{})
+			}
+			item.Metadata[model.MetadataArticle] = articles[i]
+			enriched++
+		}
+	}
+	return enriched, errors.Join(failed...)
+}
+
+// articleURL はアイテムが外部の記事へのリンクの場合にそのURLを返す。
+// リンク先がアイテム自身のページ（テキスト投稿）、画像・動画、SNSの場合は空文字列を返す
+func articleURL(item *model.FetchedData) string {
+	if item.CanonicalURL == "" {
+		return ""
+	}
+	u, err := url.Parse(item.CanonicalURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if mediaHosts[u.Hostname()] || mediaExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return ""
+	}
+	if self, err := url.Parse(item.URL); err == nil && strings.TrimPrefix(self.Hostname(), "www.") == u.Hostname() {
+		return ""
+	}
+	return item.CanonicalURL
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultDuplicateThreshold はほぼ同じ内容とみなすハミング距離の既定値。
+	// 投稿は短く数語の違いでも距離が大きくなるため、Webページ向けの一般的な値（3）より大きくしている。
+	// 無関係なテキスト同士の距離はおよそ32になる
+	DefaultDuplicateThreshold = 8
+	// DefaultDuplicateWindow は比較する過去のアイテムの期間の既定値
+	DefaultDuplicateWindow = 72 * time.Hour
+)
+
+// DuplicateService はアイテムの指紋を作り、ユーザーのフィードで最近取得した内容がほぼ同じアイテムを探す。
+// IDによる重複排除では見つからない、タイトルや本文を少し変えた転載・クロスポストを検出する
+type DuplicateService struct {
+	dataRepository output.FetchedDataRepository
+	threshold      int
+	window         time.Duration
+	now            func() time.Time
+}
+
+func NewDuplicateService(repo output.FetchedDataRepository) *DuplicateService {
+	return &DuplicateService{
+		dataRepository: repo,
+		threshold:      DefaultDuplicateThreshold,
+		window:         DefaultDuplicateWindow,
+		now:            time.Now,
+	}
+}
+
+// SetThreshold はほぼ同じ内容とみなすハミング距離（0〜64）を変更する。0の場合は指紋が一致するものだけを対象にする
+func (s *DuplicateService) SetThreshold(threshold int) {
+	if threshold >= 0 && threshold <= 64 {
+		s.threshold = threshold
+	}
+}
+
+// SetWindow は比較する過去のアイテムの期間を変更する
+func (s *DuplicateService) SetWindow(window time.Duration) {
+	if window > 0 {
+		s.window = window
+	}
+}
+
+// MarkDuplicates は各アイテムに指紋を設定し、期間内の保存済みのアイテムまたは同じ取得で先に並ぶアイテムと
+// 距離がしきい値以下の場合は DuplicateOf を設定する。重複と判定した件数を返す。
+// 保存済みの指紋を読めない場合も指紋は設定する
+func (s *DuplicateService) MarkDuplicates(ctx context.Context, userID uuid.UUID, items []*model.FetchedData) (int, error) {
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		// 正規化済みの場合は記法やタグの違いに左右されないプレーンテキストを使う
+		content := item.ContentText
+		if content == "" {
+			content = item.Content
+		}
+		if fp, ok := model.ContentFingerprint(item.Title, content); ok {
+			item.Fingerprint = &fp
+		}
+	}
+
+	known, err := s.dataRepository.ListRecentFingerprints(ctx, userID, s.now().Add(-s.window))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load recent fingerprints: %w", err)
+	}
+
+	marked := 0
+	for _, item := range items {
+		if item == nil || item.Fingerprint == nil {
+			continue
+		}
+		if original, ok := s.findOriginal(item, known); ok {
+			item.DuplicateOf = &original
+			marked++
+		}
+		// 同じ取得内の後続のアイテムとも比較する
+		known = append(known, model.ItemFingerprint{ID: item.ID, Fingerprint: *item.Fingerprint, FetchedAt: item.FetchedAt})
+	}
+	return marked, nil
+}
+
+// findOriginal は最も距離の近いアイテムのIDを返す。同じアイテム（再取得）は対象にしない
+func (s *DuplicateService) findOriginal(item *model.FetchedData, known []model.ItemFingerprint) (uuid.UUID, bool) {
+	best, bestDistance := uuid.Nil, s.threshold+1
+	for _, k := range known {
+		if k.ID == item.ID {
+			continue
+		}
+		if d := model.HammingDistance(k.Fingerprint, *item.Fingerprint); d < bestDistance {
+			best, bestDistance = k.ID, d
+		}
+	}
+	return best, best != uuid.Nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fake"
+	"github.com/google/uuid"
+)
+
+const (
+	releaseTitle = "Go 1.24 released with generic type aliases and faster maps"
+	releaseBody  = "The Go team announced the release of Go 1.24 today. Highlights include full support for generic type aliases, " +
+		"a new Swiss table based map implementation, and improvements to the runtime."
+	unrelatedTitle = "Show HN: a tiny terminal spreadsheet written in Rust"
+	unrelatedBody  = "I built a small spreadsheet that runs in the terminal. It supports formulas, CSV import and vim key bindings."
+)
+
+var duplicateNow = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// duplicateFixture は保存済みのアイテムの指紋を recent_fingerprints と同じ規則で返すリポジトリでサービスを組み立てる
+type duplicateFixture struct {
+	repos       *fake.Repositories
+	svc         *DuplicateService
+	userID      uuid.UUID
+	configID    uuid.UUID
+	otherConfig uuid.UUID
+}
+
+func newDuplicateFixture() *duplicateFixture {
+	repos := fake.NewRepositories()
+	userID, otherUser := uuid.New(), uuid.New()
+	f := &duplicateFixture{repos: repos, userID: userID, configID: uuid.New(), otherConfig: uuid.New()}
+	repos.FetchConfigs.Add(
+		model.UserFetchConfig{ID: f.configID, UserID: userID, IsActive: true},
+		model.UserFetchConfig{ID: f.otherConfig, UserID: otherUser, IsActive: true},
+	)
+
+	f.svc = NewDuplicateService(repos.FetchedData)
+	f.svc.now = func() time.Time { return duplicateNow }
+	return f
+}
+
+// store は指紋を付けたアイテムを保存する
+func (f *duplicateFixture) store(t *testing.T, configID uuid.UUID, title, body string, fetchedAt time.Time) uuid.UUID {
+	t.Helper()
+	item := &model.FetchedData{ID: uuid.New(), ConfigID: configID, Title: title, Content: body, FetchedAt: fetchedAt}
+	fp, ok := model.ContentFingerprint(title, body)
+	if !ok {
+		t.Fatalf("no fingerprint for %q", title)
+	}
+	item.Fingerprint = &fp
+	if err := f.repos.FetchedData.Create(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+	return item.ID
+}
+
+func newItem(title, body string) *model.FetchedData {
+	return &model.FetchedData{ID: uuid.New(), Title: title, Content: body, FetchedAt: duplicateNow}
+}
+
+func TestDuplicateServiceMarkDuplicates(t *testing.T) {
+	tests := []struct {
+		name string
+		// storedAgo は保存済みのアイテムを取得してからの時間。other がtrueの場合は他のユーザーの設定で保存する
+		storedAgo time.Duration
+		other     bool
+		item      func() *model.FetchedData
+		want      bool
+	}{
+		{name: "同じ内容", storedAgo: time.Hour, item: func() *model.FetchedData { return newItem(releaseTitle, releaseBody) }, want: true},
+		{name: "タイトルを少し変えた転載", storedAgo: time.Hour, item: func() *model.FetchedData { return newItem("[News] "+releaseTitle, releaseBody) }, want: true},
+		{name: "本文に一文を足した転載", storedAgo: time.Hour, item: func() *model.FetchedData { return newItem(releaseTitle, releaseBody+" Discuss below.") }, want: true},
+		{
+			name:      "正規化したテキストで比較する",
+			storedAgo: time.Hour,
+			item: func() *model.FetchedData {
+				item := newItem(releaseTitle, `<div class="md"><p>`+releaseBody+`</p><a href="https://go.dev/blog">blog</a></div>`)
+				item.ContentText = releaseBody
+				return item
+			},
+			want: true,
+		},
+		{name: "無関係な内容", storedAgo: time.Hour, item: func() *model.FetchedData { return newItem(unrelatedTitle, unrelatedBody) }},
+		{name: "期間より前に保存したアイテムとは比較しない", storedAgo: DefaultDuplicateWindow + time.Hour, item: func() *model.FetchedData { return newItem(releaseTitle, releaseBody) }},
+		{name: "他のユーザーのアイテムとは比較しない", storedAgo: time.Hour, other: true, item: func() *model.FetchedData { return newItem(releaseTitle, releaseBody) }},
+		{name: "本文がなくタイトルが短い", storedAgo: time.Hour, item: func() *model.FetchedData { return newItem("Go 1.24", "") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDuplicateFixture()
+			configID := f.configID
+			if tt.other {
+				configID = f.otherConfig
+			}
+			original := f.store(t, configID, releaseTitle, releaseBody, duplicateNow.Add(-tt.storedAgo))
+
+			item := tt.item()
+			marked, err := f.svc.MarkDuplicates(context.Background(), f.userID, []*model.FetchedData{item})
+			if err != nil {
+				t.Fatalf("MarkDuplicates: %v", err)
+			}
+			wantMarked := 0
+			if tt.want {
+				wantMarked = 1
+			}
+			if got := item.DuplicateOf != nil; got != tt.want || marked != wantMarked {
+				t.Fatalf("duplicate = %v (marked %d), want %v", got, marked, tt.want)
+			}
+			if tt.want && *item.DuplicateOf != original {
+				t.Errorf("DuplicateOf = %s, want the stored item %s", *item.DuplicateOf, original)
+			}
+		})
+	}
+}
+
+func TestDuplicateServiceThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		title     string
+		want      bool
+	}{
+		{"既定のしきい値ではほぼ同じ内容を重複とする", DefaultDuplicateThreshold, "[News] " + releaseTitle, true},
+		{"しきい値が0の場合は一致する指紋だけ", 0, "[News] " + releaseTitle, false},
+		{"しきい値が0でも同じ内容は重複とする", 0, releaseTitle, true},
+		{"範囲外のしきい値は無視する", 65, "[News] " + releaseTitle, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDuplicateFixture()
+			f.svc.SetThreshold(tt.threshold)
+			f.store(t, f.configID, releaseTitle, releaseBody, duplicateNow.Add(-time.Hour))
+
+			item := newItem(tt.title, releaseBody)
+			if _, err := f.svc.MarkDuplicates(context.Background(), f.userID, []*model.FetchedData{item}); err != nil {
+				t.Fatal(err)
+			}
+			if got := item.DuplicateOf != nil; got != tt.want {
+				t.Errorf("duplicate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateServiceWindow(t *testing.T) {
+	f := newDuplicateFixture()
+	f.svc.SetWindow(30 * time.Minute)
+	f.store(t, f.configID, releaseTitle, releaseBody, duplicateNow.Add(-time.Hour))
+
+	item := newItem(releaseTitle, releaseBody)
+	if _, err := f.svc.MarkDuplicates(context.Background(), f.userID, []*model.FetchedData{item}); err != nil {
+		t.Fatal(err)
+	}
+	if item.DuplicateOf != nil {
+		t.Errorf("matched an item stored before the window")
+	}
+}
+
+// 同じ取得内で先に並ぶアイテムとも比較し、再取得した同じアイテムは重複としない
+func TestDuplicateServiceMarkDuplicatesWithinBatch(t *testing.T) {
+	f := newDuplicateFixture()
+	stored := f.store(t, f.configID, unrelatedTitle, unrelatedBody, duplicateNow.Add(-time.Hour))
+
+	first := newItem(releaseTitle, releaseBody)
+	crosspost := newItem("[News] "+releaseTitle, releaseBody)
+	refetched := newItem(unrelatedTitle, unrelatedBody)
+	refetched.ID = stored
+
+	marked, err := f.svc.MarkDuplicates(context.Background(), f.userID, []*model.FetchedData{first, nil, crosspost, refetched})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marked != 1 || first.DuplicateOf != nil {
+		t.Fatalf("marked %d (first %v), want only the crosspost", marked, first.DuplicateOf)
+	}
+	if crosspost.DuplicateOf == nil || *crosspost.DuplicateOf != first.ID {
+		t.Errorf("crosspost DuplicateOf = %v, want %s", crosspost.DuplicateOf, first.ID)
+	}
+	if refetched.DuplicateOf != nil {
+		t.Errorf("refetched item was marked as a duplicate of %s", *refetched.DuplicateOf)
+	}
+	for _, item := range []*model.FetchedData{first, crosspost, refetched} {
+		if item.Fingerprint == nil {
+			t.Errorf("item %q has no fingerprint", item.Title)
+		}
+	}
+}
+
+// failingFingerprints は保存済みの指紋の取得に失敗するリポジトリ
+type failingFingerprints struct {
+	*fake.FetchedDataRepository
+}
+
+func (r failingFingerprints) ListRecentFingerprints(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.ItemFingerprint, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestDuplicateServiceKeepsFingerprintsWhenLookupFails(t *testing.T) {
+	svc := NewDuplicateService(failingFingerprints{fake.NewFetchedDataRepository()})
+	item := newItem(releaseTitle, releaseBody)
+
+	if _, err := svc.MarkDuplicates(context.Background(), uuid.New(), []*model.FetchedData{item}); err == nil {
+		t.Error("MarkDuplicates did not report the lookup failure")
+	}
+	if item.Fingerprint == nil {
+		t.Error("fingerprint was not set")
+	}
+}
@@ -2,16 +2,24 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
 )
 
+// DefaultSuspendThreshold は恒久的な失敗が何回続いたら設定を自動停止するか
+const DefaultSuspendThreshold = 3
+
 type FetchConfigService struct {
 	userRepo              output.UserRepository
 	configRepo            output.FetchConfigRepository
 	redditFetchConfigRepo output.RedditFetchConfigRepository
+	suspendThreshold      int
 }
 
 type EnrichedFetchConfig struct {
@@ -19,6 +27,20 @@ type EnrichedFetchConfig struct {
 	Detail          model.FetchConfigDetail
 }
 
+// FetchSelector は実行対象の絞り込み条件。ゼロ値の場合は実行時刻を迎えた全ユーザーの設定が対象
+type FetchSelector struct {
+	UserID       *uuid.UUID
+	DataSourceID string
+	// ConfigIDs を指定した場合は、スケジュールや停止状態に関係なく指定した設定を対象にする
+	ConfigIDs []uuid.UUID
+	// Since を指定した場合は、スケジュールに関係なくこの時刻以降に取得していない設定を対象にする
+	Since *time.Time
+}
+
+func (sel FetchSelector) IsZero() bool {
+	return sel.UserID == nil && sel.DataSourceID == "" && len(sel.ConfigIDs) == 0 && sel.Since == nil
+}
+
 func NewFetchConfigService(
 	uRepo output.UserRepository,
 	cRepo output.FetchConfigRepository,
@@ -28,9 +50,14 @@ func NewFetchConfigService(
 		userRepo:              uRepo,
 		configRepo:            cRepo,
 		redditFetchConfigRepo: rRepo,
+		suspendThreshold:      DefaultSuspendThreshold,
 	}
 }
 
+// SetSuspendThreshold は自動停止までの連続失敗回数を変更する。0以下の場合は自動停止しない
+func (s *FetchConfigService) SetSuspendThreshold(n int) {
+	s.suspendThreshold = n
+}
 
 func (s *FetchConfigService) GetActiveUsersEnrichedConfigs(ctx context.Context) ([]EnrichedFetchConfig, error) {
 	// 対象ユーザーのIDを取得。ユーザー数が増えてくるとuser_statsテーブルを追加して、休眠ユーザーは対象外にする予定
@@ -45,7 +72,7 @@ func (s *FetchConfigService) GetActiveUsersEnrichedConfigs(ctx context.Context)
 		userConfigs, err := s.GetUserEnrichedConfigs(ctx, userID)
 		if err != nil {
 			// エラーログを記録して続行
-			fmt.Println("error", err)
+			slog.ErrorContext(ctx, "Failed to get user configs", "user_id", userID, "error", err)
 			continue
 		}
 		allConfigs = append(allConfigs, userConfigs...)
@@ -54,20 +81,162 @@ func (s *FetchConfigService) GetActiveUsersEnrichedConfigs(ctx context.Context)
 	return allConfigs, nil
 }
 
+// 実行時刻を迎えた設定のみを詳細情報付きで取得
+func (s *FetchConfigService) GetDueEnrichedConfigs(ctx context.Context, now time.Time) ([]EnrichedFetchConfig, error) {
+	allConfigs, err := s.GetActiveUsersEnrichedConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterDueConfigs(ctx, allConfigs, now, nil), nil
+}
+
+// GetSelectedEnrichedConfigs は条件に一致する設定を詳細情報付きで取得する。
+// 設定IDの指定がない場合は、実行時刻を迎えた（Since指定時はその時刻以降に取得していない）有効な設定に絞る
+func (s *FetchConfigService) GetSelectedEnrichedConfigs(ctx context.Context, now time.Time, sel FetchSelector) ([]EnrichedFetchConfig, error) {
+	if sel.IsZero() {
+		return s.GetDueEnrichedConfigs(ctx, now)
+	}
+
+	if len(sel.ConfigIDs) > 0 {
+		selected := make([]EnrichedFetchConfig, 0, len(sel.ConfigIDs))
+		for _, id := range sel.ConfigIDs {
+			cfg, err := s.GetEnrichedConfig(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			c := cfg.UserFetchConfig
+			if sel.UserID != nil && c.UserID != *sel.UserID {
+				return nil, fmt.Errorf("config %s belongs to user %s, not %s", id, c.UserID, *sel.UserID)
+			}
+			if sel.DataSourceID != "" && c.DataSourceID != sel.DataSourceID {
+				return nil, fmt.Errorf("config %s uses source '%s', not '%s'", id, c.DataSourceID, sel.DataSourceID)
+			}
+			if !c.IsActive || c.IsSuspended() {
+				slog.WarnContext(ctx, "Running inactive or suspended config because it was selected explicitly",
+					"config_id", id, "active", c.IsActive, "suspended", c.IsSuspended())
+			}
+			selected = append(selected, *cfg)
+		}
+		return selected, nil
+	}
+
+	configs, err := s.configRepo.List(ctx, output.FetchConfigFilter{
+		UserID:       sel.UserID,
+		DataSourceID: sel.DataSourceID,
+		ActiveOnly:   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configs: %w", err)
+	}
+	return filterDueConfigs(ctx, s.enrichConfigs(ctx, configs), now, sel.Since), nil
+}
+
+// filterDueConfigs は実行時刻を迎えた設定に絞る。sinceを指定した場合はスケジュールの代わりに
+// since以降に取得していないかで判定する
+func filterDueConfigs(ctx context.Context, configs []EnrichedFetchConfig, now time.Time, since *time.Time) []EnrichedFetchConfig {
+	dueConfigs := make([]EnrichedFetchConfig, 0, len(configs))
+	for _, cfg := range configs {
+		if since != nil {
+			last := cfg.UserFetchConfig.LastFetchedAt
+			if last == nil || last.Before(*since) {
+				dueConfigs = append(dueConfigs, cfg)
+			}
+			continue
+		}
+
+		due, err := cfg.UserFetchConfig.IsDue(now)
+		if err != nil {
+			// スケジュールが不正な設定はスキップする
+			slog.ErrorContext(ctx, "Invalid schedule, skipping config",
+				"user_id", cfg.UserFetchConfig.UserID,
+				"config_id", cfg.UserFetchConfig.ID,
+				"schedule", cfg.UserFetchConfig.Schedule,
+				"error", err)
+			continue
+		}
+		if due {
+			dueConfigs = append(dueConfigs, cfg)
+		}
+	}
+
+	return dueConfigs
+}
+
+// 取得に成功した時刻を記録し、次回の実行予定の基準とする。連続失敗の回数もリセットする
+func (s *FetchConfigService) MarkFetched(ctx context.Context, config model.UserFetchConfig, fetchedAt time.Time) error {
+	return s.configRepo.RecordSuccess(ctx, config.ID, fetchedAt)
+}
+
+// 取得の失敗を記録する。恒久的な失敗が閾値に達した設定は自動停止し、trueを返す
+func (s *FetchConfigService) RecordFailure(ctx context.Context, config model.UserFetchConfig, fetchErr error, now time.Time) (bool, error) {
+	kind := model.ClassifyFetchError(fetchErr)
+	message := fetchErr.Error()
+
+	failure := config.FetchFailure
+	// 種類が変わった場合は連続回数を数え直す
+	if failure.LastErrorKind != nil && *failure.LastErrorKind != kind {
+		failure.ConsecutiveFailures = 0
+	}
+	failure.ConsecutiveFailures++
+	failure.LastErrorKind = &kind
+	failure.LastError = &message
+
+	suspended := false
+	if kind.IsPermanent() && s.suspendThreshold > 0 && failure.ConsecutiveFailures >= s.suspendThreshold {
+		reason := fmt.Sprintf("suspended after %d consecutive %s failures: %s", failure.ConsecutiveFailures, kind, message)
+		failure.SuspendedAt = &now
+		failure.SuspendedReason = &reason
+		suspended = true
+	}
+
+	if err := s.configRepo.RecordFailure(ctx, config.ID, failure); err != nil {
+		return false, err
+	}
+	return suspended, nil
+}
+
+// 自動停止された設定を取得
+func (s *FetchConfigService) GetSuspendedConfigs(ctx context.Context) ([]model.UserFetchConfig, error) {
+	return s.configRepo.GetSuspended(ctx)
+}
+
+// 自動停止された設定を再開する。連続失敗の回数もリセットする。設定が存在しない場合は output.ErrNotFound を返す
+func (s *FetchConfigService) Unsuspend(ctx context.Context, configID uuid.UUID) error {
+	return s.configRepo.Unsuspend(ctx, configID)
+}
+
 // 特定ユーザーの設定を詳細情報付きで取得
 func (s *FetchConfigService) GetUserEnrichedConfigs(ctx context.Context, userID model.UserID) ([]EnrichedFetchConfig, error) {
 	configs, err := s.configRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get configs for user %s: %w", userID, err)
 	}
+	return s.enrichConfigs(ctx, configs), nil
+}
 
+// enrichConfigs はデータソース固有の設定を付与する。取得できない設定や不正な設定は除外する
+func (s *FetchConfigService) enrichConfigs(ctx context.Context, configs []model.UserFetchConfig) []EnrichedFetchConfig {
 	enrichedConfigs := make([]EnrichedFetchConfig, 0, len(configs))
 
 	for _, config := range configs {
 		// データソース固有の検索設定を取得する
 		detail, err := s.getConfigDetail(ctx, config)
 		if err != nil {
-			fmt.Println("error occurred", err)
+			slog.ErrorContext(ctx, "Failed to get config detail",
+				"user_id", config.UserID,
+				"config_id", config.ID,
+				"source", config.DataSourceID,
+				"error", err)
+			continue
+		}
+
+		// 不正な設定はAPIエラーになるだけなので取得対象から外す
+		if err := detail.Validate(); err != nil {
+			slog.ErrorContext(ctx, "Invalid config, skipping",
+				"user_id", config.UserID,
+				"config_id", config.ID,
+				"source", config.DataSourceID,
+				"error", err)
 			continue
 		}
 
@@ -77,7 +246,37 @@ func (s *FetchConfigService) GetUserEnrichedConfigs(ctx context.Context, userID
 		})
 	}
 
-	return enrichedConfigs, nil
+	return enrichedConfigs
+}
+
+// 設定IDを指定して詳細情報付きで取得する。詳細設定が不正な場合はエラーを返す
+func (s *FetchConfigService) GetEnrichedConfig(ctx context.Context, configID uuid.UUID) (*EnrichedFetchConfig, error) {
+	cfg, err := s.GetEnrichedConfigUnvalidated(ctx, configID)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Detail.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", configID, err)
+	}
+	return cfg, nil
+}
+
+// 設定IDを指定して詳細情報付きで取得する。不正な設定を表示・修正するために検証は行わない
+func (s *FetchConfigService) GetEnrichedConfigUnvalidated(ctx context.Context, configID uuid.UUID) (*EnrichedFetchConfig, error) {
+	config, err := s.configRepo.GetByID(ctx, configID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config %s: %w", configID, err)
+	}
+
+	detail, err := s.getConfigDetail(ctx, *config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get detail for config %s: %w", configID, err)
+	}
+
+	return &EnrichedFetchConfig{
+		UserFetchConfig: *config,
+		Detail:          detail,
+	}, nil
 }
 
 // データソースに応じて適切な詳細設定を取得
@@ -89,3 +288,139 @@ func (s *FetchConfigService) getConfigDetail(ctx context.Context, config model.U
 		return nil, fmt.Errorf("unsupported data source: '%s' (length: %d)", config.DataSourceID, len(config.DataSourceID))
 	}
 }
+
+// 条件に一致する設定の一覧を取得
+func (s *FetchConfigService) ListConfigs(ctx context.Context, filter output.FetchConfigFilter) ([]model.UserFetchConfig, error) {
+	return s.configRepo.List(ctx, filter)
+}
+
+// 設定をデータソース固有の設定とともに作成する。書き込む前に内容を検証する
+func (s *FetchConfigService) CreateConfig(ctx context.Context, config *model.UserFetchConfig, detail model.FetchConfigDetail) error {
+	if err := validateConfig(config, detail); err != nil {
+		return err
+	}
+
+	if err := s.configRepo.Create(ctx, config); err != nil {
+		return fmt.Errorf("failed to create config: %w", err)
+	}
+
+	// PostgREST経由ではトランザクションを張れないため、詳細の保存に失敗した場合は親設定を削除して戻す
+	if err := s.saveConfigDetail(ctx, detail, true); err != nil {
+		if derr := s.configRepo.Delete(ctx, config.ID); derr != nil {
+			slog.ErrorContext(ctx, "Failed to roll back config after detail insert failed",
+				"config_id", config.ID, "error", derr)
+		}
+		return fmt.Errorf("failed to create config detail: %w", err)
+	}
+	return nil
+}
+
+// 設定とデータソース固有の設定を更新する。書き込む前に内容を検証する
+func (s *FetchConfigService) UpdateConfig(ctx context.Context, config *model.UserFetchConfig, detail model.FetchConfigDetail) error {
+	if err := validateConfig(config, detail); err != nil {
+		return err
+	}
+
+	config.UpdatedAt = time.Now()
+	if err := s.configRepo.Update(ctx, config); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	if err := s.saveConfigDetail(ctx, detail, false); err != nil {
+		return fmt.Errorf("failed to update config detail: %w", err)
+	}
+	return nil
+}
+
+// 設定の有効・無効を切り替える
+func (s *FetchConfigService) SetActive(ctx context.Context, configID uuid.UUID, active bool) error {
+	config, err := s.configRepo.GetByID(ctx, configID)
+	if err != nil {
+		return fmt.Errorf("failed to get config %s: %w", configID, err)
+	}
+
+	config.IsActive = active
+	config.UpdatedAt = time.Now()
+	return s.configRepo.Update(ctx, config)
+}
+
+// 設定を削除する。データソース固有の設定も削除される
+func (s *FetchConfigService) DeleteConfig(ctx context.Context, configID uuid.UUID) error {
+	return s.configRepo.Delete(ctx, configID)
+}
+
+// データソースに応じて詳細設定を保存
+func (s *FetchConfigService) saveConfigDetail(ctx context.Context, detail model.FetchConfigDetail, create bool) error {
+	switch d := detail.(type) {
+	case *model.RedditFetchConfigDetail:
+		if create {
+			return s.redditFetchConfigRepo.Create(ctx, d)
+		}
+		return s.redditFetchConfigRepo.Update(ctx, d)
+	default:
+		return fmt.Errorf("unsupported data source: '%s'", detail.GetDataSourceID())
+	}
+}
+
+// ConfigValidationResult は設定1件の検証結果
+type ConfigValidationResult struct {
+	Config model.UserFetchConfig
+	// Problems は見つかった問題。空の場合は問題なし
+	Problems []error
+}
+
+// 条件に一致するすべての設定（無効・停止中を含む）を検証する
+func (s *FetchConfigService) ValidateConfigs(ctx context.Context, filter output.FetchConfigFilter) ([]ConfigValidationResult, error) {
+	configs, err := s.configRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ConfigValidationResult, 0, len(configs))
+	for _, config := range configs {
+		result := ConfigValidationResult{Config: config}
+
+		detail, err := s.getConfigDetail(ctx, config)
+		if err != nil {
+			result.Problems = []error{fmt.Errorf("failed to load source settings: %w", err)}
+		} else if err := validateConfig(&config, detail); err != nil {
+			result.Problems = model.ValidationErrors(errors.Unwrap(err))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// validateConfig は設定を書き込む前に、共通項目とデータソース固有の項目を検証する
+func validateConfig(config *model.UserFetchConfig, detail model.FetchConfigDetail) error {
+	var errs []error
+	if config.UserID == uuid.Nil {
+		errs = append(errs, &model.ValidationError{Field: "user_id", Message: "is required"})
+	}
+	if config.Name == "" {
+		errs = append(errs, &model.ValidationError{Field: "name", Message: "is required"})
+	}
+	if _, err := model.ParseFetchSchedule(config.Schedule); err != nil {
+		errs = append(errs, &model.ValidationError{Field: "schedule", Message: err.Error()})
+	}
+
+	if detail == nil {
+		errs = append(errs, fmt.Errorf("settings for data source '%s' are required", config.DataSourceID))
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	if detail.GetDataSourceID() != config.DataSourceID {
+		errs = append(errs, fmt.Errorf("data source mismatch: config is '%s' but settings are for '%s'",
+			config.DataSourceID, detail.GetDataSourceID()))
+	}
+	if detail.GetUserFetchConfigID() != config.ID {
+		errs = append(errs, &model.ValidationError{Field: "user_fetch_config_id", Message: "settings do not belong to this config"})
+	}
+
+	if err := detail.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+// FetchRunLockName は feedle fetch の実行全体を排他するロック名
+const FetchRunLockName = "fetch"
+
+var (
+	// ErrRunLocked は他のプロセスがロックを保持しているため取得できなかったことを表す
+	ErrRunLocked = errors.New("run lock is held by another process")
+	// ErrRunLockLost は保持していたロックを延長できず、他のプロセスに引き継がれた可能性があることを表す
+	ErrRunLockLost = errors.New("run lock was lost")
+)
+
+type RunLockService struct {
+	repo output.RunLockRepository
+}
+
+type RunLockOptions struct {
+	// TTL はロックの有効期間。保持中はTTL/3ごとに延長し、プロセスが落ちた場合はTTL経過後に引き継がれる
+	TTL time.Duration
+	// Wait がtrueの場合は取得できるまで待つ。falseの場合は即座にErrRunLockedを返す
+	Wait bool
+	// WaitTimeout は Wait 時に待つ最大時間。0の場合は無制限
+	WaitTimeout time.Duration
+	// RetryInterval は Wait 時の再試行間隔
+	RetryInterval time.Duration
+}
+
+// HeldRunLock は取得済みのロック。Releaseを呼ぶまで自動的に期限を延長する。
+// 延長できずにロックを失った場合は Lost が閉じられる
+type HeldRunLock struct {
+	name   string
+	holder string
+	repo   output.RunLockRepository
+	stop   context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+
+	lost     chan struct{}
+	lostErr  error
+	lostOnce sync.Once
+}
+
+func NewRunLockService(repo output.RunLockRepository) *RunLockService {
+	return &RunLockService{
+		repo: repo,
+	}
+}
+
+// Acquire はロックを取得する。期限切れのロックを引き継いだ場合は前の保持者をログに残す
+func (s *RunLockService) Acquire(ctx context.Context, name string, opts RunLockOptions) (*HeldRunLock, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = 10 * time.Minute
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 10 * time.Second
+	}
+
+	holder := newLockHolder()
+
+	var deadline <-chan time.Time
+	if opts.Wait && opts.WaitTimeout > 0 {
+		timer := time.NewTimer(opts.WaitTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		result, err := s.repo.TryAcquire(ctx, name, holder, opts.TTL)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Acquired {
+			if result.TakenOverFrom != nil {
+				slog.WarnContext(ctx, "Took over stale run lock", "lock", name, "previous_holder", *result.TakenOverFrom)
+			}
+			return s.hold(name, holder, opts.TTL), nil
+		}
+
+		if !opts.Wait {
+			return nil, fmt.Errorf("%w: %q held by %s until %s", ErrRunLocked, name,
+				result.Lock.Holder, result.Lock.ExpiresAt.Format(time.RFC3339))
+		}
+
+		slog.InfoContext(ctx, "Run lock is held by another process, waiting", "lock", name, "holder", result.Lock.Holder)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, fmt.Errorf("%w: timed out after %s waiting for %q", ErrRunLocked, opts.WaitTimeout, name)
+		case <-time.After(opts.RetryInterval):
+		}
+	}
+}
+
+func (s *RunLockService) hold(name, holder string, ttl time.Duration) *HeldRunLock {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &HeldRunLock{
+		name:   name,
+		holder: holder,
+		repo:   s.repo,
+		stop:   cancel,
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		refreshed := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// 応答しない延長で喪失の判定が遅れないよう、延長の周期より短い時間で打ち切る
+				refreshCtx, cancel := context.WithTimeout(ctx, ttl/4)
+				err := s.repo.Refresh(refreshCtx, name, holder, ttl)
+				cancel()
+				switch {
+				case err == nil:
+					refreshed = time.Now()
+				case ctx.Err() != nil:
+					return
+				case errors.Is(err, output.ErrLockNotHeld):
+					l.markLost(err)
+					return
+				case time.Since(refreshed) >= ttl:
+					// 延長できないまま期限が過ぎたため、他のプロセスが引き継いでいるかもしれない
+					l.markLost(fmt.Errorf("not refreshed for %s: %w", ttl, err))
+					return
+				default:
+					// 期限までに延長できれば保持し続けられるため、次の周期で再試行する
+					slog.WarnContext(ctx, "Failed to refresh run lock, retrying", "lock", name, "error", err)
+				}
+			}
+		}
+	}()
+
+	return l
+}
+
+func (l *HeldRunLock) markLost(err error) {
+	l.lostOnce.Do(func() {
+		l.lostErr = fmt.Errorf("%w: %q: %w", ErrRunLockLost, l.name, err)
+		slog.Error("Lost run lock", "lock", l.name, "holder", l.holder, "error", err)
+		close(l.lost)
+	})
+}
+
+// Lost はロックを失った場合に閉じられる
+func (l *HeldRunLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err はロックを失った理由を返す。保持している間はnilを返す
+func (l *HeldRunLock) Err() error {
+	select {
+	case <-l.lost:
+		return l.lostErr
+	default:
+		return nil
+	}
+}
+
+// Bind はロックを失った時点でキャンセルされるcontextを返す。ロックで守る処理はこのcontextで実行する
+func (l *HeldRunLock) Bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-l.lost:
+			cancel(l.lostErr)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+func (l *HeldRunLock) Holder() string {
+	return l.holder
+}
+
+// Release は期限の延長を止めてロックを解放する
+func (l *HeldRunLock) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		l.stop()
+		<-l.done
+		err = l.repo.Release(ctx, l.name, l.holder)
+	})
+	return err
+}
+
+// newLockHolder はログから実行元を特定できるロック保持者IDを生成する
+func newLockHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fake"
+)
+
+// clock は fake.RunLockRepository の現在時刻を進めるためのテスト用の時計
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// hangingRefresh は延長の要求に応答しないリポジトリ。ctxが終わるまで戻らない
+type hangingRefresh struct {
+	*fake.RunLockRepository
+}
+
+func (r hangingRefresh) Refresh(ctx context.Context, name, holder string, ttl time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// failingRefresh は延長のたびに一時的なエラーを返すリポジトリ
+type failingRefresh struct {
+	*fake.RunLockRepository
+}
+
+func (r failingRefresh) Refresh(ctx context.Context, name, holder string, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func acquire(t *testing.T, s *RunLockService, opts RunLockOptions) *HeldRunLock {
+	t.Helper()
+	lock, err := s.Acquire(context.Background(), FetchRunLockName, opts)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	t.Cleanup(func() { _ = lock.Release(context.Background()) })
+	return lock
+}
+
+func waitLost(t *testing.T, lock *HeldRunLock, within time.Duration) {
+	t.Helper()
+	select {
+	case <-lock.Lost():
+	case <-time.After(within):
+		t.Fatalf("lock was not marked lost within %v", within)
+	}
+}
+
+func TestRunLockServiceAcquire(t *testing.T) {
+	repo := fake.NewRunLockRepository()
+	s := NewRunLockService(repo)
+
+	first := acquire(t, s, RunLockOptions{TTL: time.Minute})
+
+	_, err := s.Acquire(context.Background(), FetchRunLockName, RunLockOptions{TTL: time.Minute})
+	if !errors.Is(err, ErrRunLocked) {
+		t.Fatalf("second Acquire error = %v, want ErrRunLocked", err)
+	}
+
+	if err := first.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	second := acquire(t, s, RunLockOptions{TTL: time.Minute})
+	if second.Holder() == first.Holder() {
+		t.Errorf("holder %q was reused", second.Holder())
+	}
+}
+
+func TestRunLockServiceAcquireWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		release bool
+		wantErr error
+	}{
+		{"解放されたら取得する", true, nil},
+		{"解放されなければ待つ時間を過ぎて諦める", false, ErrRunLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRunLockService(fake.NewRunLockRepository())
+			held := acquire(t, s, RunLockOptions{TTL: time.Minute})
+			if tt.release {
+				time.AfterFunc(30*time.Millisecond, func() { _ = held.Release(context.Background()) })
+			}
+
+			lock, err := s.Acquire(context.Background(), FetchRunLockName, RunLockOptions{
+				TTL:           time.Minute,
+				Wait:          true,
+				WaitTimeout:   300 * time.Millisecond,
+				RetryInterval: 10 * time.Millisecond,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Acquire error = %v, want %v", err, tt.wantErr)
+			}
+			if lock != nil {
+				_ = lock.Release(context.Background())
+			}
+		})
+	}
+}
+
+// プロセスが落ちて延長されなくなったロックは、期限が過ぎたら引き継げる
+func TestRunLockServiceTakesOverExpiredLock(t *testing.T) {
+	c := &clock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	repo := fake.NewRunLockRepository()
+	repo.Now = c.Now
+	s := NewRunLockService(repo)
+
+	if _, err := repo.TryAcquire(context.Background(), FetchRunLockName, "crashed", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Acquire(context.Background(), FetchRunLockName, RunLockOptions{TTL: time.Minute}); !errors.Is(err, ErrRunLocked) {
+		t.Fatalf("Acquire before expiry error = %v, want ErrRunLocked", err)
+	}
+
+	c.Advance(time.Minute + time.Second)
+	lock := acquire(t, s, RunLockOptions{TTL: time.Minute})
+	if lock.Holder() == "crashed" {
+		t.Errorf("holder = %q, want a new holder", lock.Holder())
+	}
+}
+
+// 保持している間は期限を延長し続け、他のプロセスに引き継がれない
+func TestRunLockServiceRefreshesHeldLock(t *testing.T) {
+	c := &clock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	repo := fake.NewRunLockRepository()
+	repo.Now = c.Now
+	s := NewRunLockService(repo)
+
+	const ttl = 60 * time.Millisecond
+	lock := acquire(t, s, RunLockOptions{TTL: ttl})
+
+	// 延長されなければ期限が切れる時間を、延長の周期より細かく進める
+	for range 10 {
+		time.Sleep(ttl / 3)
+		c.Advance(ttl / 3)
+	}
+	if _, err := s.Acquire(context.Background(), FetchRunLockName, RunLockOptions{TTL: ttl}); !errors.Is(err, ErrRunLocked) {
+		t.Fatalf("Acquire by another process error = %v, want ErrRunLocked", err)
+	}
+	if err := lock.Err(); err != nil {
+		t.Errorf("Err = %v, want the lock still held", err)
+	}
+}
+
+func TestRunLockServiceDetectsLostLock(t *testing.T) {
+	const ttl = 60 * time.Millisecond
+	tests := []struct {
+		name string
+		repo func(*fake.RunLockRepository) output.RunLockRepository
+		// steal がtrueの場合は他のプロセスにロックを引き継がせる
+		steal bool
+		want  error
+	}{
+		{
+			name:  "他のプロセスに引き継がれた",
+			repo:  func(r *fake.RunLockRepository) output.RunLockRepository { return r },
+			steal: true,
+			want:  output.ErrLockNotHeld,
+		},
+		{
+			name: "延長が応答しないまま期限が過ぎた",
+			repo: func(r *fake.RunLockRepository) output.RunLockRepository { return hangingRefresh{r} },
+			want: context.DeadlineExceeded,
+		},
+		{
+			name: "延長の失敗が続いて期限が過ぎた",
+			repo: func(r *fake.RunLockRepository) output.RunLockRepository { return failingRefresh{r} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := fake.NewRunLockRepository()
+			s := NewRunLockService(tt.repo(repo))
+			lock := acquire(t, s, RunLockOptions{TTL: ttl})
+			ctx, cancel := lock.Bind(context.Background())
+			defer cancel()
+
+			if tt.steal {
+				if err := repo.Release(context.Background(), FetchRunLockName, lock.Holder()); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := repo.TryAcquire(context.Background(), FetchRunLockName, "other", time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// 延長の周期を考えても、TTLを少し過ぎたところで喪失を判定できる。負荷による遅れを見込んで余裕を持たせる
+			waitLost(t, lock, 3*ttl)
+			if err := lock.Err(); !errors.Is(err, ErrRunLockLost) || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Errorf("Err = %v, want ErrRunLockLost wrapping %v", err, tt.want)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+				t.Fatal("bound context was not cancelled")
+			}
+			if cause := context.Cause(ctx); !errors.Is(cause, ErrRunLockLost) {
+				t.Errorf("bound context cause = %v, want ErrRunLockLost", cause)
+			}
+		})
+	}
+}
+
+func TestRunLockServiceRelease(t *testing.T) {
+	repo := fake.NewRunLockRepository()
+	s := NewRunLockService(repo)
+	lock := acquire(t, s, RunLockOptions{TTL: time.Minute})
+	ctx, cancel := lock.Bind(context.Background())
+	defer cancel()
+
+	if err := lock.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := lock.Release(context.Background()); err != nil {
+		t.Errorf("second Release: %v", err)
+	}
+	if err := repo.Refresh(context.Background(), FetchRunLockName, lock.Holder(), time.Minute); !errors.Is(err, output.ErrLockNotHeld) {
+		t.Errorf("Refresh after Release error = %v, want ErrLockNotHeld", err)
+	}
+	if err := lock.Err(); err != nil {
+		t.Errorf("Err after Release = %v, want nil", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("bound context was cancelled by Release: %v", context.Cause(ctx))
+	}
+}
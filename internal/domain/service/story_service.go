@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+// StoryService は異なるデータソース・設定で取得した同じ記事を、ユーザーごとに1つのストーリーにまとめる
+type StoryService struct {
+	storyRepository output.StoryRepository
+	resolver        output.URLResolver
+}
+
+// NewStoryService はサービスを作る。resolverがnilの場合は短縮URLを展開しない
+func NewStoryService(repo output.StoryRepository, resolver output.URLResolver) *StoryService {
+	return &StoryService{
+		storyRepository: repo,
+		resolver:        resolver,
+	}
+}
+
+// LinkStories は各アイテムの正規化したURLを確定し、ストーリーに紐付けてStoryIDを設定する。
+// 紐付けられなかったアイテムもそのまま保存できるよう、失敗はまとめて返すだけで処理は続ける
+func (s *StoryService) LinkStories(ctx context.Context, userID uuid.UUID, items []*model.FetchedData) (int, error) {
+	var errs []error
+	linked := 0
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		canonical, err := s.canonicalURL(ctx, item)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("item %s: %w", item.ID, err))
+			continue
+		}
+		item.CanonicalURL = canonical
+
+		storyID, err := s.storyRepository.Link(ctx, userID, canonical, item.Title, model.NewStoryDiscussion(item))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("item %s: %w", item.ID, err))
+			continue
+		}
+		item.StoryID = &storyID
+		linked++
+	}
+	return linked, errors.Join(errs...)
+}
+
+// canonicalURL はフェッチャーが設定したURL（なければアイテムのURL）を正規化する。
+// 短縮URLはリダイレクト先を調べてから正規化し、調べられない場合は短縮URLのまま使う
+func (s *StoryService) canonicalURL(ctx context.Context, item *model.FetchedData) (string, error) {
+	raw := item.CanonicalURL
+	if raw == "" {
+		raw = item.URL
+	}
+	if raw == "" {
+		return "", errors.New("item has no URL")
+	}
+
+	if s.resolver != nil && model.IsShortenerURL(raw) {
+		resolved, err := s.resolver.Resolve(ctx, raw)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to resolve short URL", "url", raw, "error", err)
+		} else {
+			raw = resolved
+		}
+	}
+	return model.CanonicalizeURL(raw)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fake"
+	"github.com/google/uuid"
+)
+
+// mapResolver は登録したURLのリダイレクト先を返す。登録していないURLはエラーにする
+type mapResolver map[string]string
+
+func (r mapResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	if resolved, ok := r[rawURL]; ok {
+		return resolved, nil
+	}
+	return "", errors.New("no such short URL")
+}
+
+func storyItem(source, url string) *model.FetchedData {
+	return &model.FetchedData{ID: uuid.New(), ConfigID: uuid.New(), Source: source, URL: url, Title: source + " post"}
+}
+
+func TestStoryServiceLinkStories(t *testing.T) {
+	repo := fake.NewStoryRepository()
+	s := NewStoryService(repo, mapResolver{"https://bit.ly/a": "https://www.example.com/article?utm_source=bitly"})
+	userID := uuid.New()
+
+	// フェッチャーが設定したリンク先のURLを投稿のURLより優先する
+	fromReddit := storyItem("reddit", "https://reddit.com/r/golang/comments/abc")
+	fromReddit.CanonicalURL = "https://example.com/article/?utm_source=reddit#top"
+	shortened := storyItem("hackernews", "https://bit.ly/a")
+	unresolved := storyItem("rss", "https://t.co/unknown")
+	noURL := storyItem("rss", "")
+
+	linked, err := s.LinkStories(context.Background(), userID, []*model.FetchedData{fromReddit, nil, shortened, unresolved, noURL})
+	if err == nil {
+		t.Error("LinkStories did not report the item without a URL")
+	}
+	if linked != 3 {
+		t.Errorf("linked = %d, want 3", linked)
+	}
+
+	// 同じ記事を指すアイテムは同じストーリーになる
+	for _, item := range []*model.FetchedData{fromReddit, shortened} {
+		if item.CanonicalURL != "https://example.com/article" {
+			t.Errorf("%s: CanonicalURL = %s, want https://example.com/article", item.Source, item.CanonicalURL)
+		}
+	}
+	if fromReddit.StoryID == nil || shortened.StoryID == nil || *fromReddit.StoryID != *shortened.StoryID {
+		t.Errorf("items for the same article got stories %v and %v", fromReddit.StoryID, shortened.StoryID)
+	}
+	// 展開できない短縮URLはそのまま使う
+	if unresolved.CanonicalURL != "https://t.co/unknown" || unresolved.StoryID == nil {
+		t.Errorf("unresolved short URL: CanonicalURL %s, story %v", unresolved.CanonicalURL, unresolved.StoryID)
+	}
+	if noURL.StoryID != nil {
+		t.Errorf("item without a URL was linked to %s", *noURL.StoryID)
+	}
+
+	stories := repo.All()
+	if len(stories) != 2 {
+		t.Fatalf("stories = %d, want 2", len(stories))
+	}
+	article := stories[0]
+	if article.CanonicalURL != "https://example.com/article" || len(article.Discussions) != 2 {
+		t.Fatalf("article story = %s with %d discussions, want 2", article.CanonicalURL, len(article.Discussions))
+	}
+	if d := article.Discussions[0]; d.Source != "reddit" || d.URL != fromReddit.URL || d.ItemID != fromReddit.ID {
+		t.Errorf("discussion = %+v, want the reddit post", d)
+	}
+}
+
+func TestStoryServiceLinkStoriesPerUser(t *testing.T) {
+	repo := fake.NewStoryRepository()
+	s := NewStoryService(repo, nil)
+
+	first, second := storyItem("reddit", "https://example.com/a"), storyItem("reddit", "https://example.com/a")
+	for _, item := range []*model.FetchedData{first, second} {
+		if _, err := s.LinkStories(context.Background(), uuid.New(), []*model.FetchedData{item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *first.StoryID == *second.StoryID {
+		t.Error("items of different users share a story")
+	}
+
+	// 同じ投稿を再び紐付けても議論のリンクは増えない
+	userID := uuid.New()
+	for range 2 {
+		if _, err := s.LinkStories(context.Background(), userID, []*model.FetchedData{storyItem("reddit", "https://example.com/b")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, story := range repo.All() {
+		if story.CanonicalURL == "https://example.com/b" && len(story.Discussions) != 1 {
+			t.Errorf("story has %d discussions for the same post, want 1", len(story.Discussions))
+		}
+	}
+}
+
+// 展開しない設定では短縮URLに通信しない
+func TestStoryServiceLinkStoriesWithoutResolver(t *testing.T) {
+	s := NewStoryService(fake.NewStoryRepository(), nil)
+	item := storyItem("reddit", "https://bit.ly/a?utm_source=x")
+
+	if _, err := s.LinkStories(context.Background(), uuid.New(), []*model.FetchedData{item}); err != nil {
+		t.Fatal(err)
+	}
+	if item.CanonicalURL != "https://bit.ly/a" {
+		t.Errorf("CanonicalURL = %s, want the short URL", item.CanonicalURL)
+	}
+}
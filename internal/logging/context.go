@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// 共通のログ属性キー
+const (
+	KeyRunID    = "run_id"
+	KeyUserID   = "user_id"
+	KeyConfigID = "config_id"
+	KeySource   = "source"
+	KeyTraceID  = "trace_id"
+	KeySpanID   = "span_id"
+)
+
+// WithRunID は実行IDをログ属性に追加する
+func WithRunID(ctx context.Context, runID uuid.UUID) context.Context {
+	return With(ctx, KeyRunID, runID.String())
+}
+
+// WithConfig は処理中の設定のユーザーID・設定ID・データソースをログ属性に追加する
+func WithConfig(ctx context.Context, userID, configID uuid.UUID, source string) context.Context {
+	return With(ctx,
+		KeyUserID, userID.String(),
+		KeyConfigID, configID.String(),
+		KeySource, source,
+	)
+}
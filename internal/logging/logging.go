@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type ctxKey struct{}
+
+// contextHandler はcontextに保持した属性（run_id, config_id等）とトレースIDをすべてのログに付与する
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String(KeyTraceID, sc.TraceID().String()),
+			slog.String(KeySpanID, sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// NewLogger は指定した形式・レベルのロガーを作る
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected %q or %q)", format, FormatText, FormatJSON)
+	}
+
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// Setup はデフォルトロガーを設定する。log パッケージの出力もこのロガーに流れる
+func Setup(w io.Writer, format, level string) error {
+	logger, err := NewLogger(w, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// With はcontextにログ属性を追加する。同じキーは後から追加した値で上書きされる
+func With(ctx context.Context, args ...any) context.Context {
+	var existing []slog.Attr
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		existing = attrs
+	}
+
+	added := argsToAttrs(args)
+	merged := make([]slog.Attr, 0, len(existing)+len(added))
+	for _, a := range existing {
+		if !containsKey(added, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	merged = append(merged, added...)
+
+	return context.WithValue(ctx, ctxKey{}, merged)
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+func containsKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "feedle"
+
+// Registry はfeedleのメトリクスを保持する。デーモンモードでは /metrics で公開し、
+// バッチモードでは実行終了時にPushgatewayへ送信する
+var Registry = prometheus.NewRegistry()
+
+// runtimeRegistry はGoランタイムとプロセスのメトリクスを保持する。/metrics でのみ公開し、
+// 短時間で終わるバッチの値でPushgatewayの値を上書きしないようPushgatewayには送信しない
+var runtimeRegistry = prometheus.NewRegistry()
+
+var (
+	// SourceRequests はデータソースへのHTTPリクエスト数（ステータスコード別）
+	SourceRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "source_requests_total",
+		Help:      "HTTP requests sent to a data source, by status code.",
+	}, []string{"source", "code"})
+
+	// SourceRequestDuration はデータソースへのHTTPリクエストの所要時間
+	SourceRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "source_request_duration_seconds",
+		Help:      "Latency of HTTP requests sent to a data source.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source"})
+
+	// FetchDuration は設定1件分の取得（ページングを含む）の所要時間
+	FetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Time spent fetching a single config, including pagination.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"source", "result"})
+
+	ItemsFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_fetched_total",
+		Help:      "Items returned by fetchers.",
+	}, []string{"source"})
+
+	ItemsSaved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_saved_total",
+		Help:      "Items written to the database.",
+	}, []string{"source"})
+
+	// ItemsSkipped は取得したが保存しなかった件数
+	ItemsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_skipped_total",
+		Help:      "Fetched items that were not saved.",
+	}, []string{"source"})
+
+	// ItemsNearDuplicate は以前のアイテムとほぼ同じ内容と判定した件数（保存はする）
+	ItemsNearDuplicate = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_near_duplicate_total",
+		Help:      "Fetched items whose content nearly matches an earlier item in the same user's feed.",
+	}, []string{"source"})
+
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Access token refreshes, by result.",
+	}, []string{"source", "result"})
+
+	// Retries はジョブキューで再試行に回されたジョブ数
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retries_total",
+		Help:      "Fetch jobs scheduled for retry after a failure, by error kind.",
+	}, []string{"kind"})
+
+	ConfigsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "configs_processed_total",
+		Help:      "Configs processed, by data source and result.",
+	}, []string{"source", "result"})
+
+	// HTTPCacheResults はHTTPキャッシュの利用結果（miss, hit, revalidated）
+	HTTPCacheResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_cache_results_total",
+		Help:      "HTTP cache lookups by data source, by result.",
+	}, []string{"source", "result"})
+)
+
+func init() {
+	runtimeRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	Registry.MustRegister(
+		SourceRequests,
+		SourceRequestDuration,
+		FetchDuration,
+		ItemsFetched,
+		ItemsSaved,
+		ItemsSkipped,
+		ItemsNearDuplicate,
+		TokenRefreshes,
+		Retries,
+		ConfigsProcessed,
+		HTTPCacheResults,
+	)
+}
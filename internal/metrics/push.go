@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Handler は /metrics 用のハンドラ。feedleのメトリクスに加えてランタイムのメトリクスも公開する
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{Registry, runtimeRegistry}, promhttp.HandlerOpts{})
+}
+
+// DefaultPushInstance は instance を指定しない場合のグループ名
+const DefaultPushInstance = "default"
+
+// Push はPushgateway互換のエンドポイントにfeedleのメトリクスを送信する。
+// 同じjob/instanceのメトリクスは置き換えられる。Pushgatewayはグループを削除しないため、
+// instance にはホスト名のように実行ごとに変わる値ではなく固定の値を使う。空の場合は DefaultPushInstance
+func Push(ctx context.Context, url, job, instance string) error {
+	if instance == "" {
+		instance = DefaultPushInstance
+	}
+
+	err := push.New(url, job).
+		Gatherer(Registry).
+		Grouping("instance", instance).
+		PushContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pushgateway はPushgatewayの代わりに送信されたリクエストを記録する
+type pushgateway struct {
+	method string
+	path   string
+	body   string
+}
+
+func newPushgateway(t *testing.T) (*pushgateway, *httptest.Server) {
+	t.Helper()
+	gw := &pushgateway{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read push body: %v", err)
+		}
+		gw.method, gw.path, gw.body = r.Method, r.URL.Path, string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return gw, server
+}
+
+func TestPushSendsOnlyBatchMetrics(t *testing.T) {
+	gw, server := newPushgateway(t)
+	ItemsSaved.WithLabelValues("reddit").Add(3)
+
+	if err := Push(context.Background(), server.URL, "feedle_batch", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if gw.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", gw.method)
+	}
+	// 本文はprotobufの区切り形式のため、メトリクス名が含まれるかで確認する
+	if !strings.Contains(gw.body, "feedle_items_saved_total") {
+		t.Error("pushed metrics do not include feedle_items_saved_total")
+	}
+	for _, name := range []string{"go_goroutines", "process_cpu_seconds_total"} {
+		if strings.Contains(gw.body, name) {
+			t.Errorf("pushed metrics include runtime metric %s", name)
+		}
+	}
+}
+
+// 実行ごとに同じグループを置き換えるよう、instanceは実行環境によらず固定の値にする
+func TestPushGrouping(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+		want     string
+	}{
+		{"指定しない場合は固定の値", "", "/metrics/job/feedle_batch/instance/default"},
+		{"指定した値", "prod", "/metrics/job/feedle_batch/instance/prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, server := newPushgateway(t)
+			if err := Push(context.Background(), server.URL, "feedle_batch", tt.instance); err != nil {
+				t.Fatal(err)
+			}
+			if gw.path != tt.want {
+				t.Errorf("path = %s, want %s", gw.path, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushReportsGatewayErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if err := Push(context.Background(), server.URL, "feedle_batch", ""); err == nil {
+		t.Fatal("Push() succeeded against a failing gateway")
+	}
+}
+
+func TestHandlerIncludesRuntimeMetrics(t *testing.T) {
+	ItemsSaved.WithLabelValues("reddit").Add(1)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, name := range []string{"feedle_items_saved_total", "go_goroutines"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("/metrics does not include %s", name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// instrumentedTransport はデータソースへのリクエスト数と所要時間を記録する
+type instrumentedTransport struct {
+	source string
+	next   http.RoundTripper
+}
+
+// InstrumentRoundTripper はリクエストをデータソース名のラベル付きで計測するRoundTripperを返す
+func InstrumentRoundTripper(source string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{source: source, next: next}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	SourceRequestDuration.WithLabelValues(t.source).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	SourceRequests.WithLabelValues(t.source, code).Inc()
+
+	return resp, err
+}
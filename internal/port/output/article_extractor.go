@@ -0,0 +1,20 @@
+package output
+
+import (
+	"context"
+	"errors"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+var (
+	// ErrRobotsDisallowed はrobots.txtで取得が許可されていない場合に返す
+	ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+	// ErrNotArticle はリンク先が記事として読めるページでない（HTMLでない、存在しない、大きすぎる）場合に返す
+	ErrNotArticle = errors.New("not an article page")
+)
+
+// ArticleExtractor はリンク先のページを取得して記事の本文とメタデータを取り出す
+type ArticleExtractor interface {
+	Extract(ctx context.Context, pageURL string) (*model.Article, error)
+}
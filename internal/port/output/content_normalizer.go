@@ -0,0 +1,14 @@
+package output
+
+import (
+	"context"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// ContentNormalizer はデータソースごとの形式（Markdown、HTML）の本文から、
+// 表示用のサニタイズ済みHTML・プレーンテキスト・抜粋・リンクと画像を作る
+type ContentNormalizer interface {
+	// Normalize はアイテムの正規化した本文のフィールドを設定する。Content は変更しない
+	Normalize(ctx context.Context, item *model.FetchedData) error
+}
@@ -0,0 +1,12 @@
+package output
+
+import "errors"
+
+// ErrNotFound は更新・削除の対象が存在しない場合に返す
+var ErrNotFound = errors.New("not found")
+
+// ErrLockNotHeld はロックの延長・解放時に、ロックが期限切れで他の保持者に移っていた場合に返す
+var ErrLockNotHeld = errors.New("lock is not held")
+
+// ErrLeaseLost はジョブのleaseの延長・完了・失敗の記録時に、leaseが期限切れで他のワーカーに移っていた場合に返す
+var ErrLeaseLost = errors.New("job lease is no longer held")
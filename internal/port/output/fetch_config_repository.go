@@ -2,10 +2,37 @@ package output
 
 import (
 	"context"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
 )
 
+// FetchConfigFilter は設定一覧の絞り込み条件。ゼロ値の項目は条件に含めない
+type FetchConfigFilter struct {
+	UserID       *uuid.UUID
+	DataSourceID string
+	// ActiveOnly は有効かつ停止されていない設定のみに絞る
+	ActiveOnly bool
+}
+
 type FetchConfigRepository interface {
+	// GetByUserID はユーザーの有効かつ停止されていない設定を返す
 	GetByUserID(ctx context.Context, userID model.UserID) ([]model.UserFetchConfig, error)
+	GetByID(ctx context.Context, configID uuid.UUID) (*model.UserFetchConfig, error)
+	// List は条件に一致する設定を作成日時の古い順に返す
+	List(ctx context.Context, filter FetchConfigFilter) ([]model.UserFetchConfig, error)
+	Create(ctx context.Context, config *model.UserFetchConfig) error
+	// Update は名前・有効状態・スケジュールを更新する
+	Update(ctx context.Context, config *model.UserFetchConfig) error
+	// Delete は設定を削除する。データソース固有の設定も外部キーのCASCADEで削除される
+	Delete(ctx context.Context, configID uuid.UUID) error
+	// GetSuspended は連続失敗により自動停止された設定を返す
+	GetSuspended(ctx context.Context) ([]model.UserFetchConfig, error)
+	// RecordSuccess は取得成功時刻を記録し、連続失敗の状況をリセットする
+	RecordSuccess(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) error
+	// RecordFailure は連続失敗の状況（停止する場合は停止理由）を保存する
+	RecordFailure(ctx context.Context, configID uuid.UUID, failure model.FetchFailure) error
+	// Unsuspend は自動停止を解除し、連続失敗の状況をリセットする。設定が存在しない場合は ErrNotFound を返す
+	Unsuspend(ctx context.Context, configID uuid.UUID) error
 }
@@ -0,0 +1,22 @@
+package output
+
+import (
+	"context"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// FetchJobQueue は複数ワーカーで取得処理を分担するためのジョブキュー
+type FetchJobQueue interface {
+	// Enqueue は設定のジョブを追加する。同じ設定の未完了ジョブがある場合は追加せずfalseを返す
+	Enqueue(ctx context.Context, configID uuid.UUID, maxAttempts int) (bool, error)
+	// Claim は実行可能なジョブを最大limit件取得し、leaseの期間workerIDに割り当てる
+	Claim(ctx context.Context, workerID string, limit int, lease time.Duration) ([]model.FetchJob, error)
+	// Heartbeat は実行中のジョブのleaseを延長する
+	Heartbeat(ctx context.Context, jobID uuid.UUID, workerID string, lease time.Duration) error
+	Complete(ctx context.Context, jobID uuid.UUID, workerID string) error
+	// Fail はジョブを失敗として記録し、試行回数が残っていればretryAfter後に再実行させる
+	Fail(ctx context.Context, jobID uuid.UUID, workerID string, reason string, retryAfter time.Duration) error
+}
@@ -0,0 +1,21 @@
+package output
+
+import (
+	"context"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// FetchRunRepository は実行履歴と設定ごとの進捗（チェックポイント）を管理する
+type FetchRunRepository interface {
+	Create(ctx context.Context, run *model.FetchRun) error
+	Update(ctx context.Context, run *model.FetchRun) error
+	GetByID(ctx context.Context, runID uuid.UUID) (*model.FetchRun, error)
+	// List は開始時刻の新しい順に最大limit件を返す
+	List(ctx context.Context, limit int) ([]model.FetchRun, error)
+
+	CreateItems(ctx context.Context, items []model.FetchRunItem) error
+	UpdateItem(ctx context.Context, item *model.FetchRunItem) error
+	GetItems(ctx context.Context, runID uuid.UUID) ([]model.FetchRunItem, error)
+}
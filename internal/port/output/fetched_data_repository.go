@@ -2,10 +2,16 @@ package output
 
 import (
 	"context"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
 )
 
 type FetchedDataRepository interface {
 	Create(ctx context.Context, data *model.FetchedData) error
+	// ExistingIDs は ids のうち既に保存されているものを返す。再取得したアイテムを保存し直さないために使う
+	ExistingIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]bool, error)
+	// ListRecentFingerprints はユーザーのすべての設定で since 以降に取得したアイテムの指紋を返す
+	ListRecentFingerprints(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.ItemFingerprint, error)
 }
@@ -9,4 +9,7 @@ import (
 
 type RedditFetchConfigRepository interface {
 	GetByUserFetchConfigID(ctx context.Context, userFetchConfigID uuid.UUID) (*model.RedditFetchConfigDetail, error)
+	Create(ctx context.Context, detail *model.RedditFetchConfigDetail) error
+	// Update は親設定のIDをキーに検索条件を更新する
+	Update(ctx context.Context, detail *model.RedditFetchConfigDetail) error
 }
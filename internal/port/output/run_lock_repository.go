@@ -0,0 +1,17 @@
+package output
+
+import (
+	"context"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+// RunLockRepository はバッチ実行単位の分散ロックを管理する
+type RunLockRepository interface {
+	// TryAcquire はロックの取得を1回試みる。期限切れのロックは引き継ぐ
+	TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (*model.RunLockAcquisition, error)
+	// Refresh は保持しているロックの期限を延長する
+	Refresh(ctx context.Context, name, holder string, ttl time.Duration) error
+	Release(ctx context.Context, name, holder string) error
+}
@@ -0,0 +1,15 @@
+package output
+
+import (
+	"context"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// StoryRepository は同じ記事を指すアイテムをユーザーごとにまとめたストーリーを管理する
+type StoryRepository interface {
+	// Link はユーザーと正規化したURLに対応するストーリーを作成または取得し、議論のリンクを追加してIDを返す。
+	// 同じURLのリンクが既にある場合は追加しない
+	Link(ctx context.Context, userID uuid.UUID, canonicalURL, title string, discussion model.StoryDiscussion) (uuid.UUID, error)
+}
@@ -0,0 +1,9 @@
+package output
+
+import "context"
+
+// URLResolver は短縮URLのリダイレクト先を調べる
+type URLResolver interface {
+	// Resolve はリダイレクトをたどった最終的なURLを返す
+	Resolve(ctx context.Context, rawURL string) (string, error)
+}
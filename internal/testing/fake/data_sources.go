@@ -0,0 +1,62 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+type DataSourceRepository struct {
+	mu      sync.Mutex
+	sources map[string]model.DataSource
+}
+
+func NewDataSourceRepository() *DataSourceRepository {
+	return &DataSourceRepository{sources: make(map[string]model.DataSource)}
+}
+
+// Add はデータソースを追加する。同じIDの場合は上書きする
+func (r *DataSourceRepository) Add(sources ...model.DataSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range sources {
+		r.sources[s.ID] = s
+	}
+}
+
+func (r *DataSourceRepository) GetByID(ctx context.Context, id string) (*model.DataSource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sources[id]
+	if !ok {
+		return nil, fmt.Errorf("data source %s: %w", id, output.ErrNotFound)
+	}
+	return &s, nil
+}
+
+func (r *DataSourceRepository) GetAll(ctx context.Context) ([]*model.DataSource, error) {
+	return r.list(false), nil
+}
+
+func (r *DataSourceRepository) GetActive(ctx context.Context) ([]*model.DataSource, error) {
+	return r.list(true), nil
+}
+
+func (r *DataSourceRepository) list(activeOnly bool) []*model.DataSource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*model.DataSource
+	for _, s := range r.sources {
+		if activeOnly && !s.IsActive {
+			continue
+		}
+		s := s
+		result = append(result, &s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
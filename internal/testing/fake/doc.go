@@ -0,0 +1,63 @@
+// Package fake はoutputポートのインメモリ実装を提供する。
+// Supabaseに接続せずにユースケースやサービスを動かすためのもので、
+// 並行に呼び出しても安全。時刻に依存する処理（ジョブキューやロック）はNowで差し替えられる
+package fake
+
+import (
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// Repositories はすべてのポートの実装をまとめたもの
+type Repositories struct {
+	Users              *UserRepository
+	DataSources        *DataSourceRepository
+	FetchConfigs       *FetchConfigRepository
+	RedditFetchConfigs *RedditFetchConfigRepository
+	FetchedData        *FetchedDataRepository
+	FetchRuns          *FetchRunRepository
+	FetchJobs          *FetchJobQueue
+	RunLocks           *RunLockRepository
+	Stories            *StoryRepository
+}
+
+// NewRepositories は空のリポジトリ一式を作る。設定の削除はデータソース固有の設定にも反映される（CASCADE）。
+// 取得したデータは設定を通じてユーザーと結び付く
+func NewRepositories() *Repositories {
+	redditConfigs := NewRedditFetchConfigRepository()
+	configs := NewFetchConfigRepository()
+	configs.RedditFetchConfigs = redditConfigs
+	fetchedData := NewFetchedDataRepository()
+	fetchedData.FetchConfigs = configs
+
+	return &Repositories{
+		Users:              NewUserRepository(),
+		DataSources:        NewDataSourceRepository(),
+		FetchConfigs:       configs,
+		RedditFetchConfigs: redditConfigs,
+		FetchedData:        fetchedData,
+		FetchRuns:          NewFetchRunRepository(),
+		FetchJobs:          NewFetchJobQueue(),
+		RunLocks:           NewRunLockRepository(),
+		Stories:            NewStoryRepository(),
+	}
+}
+
+// SetNow はジョブキューとロックが使う現在時刻を差し替える
+func (r *Repositories) SetNow(now func() time.Time) {
+	r.FetchJobs.Now = now
+	r.RunLocks.Now = now
+}
+
+var (
+	_ output.UserRepository              = (*UserRepository)(nil)
+	_ output.DataSourceRepository        = (*DataSourceRepository)(nil)
+	_ output.FetchConfigRepository       = (*FetchConfigRepository)(nil)
+	_ output.RedditFetchConfigRepository = (*RedditFetchConfigRepository)(nil)
+	_ output.FetchedDataRepository       = (*FetchedDataRepository)(nil)
+	_ output.FetchRunRepository          = (*FetchRunRepository)(nil)
+	_ output.FetchJobQueue               = (*FetchJobQueue)(nil)
+	_ output.RunLockRepository           = (*RunLockRepository)(nil)
+	_ output.StoryRepository             = (*StoryRepository)(nil)
+)
@@ -0,0 +1,163 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+type FetchConfigRepository struct {
+	mu      sync.Mutex
+	configs map[uuid.UUID]model.UserFetchConfig
+	// RedditFetchConfigs を設定すると、Delete でデータソース固有の設定も削除する
+	RedditFetchConfigs *RedditFetchConfigRepository
+}
+
+func NewFetchConfigRepository() *FetchConfigRepository {
+	return &FetchConfigRepository{configs: make(map[uuid.UUID]model.UserFetchConfig)}
+}
+
+// Add は設定をそのまま保存する。テストの初期データの投入に使う
+func (r *FetchConfigRepository) Add(configs ...model.UserFetchConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range configs {
+		r.configs[c.ID] = c
+	}
+}
+
+// All はすべての設定を作成日時の古い順に返す
+func (r *FetchConfigRepository) All() []model.UserFetchConfig {
+	return r.filter(func(model.UserFetchConfig) bool { return true })
+}
+
+func (r *FetchConfigRepository) GetByUserID(ctx context.Context, userID model.UserID) ([]model.UserFetchConfig, error) {
+	configs := r.filter(func(c model.UserFetchConfig) bool {
+		return c.UserID.String() == string(userID) && c.IsActive && !c.IsSuspended()
+	})
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
+
+func (r *FetchConfigRepository) GetByID(ctx context.Context, configID uuid.UUID) (*model.UserFetchConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.configs[configID]
+	if !ok {
+		return nil, fmt.Errorf("config %s: %w", configID, output.ErrNotFound)
+	}
+	return &c, nil
+}
+
+func (r *FetchConfigRepository) List(ctx context.Context, filter output.FetchConfigFilter) ([]model.UserFetchConfig, error) {
+	return r.filter(func(c model.UserFetchConfig) bool {
+		if filter.UserID != nil && c.UserID != *filter.UserID {
+			return false
+		}
+		if filter.DataSourceID != "" && c.DataSourceID != filter.DataSourceID {
+			return false
+		}
+		if filter.ActiveOnly && (!c.IsActive || c.IsSuspended()) {
+			return false
+		}
+		return true
+	}), nil
+}
+
+func (r *FetchConfigRepository) Create(ctx context.Context, config *model.UserFetchConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.configs[config.ID]; ok {
+		return fmt.Errorf("config %s already exists", config.ID)
+	}
+	r.configs[config.ID] = *config
+	return nil
+}
+
+func (r *FetchConfigRepository) Update(ctx context.Context, config *model.UserFetchConfig) error {
+	return r.update(config.ID, func(c *model.UserFetchConfig) {
+		c.Name = config.Name
+		c.IsActive = config.IsActive
+		c.Schedule = config.Schedule
+		c.UpdatedAt = config.UpdatedAt
+	})
+}
+
+func (r *FetchConfigRepository) Delete(ctx context.Context, configID uuid.UUID) error {
+	r.mu.Lock()
+	if _, ok := r.configs[configID]; !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("config %s: %w", configID, output.ErrNotFound)
+	}
+	delete(r.configs, configID)
+	r.mu.Unlock()
+
+	if r.RedditFetchConfigs != nil {
+		r.RedditFetchConfigs.deleteByConfigID(configID)
+	}
+	return nil
+}
+
+func (r *FetchConfigRepository) GetSuspended(ctx context.Context) ([]model.UserFetchConfig, error) {
+	return r.filter(func(c model.UserFetchConfig) bool { return c.IsSuspended() }), nil
+}
+
+func (r *FetchConfigRepository) RecordSuccess(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) error {
+	return r.update(configID, func(c *model.UserFetchConfig) {
+		t := fetchedAt
+		c.LastFetchedAt = &t
+		c.ConsecutiveFailures = 0
+		c.LastErrorKind = nil
+		c.LastError = nil
+	})
+}
+
+func (r *FetchConfigRepository) RecordFailure(ctx context.Context, configID uuid.UUID, failure model.FetchFailure) error {
+	return r.update(configID, func(c *model.UserFetchConfig) {
+		c.FetchFailure = failure
+	})
+}
+
+func (r *FetchConfigRepository) Unsuspend(ctx context.Context, configID uuid.UUID) error {
+	return r.update(configID, func(c *model.UserFetchConfig) {
+		c.FetchFailure = model.FetchFailure{}
+	})
+}
+
+func (r *FetchConfigRepository) update(configID uuid.UUID, fn func(c *model.UserFetchConfig)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.configs[configID]
+	if !ok {
+		return fmt.Errorf("config %s: %w", configID, output.ErrNotFound)
+	}
+	fn(&c)
+	r.configs[configID] = c
+	return nil
+}
+
+func (r *FetchConfigRepository) filter(match func(model.UserFetchConfig) bool) []model.UserFetchConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []model.UserFetchConfig
+	for _, c := range r.configs {
+		if match(c) {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID.String() < result[j].ID.String()
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
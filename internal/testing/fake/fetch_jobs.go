@@ -0,0 +1,154 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+// FetchJobQueue はdocs/db.mdのキュー操作関数と同じ規則でジョブを管理する
+type FetchJobQueue struct {
+	mu   sync.Mutex
+	jobs []*model.FetchJob
+	// Now は現在時刻。leaseや再試行の判定に使う
+	Now func() time.Time
+}
+
+func NewFetchJobQueue() *FetchJobQueue {
+	return &FetchJobQueue{Now: time.Now}
+}
+
+// Jobs はすべてのジョブを登録順に返す
+func (q *FetchJobQueue) Jobs() []model.FetchJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]model.FetchJob, len(q.jobs))
+	for i, j := range q.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+func (q *FetchJobQueue) Enqueue(ctx context.Context, configID uuid.UUID, maxAttempts int) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ConfigID == configID && (j.Status == model.FetchJobStatusQueued || j.Status == model.FetchJobStatusRunning) {
+			return false, nil
+		}
+	}
+	now := q.Now()
+	q.jobs = append(q.jobs, &model.FetchJob{
+		ID:          uuid.New(),
+		ConfigID:    configID,
+		Status:      model.FetchJobStatusQueued,
+		MaxAttempts: maxAttempts,
+		RunAfter:    now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	return true, nil
+}
+
+func (q *FetchJobQueue) Claim(ctx context.Context, workerID string, limit int, lease time.Duration) ([]model.FetchJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.Now()
+
+	// 試行回数を使い切ったままleaseが切れたジョブはdeadにする
+	for _, j := range q.jobs {
+		if j.Status == model.FetchJobStatusRunning && leaseExpired(j, now) && j.IsLastAttempt() {
+			j.Status = model.FetchJobStatusDead
+			if j.LastError == nil {
+				msg := "lease expired"
+				j.LastError = &msg
+			}
+			j.LeasedBy = nil
+			j.LeaseExpiresAt = nil
+			j.UpdatedAt = now
+		}
+	}
+
+	var claimable []*model.FetchJob
+	for _, j := range q.jobs {
+		ready := (j.Status == model.FetchJobStatusQueued && !j.RunAfter.After(now)) ||
+			(j.Status == model.FetchJobStatusRunning && leaseExpired(j, now))
+		if ready && j.Attempts < j.MaxAttempts {
+			claimable = append(claimable, j)
+		}
+	}
+	sort.SliceStable(claimable, func(a, b int) bool { return claimable[a].RunAfter.Before(claimable[b].RunAfter) })
+	if limit >= 0 && len(claimable) > limit {
+		claimable = claimable[:limit]
+	}
+
+	claimed := make([]model.FetchJob, 0, len(claimable))
+	for _, j := range claimable {
+		expires := now.Add(lease)
+		holder := workerID
+		j.Status = model.FetchJobStatusRunning
+		j.Attempts++
+		j.LeasedBy = &holder
+		j.LeaseExpiresAt = &expires
+		j.UpdatedAt = now
+		claimed = append(claimed, *j)
+	}
+	return claimed, nil
+}
+
+func (q *FetchJobQueue) Heartbeat(ctx context.Context, jobID uuid.UUID, workerID string, lease time.Duration) error {
+	return q.withLease(jobID, workerID, func(j *model.FetchJob, now time.Time) {
+		expires := now.Add(lease)
+		j.LeaseExpiresAt = &expires
+	})
+}
+
+func (q *FetchJobQueue) Complete(ctx context.Context, jobID uuid.UUID, workerID string) error {
+	return q.withLease(jobID, workerID, func(j *model.FetchJob, now time.Time) {
+		j.Status = model.FetchJobStatusSucceeded
+		j.LeasedBy = nil
+		j.LeaseExpiresAt = nil
+	})
+}
+
+func (q *FetchJobQueue) Fail(ctx context.Context, jobID uuid.UUID, workerID string, reason string, retryAfter time.Duration) error {
+	return q.withLease(jobID, workerID, func(j *model.FetchJob, now time.Time) {
+		j.Status = model.FetchJobStatusQueued
+		if j.IsLastAttempt() {
+			j.Status = model.FetchJobStatusDead
+		}
+		j.RunAfter = now.Add(retryAfter)
+		j.LastError = &reason
+		j.LeasedBy = nil
+		j.LeaseExpiresAt = nil
+	})
+}
+
+// withLease はworkerIDが保持している実行中のジョブのみを更新する
+func (q *FetchJobQueue) withLease(jobID uuid.UUID, workerID string, fn func(j *model.FetchJob, now time.Time)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ID != jobID {
+			continue
+		}
+		if j.Status != model.FetchJobStatusRunning || j.LeasedBy == nil || *j.LeasedBy != workerID {
+			break
+		}
+		now := q.Now()
+		fn(j, now)
+		j.UpdatedAt = now
+		return nil
+	}
+	return fmt.Errorf("fetch job %s, worker %s: %w", jobID, workerID, output.ErrLeaseLost)
+}
+
+func leaseExpired(j *model.FetchJob, now time.Time) bool {
+	return j.LeaseExpiresAt != nil && j.LeaseExpiresAt.Before(now)
+}
@@ -0,0 +1,115 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+type runItemKey struct {
+	runID    uuid.UUID
+	configID uuid.UUID
+}
+
+type FetchRunRepository struct {
+	mu        sync.Mutex
+	runs      map[uuid.UUID]model.FetchRun
+	items     map[runItemKey]model.FetchRunItem
+	itemOrder []runItemKey
+}
+
+func NewFetchRunRepository() *FetchRunRepository {
+	return &FetchRunRepository{
+		runs:  make(map[uuid.UUID]model.FetchRun),
+		items: make(map[runItemKey]model.FetchRunItem),
+	}
+}
+
+func (r *FetchRunRepository) Create(ctx context.Context, run *model.FetchRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.runs[run.ID]; ok {
+		return fmt.Errorf("fetch run %s already exists", run.ID)
+	}
+	r.runs[run.ID] = *run
+	return nil
+}
+
+func (r *FetchRunRepository) Update(ctx context.Context, run *model.FetchRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.runs[run.ID]; !ok {
+		return fmt.Errorf("fetch run %s: %w", run.ID, output.ErrNotFound)
+	}
+	r.runs[run.ID] = *run
+	return nil
+}
+
+func (r *FetchRunRepository) GetByID(ctx context.Context, runID uuid.UUID) (*model.FetchRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("fetch run %s: %w", runID, output.ErrNotFound)
+	}
+	return &run, nil
+}
+
+func (r *FetchRunRepository) List(ctx context.Context, limit int) ([]model.FetchRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := make([]model.FetchRun, 0, len(r.runs))
+	for _, run := range r.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (r *FetchRunRepository) CreateItems(ctx context.Context, items []model.FetchRunItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, item := range items {
+		key := runItemKey{item.RunID, item.ConfigID}
+		if _, ok := r.items[key]; ok {
+			return fmt.Errorf("fetch run item %s/%s already exists", item.RunID, item.ConfigID)
+		}
+	}
+	for _, item := range items {
+		key := runItemKey{item.RunID, item.ConfigID}
+		r.items[key] = item
+		r.itemOrder = append(r.itemOrder, key)
+	}
+	return nil
+}
+
+func (r *FetchRunRepository) UpdateItem(ctx context.Context, item *model.FetchRunItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := runItemKey{item.RunID, item.ConfigID}
+	// PostgRESTの更新と同様に、対象の行がなくてもエラーにしない
+	if _, ok := r.items[key]; ok {
+		r.items[key] = *item
+	}
+	return nil
+}
+
+func (r *FetchRunRepository) GetItems(ctx context.Context, runID uuid.UUID) ([]model.FetchRunItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var items []model.FetchRunItem
+	for _, key := range r.itemOrder {
+		if key.runID == runID {
+			items = append(items, r.items[key])
+		}
+	}
+	return items, nil
+}
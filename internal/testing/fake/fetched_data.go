@@ -0,0 +1,96 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// FetchedDataRepository は保存された順にデータを保持する。
+// fetched_dataの主キーと同様に、同じIDのデータを保存しようとするとエラーを返す
+type FetchedDataRepository struct {
+	mu    sync.Mutex
+	items []model.FetchedData
+	ids   map[uuid.UUID]bool
+	// Err を設定するとCreateがこのエラーを返す
+	Err error
+	// FetchConfigs はアイテムのユーザーを調べるために使う（user_fetch_configsとの結合）
+	FetchConfigs *FetchConfigRepository
+}
+
+func NewFetchedDataRepository() *FetchedDataRepository {
+	return &FetchedDataRepository{ids: make(map[uuid.UUID]bool)}
+}
+
+func (r *FetchedDataRepository) Create(ctx context.Context, data *model.FetchedData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Err != nil {
+		return r.Err
+	}
+	if r.ids[data.ID] {
+		return fmt.Errorf("duplicate key value violates unique constraint \"fetched_data_pkey\" (id %s)", data.ID)
+	}
+	r.ids[data.ID] = true
+	r.items = append(r.items, *data)
+	return nil
+}
+
+// All は保存されたデータを保存した順に返す
+func (r *FetchedDataRepository) All() []model.FetchedData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]model.FetchedData(nil), r.items...)
+}
+
+// ByConfigID は設定ごとに保存されたデータを返す
+func (r *FetchedDataRepository) ByConfigID(configID uuid.UUID) []model.FetchedData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []model.FetchedData
+	for _, item := range r.items {
+		if item.ConfigID == configID {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (r *FetchedDataRepository) ExistingIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		if r.ids[id] {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
+
+// ListRecentFingerprints は指紋のあるアイテムのうち、ユーザーの設定で since 以降に取得したものを返す
+func (r *FetchedDataRepository) ListRecentFingerprints(ctx context.Context, userID uuid.UUID, since time.Time) ([]model.ItemFingerprint, error) {
+	owners := make(map[uuid.UUID]bool)
+	if r.FetchConfigs != nil {
+		for _, c := range r.FetchConfigs.All() {
+			if c.UserID == userID {
+				owners[c.ID] = true
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []model.ItemFingerprint
+	for _, item := range r.items {
+		if item.Fingerprint == nil || !owners[item.ConfigID] || item.FetchedAt.Before(since) {
+			continue
+		}
+		result = append(result, model.ItemFingerprint{ID: item.ID, Fingerprint: *item.Fingerprint, FetchedAt: item.FetchedAt})
+	}
+	return result, nil
+}
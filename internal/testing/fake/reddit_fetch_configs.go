@@ -0,0 +1,73 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+// RedditFetchConfigRepository は親設定のIDをキーにReddit固有の設定を保持する
+type RedditFetchConfigRepository struct {
+	mu      sync.Mutex
+	details map[uuid.UUID]model.RedditFetchConfigDetail
+}
+
+func NewRedditFetchConfigRepository() *RedditFetchConfigRepository {
+	return &RedditFetchConfigRepository{details: make(map[uuid.UUID]model.RedditFetchConfigDetail)}
+}
+
+// Add は設定をそのまま保存する。テストの初期データの投入に使う
+func (r *RedditFetchConfigRepository) Add(details ...model.RedditFetchConfigDetail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range details {
+		r.details[d.UserFetchConfigID] = d
+	}
+}
+
+func (r *RedditFetchConfigRepository) GetByUserFetchConfigID(ctx context.Context, userFetchConfigID uuid.UUID) (*model.RedditFetchConfigDetail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.details[userFetchConfigID]
+	if !ok {
+		return nil, fmt.Errorf("reddit fetch config for %s: %w", userFetchConfigID, output.ErrNotFound)
+	}
+	d.Keywords = append([]string(nil), d.Keywords...)
+	return &d, nil
+}
+
+func (r *RedditFetchConfigRepository) Create(ctx context.Context, detail *model.RedditFetchConfigDetail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.details[detail.UserFetchConfigID]; ok {
+		return fmt.Errorf("reddit fetch config for %s already exists", detail.UserFetchConfigID)
+	}
+	r.details[detail.UserFetchConfigID] = *detail
+	return nil
+}
+
+func (r *RedditFetchConfigRepository) Update(ctx context.Context, detail *model.RedditFetchConfigDetail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.details[detail.UserFetchConfigID]
+	if !ok {
+		return fmt.Errorf("reddit fetch config for %s: %w", detail.UserFetchConfigID, output.ErrNotFound)
+	}
+	d.Subreddit = detail.Subreddit
+	d.SortBy = detail.SortBy
+	d.TimeFilter = detail.TimeFilter
+	d.LimitCount = detail.LimitCount
+	d.Keywords = append([]string(nil), detail.Keywords...)
+	r.details[detail.UserFetchConfigID] = d
+	return nil
+}
+
+func (r *RedditFetchConfigRepository) deleteByConfigID(configID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.details, configID)
+}
@@ -0,0 +1,66 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// RunLockRepository はdocs/db.mdのロック関数と同じ規則でTTL付きのロックを管理する
+type RunLockRepository struct {
+	mu    sync.Mutex
+	locks map[string]model.RunLock
+	// Now は現在時刻。期限切れの判定に使う
+	Now func() time.Time
+}
+
+func NewRunLockRepository() *RunLockRepository {
+	return &RunLockRepository{locks: make(map[string]model.RunLock), Now: time.Now}
+}
+
+func (r *RunLockRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (*model.RunLockAcquisition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.Now()
+
+	current, held := r.locks[name]
+	if held && !current.ExpiresAt.Before(now) {
+		return &model.RunLockAcquisition{Acquired: false, Lock: current}, nil
+	}
+
+	lock := model.RunLock{Name: name, Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	r.locks[name] = lock
+	result := &model.RunLockAcquisition{Acquired: true, Lock: lock}
+	if held {
+		previous := current.Holder
+		result.TakenOverFrom = &previous
+	}
+	return result, nil
+}
+
+func (r *RunLockRepository) Refresh(ctx context.Context, name, holder string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock, ok := r.locks[name]
+	if !ok || lock.Holder != holder {
+		return fmt.Errorf("run lock %s is no longer held by %s: %w", name, holder, output.ErrLockNotHeld)
+	}
+	lock.ExpiresAt = r.Now().Add(ttl)
+	r.locks[name] = lock
+	return nil
+}
+
+func (r *RunLockRepository) Release(ctx context.Context, name, holder string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock, ok := r.locks[name]
+	if !ok || lock.Holder != holder {
+		return fmt.Errorf("run lock %s is not held by %s", name, holder)
+	}
+	delete(r.locks, name)
+	return nil
+}
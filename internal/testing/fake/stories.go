@@ -0,0 +1,66 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// Story はStoryRepositoryが保持するストーリー
+type Story struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	CanonicalURL string
+	Title        string
+	Discussions  []model.StoryDiscussion
+}
+
+type storyKey struct {
+	userID       uuid.UUID
+	canonicalURL string
+}
+
+// StoryRepository はdocs/db.mdのlink_story関数と同じ規則でストーリーを管理する
+type StoryRepository struct {
+	mu      sync.Mutex
+	stories map[storyKey]*Story
+}
+
+func NewStoryRepository() *StoryRepository {
+	return &StoryRepository{stories: make(map[storyKey]*Story)}
+}
+
+func (r *StoryRepository) Link(ctx context.Context, userID uuid.UUID, canonicalURL, title string, discussion model.StoryDiscussion) (uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := storyKey{userID, canonicalURL}
+	story, ok := r.stories[key]
+	if !ok {
+		story = &Story{ID: uuid.New(), UserID: userID, CanonicalURL: canonicalURL, Title: title}
+		r.stories[key] = story
+	}
+	for _, d := range story.Discussions {
+		if d.URL == discussion.URL {
+			return story.ID, nil
+		}
+	}
+	story.Discussions = append(story.Discussions, discussion)
+	return story.ID, nil
+}
+
+// All はすべてのストーリーを正規化したURLの順に返す
+func (r *StoryRepository) All() []Story {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stories := make([]Story, 0, len(r.stories))
+	for _, s := range r.stories {
+		c := *s
+		c.Discussions = append([]model.StoryDiscussion(nil), s.Discussions...)
+		stories = append(stories, c)
+	}
+	sort.Slice(stories, func(i, j int) bool { return stories[i].CanonicalURL < stories[j].CanonicalURL })
+	return stories
+}
@@ -0,0 +1,38 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+type UserRepository struct {
+	mu    sync.Mutex
+	users []model.UserID
+	// Err を設定するとすべての呼び出しがこのエラーを返す
+	Err error
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{}
+}
+
+// Add はユーザーを追加する
+func (r *UserRepository) Add(ids ...model.UserID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, ids...)
+}
+
+func (r *UserRepository) GetActiveUserIDs(ctx context.Context) ([]model.UserID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if len(r.users) == 0 {
+		return nil, nil
+	}
+	return append([]model.UserID(nil), r.users...), nil
+}
@@ -0,0 +1,575 @@
+// Package fakereddit はReddit APIを模したhttptestサーバーを提供する。
+// サブレディットの一覧・検索・コメント・トークン発行に応答し、
+// ページング（after）とレート制限のヘッダーを本物と同じ形式で返す
+package fakereddit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher/reddit"
+)
+
+const (
+	defaultLimit = 25
+	maxLimit     = 100
+	tokenTTL     = time.Hour
+)
+
+// Post はサーバーが返す投稿
+type Post struct {
+	ID          string
+	Title       string
+	Selftext    string
+	URL         string
+	Author      string
+	Score       int
+	CreatedUTC  time.Time
+	Over18      bool
+	Comments    []Comment
+	subreddit   string
+	insertOrder int
+}
+
+// Comment は投稿へのコメント
+type Comment struct {
+	ID         string
+	Author     string
+	Body       string
+	Score      int
+	CreatedUTC time.Time
+}
+
+// Request はサーバーが受け付けたリクエストの記録
+type Request struct {
+	Method        string
+	Path          string
+	Query         url.Values
+	Authorization string
+}
+
+type failure struct {
+	pathPrefix string
+	status     int
+	remaining  int
+}
+
+// Server はReddit APIの偽物。NewServerで起動し、使い終わったらCloseする
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	subreddits map[string][]*Post
+	posts      int
+	requests   []Request
+	failures   []*failure
+
+	clientID     string
+	clientSecret string
+	tokens       map[string]time.Time
+	issued       int
+
+	pageSize int
+
+	rateLimit   int
+	rateWindow  time.Duration
+	rateUsed    int
+	windowStart time.Time
+
+	// Now は現在時刻。トークンの期限、レート制限の窓、期間での絞り込みに使う
+	Now func() time.Time
+}
+
+// NewServer はサーバーを起動する。既定では認証もレート制限も行わない
+func NewServer() *Server {
+	s := &Server{
+		subreddits: make(map[string][]*Post),
+		tokens:     make(map[string]time.Time),
+		Now:        time.Now,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// TokenURL はアクセストークンを発行するエンドポイントのURL
+func (s *Server) TokenURL() string {
+	return s.URL + "/api/v1/access_token"
+}
+
+// NewFetcher はこのサーバーに接続するフェッチャーを作る。リクエスト間の待ち時間はなし。
+// 認証情報が設定されている場合はトークンを取得してから接続する
+func (s *Server) NewFetcher() *reddit.RedditFetcher {
+	s.mu.Lock()
+	clientID, clientSecret := s.clientID, s.clientSecret
+	s.mu.Unlock()
+
+	var auth *reddit.RedditAuth
+	if clientID != "" {
+		auth = reddit.NewRedditAuth(clientID, clientSecret, "")
+		auth.SetTokenURL(s.TokenURL())
+	}
+	f := reddit.NewRedditFetcherWithClient("", auth, s.Client())
+	f.SetBaseURL(s.URL)
+	f.SetRequestInterval(0)
+	return f
+}
+
+// AddSubreddit は投稿のないサブレディットを作る
+func (s *Server) AddSubreddit(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	if _, ok := s.subreddits[key]; !ok {
+		s.subreddits[key] = nil
+	}
+}
+
+// AddPosts はサブレディットに投稿を追加する。サブレディットがなければ作る。
+// IDが空の投稿には連番のIDを、作成日時が空の投稿には追加した順に古くなる日時を付ける
+func (s *Server) AddPosts(subreddit string, posts ...Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(subreddit)
+	for _, p := range posts {
+		s.posts++
+		p := p
+		p.subreddit = subreddit
+		p.insertOrder = s.posts
+		if p.ID == "" {
+			p.ID = strconv.FormatInt(int64(s.posts), 36)
+		}
+		if p.CreatedUTC.IsZero() {
+			p.CreatedUTC = s.Now().Add(-time.Duration(s.posts) * time.Minute)
+		}
+		if p.Author == "" {
+			p.Author = "fake_user"
+		}
+		s.subreddits[key] = append(s.subreddits[key], &p)
+	}
+}
+
+// RequireAuth は取得系のエンドポイントでBearerトークンを必須にし、
+// トークンの発行にclient credentialsを要求する
+func (s *Server) RequireAuth(clientID, clientSecret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientID = clientID
+	s.clientSecret = clientSecret
+}
+
+// SetRateLimit は窓ごとのリクエスト数の上限を設定する。上限を超えると429を返す。0で無効
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = limit
+	s.rateWindow = window
+	s.rateUsed = 0
+	s.windowStart = time.Time{}
+}
+
+// SetPageSize は1ページに返す投稿数の上限を設定する。本物のAPIと同様に、limitより少ない件数でも続きがあればafterを返す。0で無効
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// FailNext はパスがpathPrefixで始まる次のtimes回のリクエストにstatusを返す。
+// pathPrefixが空の場合はすべてのリクエストが対象
+func (s *Server) FailNext(pathPrefix string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{pathPrefix: pathPrefix, status: status, remaining: times})
+}
+
+// Requests は受け付けたリクエストを順に返す
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Query:         r.URL.Query(),
+		Authorization: r.Header.Get("Authorization"),
+	})
+
+	if r.URL.Path == "/api/v1/access_token" {
+		s.handleToken(w, r)
+		return
+	}
+
+	if !s.takeRateLimit(w) {
+		writeError(w, http.StatusTooManyRequests, "Too Many Requests")
+		return
+	}
+	if status, ok := s.takeFailure(r.URL.Path); ok {
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, ".json")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "search":
+		s.handleSearch(w, r, nil)
+	case len(parts) >= 2 && parts[0] == "r":
+		subs, ok := s.lookup(parts[1])
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		switch {
+		case len(parts) == 2:
+			s.handleListing(w, r, subs, "hot")
+		case len(parts) == 3 && parts[2] == "search":
+			if r.URL.Query().Get("restrict_sr") != "true" && r.URL.Query().Get("restrict_sr") != "1" {
+				subs = nil
+			}
+			s.handleSearch(w, r, subs)
+		case len(parts) == 3:
+			s.handleListing(w, r, subs, parts[2])
+		case len(parts) >= 4 && parts[2] == "comments":
+			s.handleComments(w, subs, parts[3])
+		default:
+			writeError(w, http.StatusNotFound, "Not Found")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// handleToken はclient credentialsでトークンを発行する
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if s.clientID != "" && (!ok || id != s.clientID || secret != s.clientSecret) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	s.issued++
+	token := fmt.Sprintf("fake-token-%d", s.issued)
+	s.tokens[token] = s.Now().Add(tokenTTL)
+	writeJSON(w, http.StatusOK, reddit.TokenResponse{
+		AccessToken: token,
+		TokenType:   "bearer",
+		ExpiresIn:   int(tokenTTL / time.Second),
+		Scope:       "*",
+	})
+}
+
+// authorized は認証が必須の場合に有効なBearerトークンが付いているかを確認する
+func (s *Server) authorized(r *http.Request) bool {
+	if s.clientID == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	expiresAt, ok := s.tokens[token]
+	return ok && s.Now().Before(expiresAt)
+}
+
+// takeRateLimit はリクエストを1回分数え、レート制限のヘッダーを付ける。上限を超えた場合はfalseを返す
+func (s *Server) takeRateLimit(w http.ResponseWriter) bool {
+	if s.rateLimit <= 0 {
+		return true
+	}
+	now := s.Now()
+	if s.windowStart.IsZero() || !now.Before(s.windowStart.Add(s.rateWindow)) {
+		s.windowStart = now
+		s.rateUsed = 0
+	}
+
+	allowed := s.rateUsed < s.rateLimit
+	if allowed {
+		s.rateUsed++
+	}
+	reset := s.windowStart.Add(s.rateWindow).Sub(now)
+	w.Header().Set("X-Ratelimit-Used", strconv.Itoa(s.rateUsed))
+	w.Header().Set("X-Ratelimit-Remaining", strconv.Itoa(s.rateLimit-s.rateUsed))
+	w.Header().Set("X-Ratelimit-Reset", strconv.Itoa(int(reset.Seconds())))
+	return allowed
+}
+
+func (s *Server) takeFailure(path string) (int, bool) {
+	for i, f := range s.failures {
+		if !strings.HasPrefix(path, f.pathPrefix) {
+			continue
+		}
+		f.remaining--
+		if f.remaining <= 0 {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+		}
+		return f.status, true
+	}
+	return 0, false
+}
+
+// lookup は "golang+rust" のような複数指定を含むサブレディット名を解決する
+func (s *Server) lookup(name string) ([]string, bool) {
+	var subs []string
+	for _, n := range strings.Split(name, "+") {
+		key := strings.ToLower(n)
+		if _, ok := s.subreddits[key]; !ok {
+			return nil, false
+		}
+		subs = append(subs, key)
+	}
+	return subs, true
+}
+
+// collect は指定されたサブレディット（nilの場合はすべて）の投稿を追加した順に返す
+func (s *Server) collect(subs []string) []*Post {
+	if subs == nil {
+		for key := range s.subreddits {
+			subs = append(subs, key)
+		}
+	}
+	var posts []*Post
+	for _, key := range subs {
+		posts = append(posts, s.subreddits[key]...)
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].insertOrder < posts[j].insertOrder })
+	return posts
+}
+
+func (s *Server) handleListing(w http.ResponseWriter, r *http.Request, subs []string, sortBy string) {
+	switch sortBy {
+	case "hot", "new", "top", "rising", "controversial":
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	posts := s.sortPosts(s.collect(subs), sortBy, r.URL.Query().Get("t"))
+	s.writeListing(w, r, posts)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, subs []string) {
+	q := strings.ToLower(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	var matched []*Post
+	for _, p := range s.collect(subs) {
+		if strings.Contains(strings.ToLower(p.Title), q) || strings.Contains(strings.ToLower(p.Selftext), q) {
+			matched = append(matched, p)
+		}
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = "relevance"
+	}
+	s.writeListing(w, r, s.sortPosts(matched, sortBy, r.URL.Query().Get("t")))
+}
+
+func (s *Server) handleComments(w http.ResponseWriter, subs []string, id string) {
+	for _, p := range s.collect(subs) {
+		if p.ID != id {
+			continue
+		}
+		comments := make([]thing, 0, len(p.Comments))
+		for _, c := range p.Comments {
+			comments = append(comments, thing{Kind: "t1", Data: map[string]interface{}{
+				"id":          c.ID,
+				"name":        "t1_" + c.ID,
+				"author":      c.Author,
+				"body":        c.Body,
+				"score":       c.Score,
+				"created_utc": float64(c.CreatedUTC.Unix()),
+				"link_id":     "t3_" + p.ID,
+				"subreddit":   p.subreddit,
+			}})
+		}
+		writeJSON(w, http.StatusOK, []listing{
+			newListing([]thing{postThing(p)}, ""),
+			newListing(comments, ""),
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// sortPosts は並び順と期間（top/controversialのみ）を適用する
+func (s *Server) sortPosts(posts []*Post, sortBy, timeFilter string) []*Post {
+	if sortBy == "top" || sortBy == "controversial" {
+		if d, ok := timeFilterDurations[timeFilter]; ok {
+			since := s.Now().Add(-d)
+			var filtered []*Post
+			for _, p := range posts {
+				if p.CreatedUTC.After(since) {
+					filtered = append(filtered, p)
+				}
+			}
+			posts = filtered
+		}
+	}
+
+	switch sortBy {
+	case "new":
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].CreatedUTC.After(posts[j].CreatedUTC) })
+	case "top":
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Score > posts[j].Score })
+	case "controversial":
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Score < posts[j].Score })
+	case "comments":
+		sort.SliceStable(posts, func(i, j int) bool { return len(posts[i].Comments) > len(posts[j].Comments) })
+	}
+	return posts
+}
+
+var timeFilterDurations = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+// writeListing はlimitとafterでページを切り出して返す。続きがある場合はafterに最後の投稿のfullnameを入れる
+func (s *Server) writeListing(w http.ResponseWriter, r *http.Request, posts []*Post) {
+	query := r.URL.Query()
+	limit := defaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	if limit == 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+	if s.pageSize > 0 && limit > s.pageSize {
+		limit = s.pageSize
+	}
+
+	start := 0
+	if after := query.Get("after"); after != "" {
+		start = len(posts)
+		for i, p := range posts {
+			if "t3_"+p.ID == after {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := start + limit
+	if end > len(posts) {
+		end = len(posts)
+	}
+
+	page := posts[start:end]
+	children := make([]thing, 0, len(page))
+	for _, p := range page {
+		children = append(children, postThing(p))
+	}
+	next := ""
+	if end < len(posts) && len(page) > 0 {
+		next = "t3_" + page[len(page)-1].ID
+	}
+	writeJSON(w, http.StatusOK, newListing(children, next))
+}
+
+type thing struct {
+	Kind string                 `json:"kind"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type listing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		After    *string `json:"after"`
+		Before   *string `json:"before"`
+		Dist     int     `json:"dist"`
+		Children []thing `json:"children"`
+	} `json:"data"`
+}
+
+func newListing(children []thing, after string) listing {
+	l := listing{Kind: "Listing"}
+	l.Data.Children = children
+	l.Data.Dist = len(children)
+	if after != "" {
+		l.Data.After = &after
+	}
+	return l
+}
+
+func postThing(p *Post) thing {
+	permalink := fmt.Sprintf("/r/%s/comments/%s/%s/", p.subreddit, p.ID, slug(p.Title))
+	postURL := p.URL
+	if postURL == "" {
+		postURL = "https://www.reddit.com" + permalink
+	}
+	return thing{Kind: "t3", Data: map[string]interface{}{
+		"id":           p.ID,
+		"name":         "t3_" + p.ID,
+		"title":        p.Title,
+		"selftext":     p.Selftext,
+		"url":          postURL,
+		"author":       p.Author,
+		"score":        p.Score,
+		"num_comments": len(p.Comments),
+		"created_utc":  float64(p.CreatedUTC.Unix()),
+		"subreddit":    p.subreddit,
+		"permalink":    permalink,
+		"over_18":      p.Over18,
+	}}
+}
+
+func slug(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"message": message, "error": status})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,91 @@
+// Package golden はテストの結果をtestdata以下の期待値のJSONと比較する。
+// -update フラグを付けてテストを実行すると期待値のファイルを書き換える
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// AssertJSON はgotをJSONにした結果を testdata/<name>.golden.json と比較する
+func AssertJSON(t testing.TB, name string, got interface{}) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(got); err != nil {
+		t.Fatalf("failed to encode %s: %v", name, err)
+	}
+	actual := buf.Bytes()
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (create it with -update): %v", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%s does not match the golden file (run with -update to accept):\n%s", name, diff(string(expected), string(actual)))
+	}
+}
+
+// NormalizeFetchedData は取得のたびに変わる項目（取得日時など）を固定値にしたコピーを返す。
+// フェッチャーの出力を期待値と比較する前に使う
+func NormalizeFetchedData(data []*model.FetchedData) []*model.FetchedData {
+	normalized := make([]*model.FetchedData, 0, len(data))
+	for _, d := range data {
+		if d == nil {
+			continue
+		}
+		c := *d
+		c.FetchedAt = time.Time{}
+		c.CreatedAt = time.Time{}
+		if c.PublishedAt != nil {
+			t := c.PublishedAt.UTC()
+			c.PublishedAt = &t
+		}
+		normalized = append(normalized, &c)
+	}
+	return normalized
+}
+
+// diff は最初に異なる行の前後を示す
+func diff(expected, actual string) string {
+	el := strings.Split(expected, "\n")
+	al := strings.Split(actual, "\n")
+	for i := 0; i < len(el) || i < len(al); i++ {
+		var e, a string
+		if i < len(el) {
+			e = el[i]
+		}
+		if i < len(al) {
+			a = al[i]
+		}
+		if e != a {
+			return fmt.Sprintf("line %d:\n- %s\n+ %s", i+1, e, a)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,355 @@
+// Package httpreplay は実際のHTTPレスポンスをカセット（testdata以下のJSON）に記録し、
+// テストで再生するhttp.RoundTripperを提供する。
+//
+// フェッチャーのテストでは次のように使う。FEEDLE_HTTP_RECORD=1 で実行すると本物のAPIに接続して
+// カセットを書き換え、それ以外は記録済みのレスポンスのみで動作する（ネットワークには接続しない）
+//
+//	client := httpreplay.NewClient(t, "reddit_golang_hot")
+//	f := reddit.NewRedditFetcherWithClient("", nil, client)
+//	f.SetRequestInterval(0)
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RecordEnv を "1" または "true" にするとNewClientが記録モードになる
+const RecordEnv = "FEEDLE_HTTP_RECORD"
+
+// Mode はRecorderの動作
+type Mode int
+
+const (
+	// ModeReplay は記録済みのレスポンスを返す。記録にないリクエストはエラーにする
+	ModeReplay Mode = iota
+	// ModeRecord は実際に送信し、レスポンスを記録する
+	ModeRecord
+)
+
+// Redacted は記録から取り除いた値の代わりに入れる文字列
+const Redacted = "REDACTED"
+
+// DefaultScrubHeaders は記録しないヘッダー。認証情報とクッキーを含む
+var DefaultScrubHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// DefaultScrubFields は記録するJSONボディから値を取り除く項目。トークンの発行結果などを含む
+var DefaultScrubFields = []string{"access_token", "refresh_token", "id_token", "client_secret"}
+
+// Cassette は記録されたやり取りの一覧
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction は1回のリクエストとレスポンス
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// Recorder はカセットを記録・再生するhttp.RoundTripper。並行に使っても安全
+type Recorder struct {
+	path string
+	mode Mode
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+
+	// ScrubHeaders は記録しないヘッダー
+	ScrubHeaders []string
+	// ScrubFields は記録するJSONボディから値を取り除く項目
+	ScrubFields []string
+}
+
+// New はpathのカセットを使うRecorderを作る。再生モードではカセットを読み込み、
+// 記録モードではnext（nilの場合はhttp.DefaultTransport）で実際に送信する
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:         path,
+		mode:         mode,
+		next:         next,
+		ScrubHeaders: DefaultScrubHeaders,
+		ScrubFields:  DefaultScrubFields,
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette (record it with %s=1): %w", RecordEnv, err)
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// ModeFromEnv は RecordEnv に応じたモードを返す
+func ModeFromEnv() Mode {
+	switch strings.ToLower(os.Getenv(RecordEnv)) {
+	case "1", "true":
+		return ModeRecord
+	default:
+		return ModeReplay
+	}
+}
+
+// NewClient はtestdata/cassettes/<name>.json を使うクライアントを作る。
+// モードは RecordEnv で決まり、記録モードではテストの終了時にカセットを保存する
+func NewClient(t testing.TB, name string) *http.Client {
+	t.Helper()
+	rec, err := New(filepath.Join("testdata", "cassettes", name+".json"), ModeFromEnv(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := rec.Save(); err != nil {
+			t.Errorf("failed to save cassette: %v", err)
+		}
+	})
+	return &http.Client{Transport: rec}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeRecord {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+// Save は記録モードで記録したやり取りをカセットに書き込む。再生モードでは何もしない
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := encodeJSON(r.cassette)
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	// 再生時は記録したボディの長さを使うため、元の長さは残さない
+	respHeaders := r.scrubHeaders(resp.Header)
+	if respHeaders != nil {
+		respHeaders.Del("Content-Length")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: r.scrubHeaders(req.Header),
+			Body:    r.scrubBody(reqBody),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: respHeaders,
+			Body:    r.scrubBody(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// replay は同じメソッド・パス・クエリのうち、まだ使っていない最初のやり取りを返す。
+// 認証の有無でホストが変わる（www/oauth）ため、ホストは比較しない
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	key := matchKey(req.Method, req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		u, err := url.Parse(it.Request.URL)
+		if err != nil || matchKey(it.Request.Method, u) != key {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+			StatusCode:    it.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        it.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(it.Response.Body)),
+			ContentLength: int64(len(it.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction for %s %s in %s (re-record with %s=1)", req.Method, req.URL, r.path, RecordEnv)
+}
+
+func matchKey(method string, u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		fmt.Fprintf(&b, "&%s=%s", k, strings.Join(vs, ","))
+	}
+	return method + " " + u.Path + "?" + b.String()
+}
+
+func (r *Recorder) scrubHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := h.Clone()
+	for _, name := range r.ScrubHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}
+
+// scrubBody はJSONのボディから ScrubFields の値を取り除く。JSONでないボディはそのまま記録する
+func (r *Recorder) scrubBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	if !r.scrubValue(v) {
+		return string(body)
+	}
+	scrubbed, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(scrubbed)
+}
+
+// scrubValue は値を取り除いた項目があった場合にtrueを返す
+func (r *Recorder) scrubValue(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if r.isScrubField(k) {
+				v[k] = Redacted
+				changed = true
+				continue
+			}
+			changed = r.scrubValue(child) || changed
+		}
+	case []interface{}:
+		for _, child := range v {
+			changed = r.scrubValue(child) || changed
+		}
+	}
+	return changed
+}
+
+func (r *Recorder) isScrubField(name string) bool {
+	for _, f := range r.ScrubFields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnused は再生されなかったやり取りが残っている場合に AssertAllUsed が返すエラー
+var ErrUnused = errors.New("cassette has unused interactions")
+
+// AssertAllUsed は再生モードですべてのやり取りが使われたかを確認する。
+// リクエストが減った（取得処理が変わった）ことを検知するのに使う
+func (r *Recorder) AssertAllUsed() error {
+	if r.mode != ModeReplay {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []string
+	for i, it := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, it.Request.Method+" "+it.Request.URL)
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnused, strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// encodeJSON はURLの & などをエスケープせずに読みやすい形で書き出す
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/YamaguchiKoki/feedle_batch"
+
+// エクスポーターの種類
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// 共通のスパン属性
+const (
+	AttrSource    = attribute.Key("feedle.source")
+	AttrRunID     = attribute.Key("feedle.run_id")
+	AttrConfigID  = attribute.Key("feedle.config_id")
+	AttrUserID    = attribute.Key("feedle.user_id")
+	AttrItems     = attribute.Key("feedle.items")
+	AttrSaved     = attribute.Key("feedle.items_saved")
+	AttrPage      = attribute.Key("feedle.page")
+	AttrSubreddit = attribute.Key("reddit.subreddit")
+	AttrKeyword   = attribute.Key("reddit.keyword")
+)
+
+type Options struct {
+	// Exporter は none, otlp, stdout のいずれか
+	Exporter string
+	// Endpoint はOTLP/HTTPの送信先URL。空の場合はOTEL_EXPORTER_OTLP_*環境変数に従う
+	Endpoint string
+	// Insecure はOTLPをTLSなしで送信する
+	Insecure bool
+	// SampleRatio はサンプリングする割合（0〜1）
+	SampleRatio float64
+	// ServiceName はリソース属性service.nameの値
+	ServiceName string
+	// Writer はstdoutエクスポーターの出力先
+	Writer io.Writer
+}
+
+// ShutdownFunc は未送信のスパンを送信してプロバイダを停止する
+type ShutdownFunc func(context.Context) error
+
+// Setup はグローバルなTracerProviderを設定する。Exporterがnoneの場合は何もしない
+func Setup(ctx context.Context, opts Options) (ShutdownFunc, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	switch strings.ToLower(opts.Exporter) {
+	case ExporterNone, "":
+		return noop, nil
+	case ExporterStdout:
+		w := opts.Writer
+		if w == nil {
+			w = io.Discard
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return noop, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return noop, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = exp
+	default:
+		return noop, fmt.Errorf("invalid trace exporter %q (expected %q, %q or %q)", opts.Exporter, ExporterNone, ExporterOTLP, ExporterStdout)
+	}
+
+	serviceName := opts.ServiceName
+	if serviceName == "" {
+		serviceName = "feedle-batch"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	ratio := opts.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start はスパンを開始する。トレースが無効な場合は何も記録しないスパンが返る
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail はスパンにエラーを記録してerrをそのまま返す。errがnilの場合は何もしない
+func Fail(span trace.Span, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		span.SetStatus(codes.Error, "canceled")
+		return err
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
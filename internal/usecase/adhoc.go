@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/logging"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+// AdHocFetchUsecase はコマンドラインで指定した条件で取得する。
+// ユーザーの設定や実行履歴には触れず、取得結果も保存しない（動作確認・スモークテスト用）
+type AdHocFetchUsecase struct {
+	fetchers   *fetcher.Registry
+	normalizer output.ContentNormalizer
+}
+
+func NewAdHocFetchUsecase(fetchers *fetcher.Registry) *AdHocFetchUsecase {
+	return &AdHocFetchUsecase{
+		fetchers: fetchers,
+	}
+}
+
+// SetNormalizer は保存する場合と同じく本文を正規化して返すようにする
+func (uc *AdHocFetchUsecase) SetNormalizer(normalizer output.ContentNormalizer) {
+	uc.normalizer = normalizer
+}
+
+// Execute はすべての条件で取得した結果を返す。
+// 一部の条件で失敗した場合は、取得できた結果と失敗をまとめたエラーの両方を返す
+func (uc *AdHocFetchUsecase) Execute(ctx context.Context, details []model.FetchConfigDetail) ([]*model.FetchedData, error) {
+	if len(details) == 0 {
+		return nil, errors.New("no ad-hoc fetch targets given")
+	}
+
+	// 取得を始める前にすべての条件を検証する
+	var invalid []error
+	for i, detail := range details {
+		if err := detail.Validate(); err != nil {
+			invalid = append(invalid, fmt.Errorf("target #%d (%s): %w", i+1, detail.GetDataSourceID(), err))
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid ad-hoc fetch targets: %w", errors.Join(invalid...))
+	}
+
+	var results []*model.FetchedData
+	var errs []error
+	for i, detail := range details {
+		source := detail.GetDataSourceID()
+		targetCtx := logging.With(ctx, logging.KeySource, source, "target", i+1)
+
+		f, err := uc.fetchers.Get(source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		data, err := f.FetchDetail(targetCtx, detail)
+		if err != nil {
+			slog.ErrorContext(targetCtx, "Ad-hoc fetch failed", "error", err)
+			errs = append(errs, fmt.Errorf("target #%d (%s): %w", i+1, source, err))
+			continue
+		}
+
+		normalizeContent(targetCtx, uc.normalizer, data)
+		slog.InfoContext(targetCtx, "Ad-hoc fetch completed", "items", len(data))
+		results = append(results, data...)
+	}
+
+	return results, errors.Join(errs...)
+}
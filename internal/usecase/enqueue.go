@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/logging"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+)
+
+const DefaultMaxAttempts = 5
+
+// EnqueueDueConfigsUsecase は実行時刻を迎えた設定をジョブキューに登録する
+type EnqueueDueConfigsUsecase struct {
+	fetchConfigService *service.FetchConfigService
+	queue              output.FetchJobQueue
+}
+
+func NewEnqueueDueConfigsUsecase(
+	fetchConfigService *service.FetchConfigService,
+	queue output.FetchJobQueue,
+) *EnqueueDueConfigsUsecase {
+	return &EnqueueDueConfigsUsecase{
+		fetchConfigService: fetchConfigService,
+		queue:              queue,
+	}
+}
+
+// Execute は登録したジョブ数を返す。既に未完了のジョブがある設定は登録しない
+func (uc *EnqueueDueConfigsUsecase) Execute(ctx context.Context, maxAttempts int) (int, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	enrichedConfigs, err := uc.fetchConfigService.GetDueEnrichedConfigs(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get enriched configs: %w", err)
+	}
+
+	enqueued := 0
+	for _, cfg := range enrichedConfigs {
+		cfgCtx := logging.WithConfig(ctx, cfg.UserFetchConfig.UserID, cfg.UserFetchConfig.ID, cfg.UserFetchConfig.DataSourceID)
+
+		ok, err := uc.queue.Enqueue(cfgCtx, cfg.UserFetchConfig.ID, maxAttempts)
+		if err != nil {
+			slog.ErrorContext(cfgCtx, "Failed to enqueue config", "error", err)
+			continue
+		}
+		if !ok {
+			slog.InfoContext(cfgCtx, "Config already has a pending job, skipping")
+			continue
+		}
+		enqueued++
+	}
+
+	return enqueued, nil
+}
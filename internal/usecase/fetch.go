@@ -2,75 +2,570 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpcache"
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
 	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/logging"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
 	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/tracing"
 	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type FetchAndSaveUsecase struct {
 	fetchConfigService *service.FetchConfigService
 	dataRepository     output.FetchedDataRepository
-	redditFetcher      fetcher.Fetcher[model.RedditFetchConfigDetail]
+	runRepository      output.FetchRunRepository
+	fetchers           *fetcher.Registry
+	storyService       *service.StoryService
+	duplicateService   *service.DuplicateService
+	normalizer         output.ContentNormalizer
+	articleService     *service.ArticleService
+}
+
+// runTarget は実行中に処理する設定とその進捗
+type runTarget struct {
+	item   *model.FetchRunItem
+	config *service.EnrichedFetchConfig
 }
 
 func NewFetchAndSaveUsecase(
 	fetchConfigService *service.FetchConfigService,
 	dRepo output.FetchedDataRepository,
-	redditFetcher fetcher.Fetcher[model.RedditFetchConfigDetail],
+	runRepo output.FetchRunRepository,
+	fetchers *fetcher.Registry,
 ) *FetchAndSaveUsecase {
 	return &FetchAndSaveUsecase{
 		fetchConfigService: fetchConfigService,
 		dataRepository:     dRepo,
-		redditFetcher:      redditFetcher,
+		runRepository:      runRepo,
+		fetchers:           fetchers,
 	}
 }
 
-func (uc *FetchAndSaveUsecase) Execute(ctx context.Context) error {
-	// 検索設定を取得する
-	enrichedConfigs, err := uc.fetchConfigService.GetActiveUsersEnrichedConfigs(ctx)
+// SetStoryService は保存前に同じ記事を指すアイテムをストーリーにまとめる処理を有効にする
+func (uc *FetchAndSaveUsecase) SetStoryService(svc *service.StoryService) {
+	uc.storyService = svc
+}
+
+// SetNormalizer は保存前に本文を正規化する処理を有効にする
+func (uc *FetchAndSaveUsecase) SetNormalizer(normalizer output.ContentNormalizer) {
+	uc.normalizer = normalizer
+}
+
+// SetArticleService は保存前にリンク投稿のリンク先の記事を取り出す処理を有効にする
+func (uc *FetchAndSaveUsecase) SetArticleService(svc *service.ArticleService) {
+	uc.articleService = svc
+}
+
+// SetDuplicateService は保存前に内容がほぼ同じ以前のアイテムを探して印を付ける処理を有効にする
+func (uc *FetchAndSaveUsecase) SetDuplicateService(svc *service.DuplicateService) {
+	uc.duplicateService = svc
+}
+
+// Execute は新しい実行を開始し、実行時刻を迎えた設定を処理して結果のレポートを返す。
+// 設定ごとの進捗を記録するため、途中で中断した場合は Resume で続きから再開できる。
+// 実行を記録できなかった場合以外は、エラー時もレポートを返す
+func (uc *FetchAndSaveUsecase) Execute(ctx context.Context) (*model.FetchRunReport, error) {
+	return uc.ExecuteSelected(ctx, service.FetchSelector{})
+}
+
+// ExecuteSelected は条件に一致する設定のみを対象に Execute と同様の実行を行う
+func (uc *FetchAndSaveUsecase) ExecuteSelected(ctx context.Context, sel service.FetchSelector) (*model.FetchRunReport, error) {
+	return uc.execute(ctx, sel, nil)
+}
+
+// ExecuteUntil は Execute と同様の実行を行う。stopが閉じられた場合は処理中の設定を終えたところで中断し、
+// 残りの設定は未処理のまま実行を interrupted として終了する（Resume で再開できる）
+func (uc *FetchAndSaveUsecase) ExecuteUntil(ctx context.Context, stop <-chan struct{}) (*model.FetchRunReport, error) {
+	return uc.execute(ctx, service.FetchSelector{}, stop)
+}
+
+func (uc *FetchAndSaveUsecase) execute(ctx context.Context, sel service.FetchSelector, stop <-chan struct{}) (*model.FetchRunReport, error) {
+	now := time.Now()
+	circuits := uc.fetchers.CircuitSnapshots()
+
+	run := model.NewFetchRun(now)
+	ctx, span := tracing.Start(ctx, "fetch.run", tracing.AttrRunID.String(run.ID.String()))
+	defer span.End()
+	if !sel.IsZero() {
+		span.SetAttributes(attribute.Bool("feedle.targeted", true))
+		// 指定した設定の取り直しのため、前回と同じレスポンスでも読み飛ばさない
+		ctx = httpcache.Refresh(ctx)
+	}
+	ctx = logging.WithRunID(ctx, run.ID)
+
+	// 実行時刻を迎えた検索設定を取得する
+	enrichedConfigs, err := uc.fetchConfigService.GetSelectedEnrichedConfigs(ctx, now, sel)
 	if err != nil {
-		return fmt.Errorf("failed to get enriched configs: %w", err)
+		err = fmt.Errorf("failed to get enriched configs: %w", err)
+		if cerr := uc.runRepository.Create(ctx, run); cerr != nil {
+			return nil, tracing.Fail(span, errors.Join(err, fmt.Errorf("failed to create fetch run: %w", cerr)))
+		}
+		uc.finishRun(ctx, run, model.FetchRunStatusFailed, circuits)
+		return uc.buildReport(ctx, run, nil, nil, circuits), tracing.Fail(span, err)
 	}
+	span.SetAttributes(attribute.Int("feedle.configs", len(enrichedConfigs)))
 
-	for _, cfg := range enrichedConfigs {
-		data, err := uc.fetchData(ctx, cfg)
+	// 対象がない場合は実行を記録しない（デーモンの定期実行で空の実行が溜まらないようにする）
+	if len(enrichedConfigs) == 0 {
+		finished := time.Now()
+		run.Status = model.FetchRunStatusCompleted
+		run.FinishedAt = &finished
+		slog.DebugContext(ctx, "No configs are due")
+		return uc.buildReport(ctx, run, nil, nil, circuits), nil
+	}
+
+	if err := uc.runRepository.Create(ctx, run); err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("failed to create fetch run: %w", err))
+	}
+	slog.InfoContext(ctx, "Started fetch run")
+
+	items := make([]model.FetchRunItem, len(enrichedConfigs))
+	targets := make([]runTarget, len(enrichedConfigs))
+	for i := range enrichedConfigs {
+		items[i] = model.FetchRunItem{
+			RunID:    run.ID,
+			ConfigID: enrichedConfigs[i].UserFetchConfig.ID,
+			Status:   model.FetchRunItemStatusPending,
+		}
+		targets[i] = runTarget{item: &items[i], config: &enrichedConfigs[i]}
+	}
+
+	if err := uc.runRepository.CreateItems(ctx, items); err != nil {
+		uc.finishRun(ctx, run, model.FetchRunStatusFailed, circuits)
+		return uc.buildReport(ctx, run, nil, nil, circuits), tracing.Fail(span, fmt.Errorf("failed to record fetch run items: %w", err))
+	}
+
+	if !uc.processTargets(ctx, targets, now, stop) {
+		slog.WarnContext(ctx, "Fetch run interrupted, resume it with --resume", "run_id", run.ID)
+		uc.finishRun(ctx, run, model.FetchRunStatusInterrupted, circuits)
+		return uc.buildReport(ctx, run, items, targets, circuits), nil
+	}
+	uc.finishRun(ctx, run, model.FetchRunStatusCompleted, circuits)
+	return uc.buildReport(ctx, run, items, targets, circuits), nil
+}
+
+// Resume は中断した実行のうち、成功していない設定のみを再度処理する。
+// レポートには以前の実行で成功した設定も含まれる
+func (uc *FetchAndSaveUsecase) Resume(ctx context.Context, runID uuid.UUID) (*model.FetchRunReport, error) {
+	now := time.Now()
+	circuits := uc.fetchers.CircuitSnapshots()
+
+	ctx, span := tracing.Start(ctx, "fetch.run",
+		tracing.AttrRunID.String(runID.String()),
+		attribute.Bool("feedle.resumed", true))
+	defer span.End()
+
+	ctx = logging.WithRunID(ctx, runID)
+	// 中断前に取得したレスポンスがキャッシュに残っていても読み飛ばさずに取り直す
+	ctx = httpcache.Refresh(ctx)
+
+	run, err := uc.runRepository.GetByID(ctx, runID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	items, err := uc.runRepository.GetItems(ctx, runID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	var targets []runTarget
+	for i := range items {
+		item := &items[i]
+		if item.IsCompleted() {
+			continue
+		}
+
+		cfg, err := uc.fetchConfigService.GetEnrichedConfig(ctx, item.ConfigID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config for resume", logging.KeyConfigID, item.ConfigID, "error", err)
+			uc.recordItemResult(ctx, item, now, 0, err)
+			continue
+		}
+		targets = append(targets, runTarget{item: item, config: cfg})
+	}
+
+	slog.InfoContext(ctx, "Resuming fetch run", "remaining", len(targets), "total", len(items))
+	span.SetAttributes(attribute.Int("feedle.configs", len(targets)))
+
+	run.Status = model.FetchRunStatusRunning
+	run.FinishedAt = nil
+	if err := uc.runRepository.Update(ctx, run); err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("failed to update fetch run: %w", err))
+	}
+
+	if !uc.processTargets(ctx, targets, now, nil) {
+		slog.WarnContext(ctx, "Fetch run interrupted, resume it with --resume", "run_id", run.ID)
+		uc.finishRun(ctx, run, model.FetchRunStatusInterrupted, circuits)
+		return uc.buildReport(ctx, run, items, targets, circuits), nil
+	}
+	uc.finishRun(ctx, run, model.FetchRunStatusCompleted, circuits)
+	return uc.buildReport(ctx, run, items, targets, circuits), nil
+}
+
+// processTargets は設定を順に処理する。stopが閉じられた場合やctxがキャンセルされた場合
+// （実行ロックを失った場合など）は残りの設定を処理せずにfalseを返す
+func (uc *FetchAndSaveUsecase) processTargets(ctx context.Context, targets []runTarget, fetchedAt time.Time, stop <-chan struct{}) bool {
+	for i, t := range targets {
+		select {
+		case <-stop:
+			slog.WarnContext(ctx, "Stop requested, leaving remaining configs for resume", "remaining", len(targets)-i)
+			return false
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Run cancelled, leaving remaining configs for resume", "remaining", len(targets)-i,
+				"error", context.Cause(ctx))
+			return false
+		default:
+		}
+
+		started := time.Now()
+		t.item.StartedAt = &started
+
+		cfgCtx := configContext(ctx, *t.config)
+		count, err := uc.ProcessConfig(cfgCtx, *t.config, fetchedAt)
+		uc.recordItemResult(cfgCtx, t.item, started, count, err)
+		if errors.Is(err, fetcher.ErrCircuitOpen) {
+			slog.WarnContext(cfgCtx, "Skipped config", "error", err)
+			continue
+		}
 		if err != nil {
-			log.Printf("Failed to fetch data for config %s: %v", cfg.UserFetchConfig.ID, err)
+			slog.ErrorContext(cfgCtx, "Failed to process config", "error", err)
 			continue
 		}
 
-		if err := uc.saveData(ctx, cfg.UserFetchConfig.ID, data); err != nil {
-			log.Printf("Failed to save data for config %s: %v", cfg.UserFetchConfig.ID, err)
+		slog.InfoContext(cfgCtx, "Successfully processed config", "items_saved", count,
+			"duration", time.Since(started))
+	}
+	return true
+}
+
+// recordItemResult は設定の処理結果をチェックポイントとして保存する
+func (uc *FetchAndSaveUsecase) recordItemResult(ctx context.Context, item *model.FetchRunItem, started time.Time, count int, procErr error) {
+	finished := time.Now()
+	if item.StartedAt == nil {
+		item.StartedAt = &started
+	}
+	item.FinishedAt = &finished
+	item.ItemsSaved = count
+	item.Error = nil
+	item.Status = model.FetchRunItemStatusSucceeded
+	if procErr != nil {
+		msg := procErr.Error()
+		item.Error = &msg
+		item.Status = model.FetchRunItemStatusFailed
+		if errors.Is(procErr, fetcher.ErrCircuitOpen) {
+			item.Status = model.FetchRunItemStatusSkipped
+		}
+	}
+
+	if err := uc.runRepository.UpdateItem(ctx, item); err != nil {
+		slog.ErrorContext(ctx, "Failed to record progress", logging.KeyConfigID, item.ConfigID, "error", err)
+	}
+}
+
+// finishRunTimeout は実行の終了を記録する時間の上限
+const finishRunTimeout = 10 * time.Second
+
+// finishRun は実行を終了として記録する。before は実行開始時のブレーカーの状態で、この実行でスキップした件数を求めるのに使う。
+// ctxがキャンセルされていても記録できるよう、キャンセルを引き継がない短い期限のコンテキストで保存する
+func (uc *FetchAndSaveUsecase) finishRun(ctx context.Context, run *model.FetchRun, status model.FetchRunStatus, before map[string]fetcher.CircuitSnapshot) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishRunTimeout)
+	defer cancel()
+
+	for source, cb := range uc.circuitStates(before) {
+		if cb.State == fetcher.CircuitClosed && cb.Skipped == 0 {
 			continue
 		}
+		slog.WarnContext(ctx, "Circuit breaker is not closed",
+			logging.KeySource, source,
+			"state", cb.State,
+			"consecutive_failures", cb.ConsecutiveFailures,
+			"skipped_configs", cb.Skipped,
+			"last_error", cb.LastError)
+	}
 
-		log.Printf("Successfully processed config %s: fetched and saved %d items",
-			cfg.UserFetchConfig.ID, len(data))
+	finished := time.Now()
+	run.Status = status
+	run.FinishedAt = &finished
+	if err := uc.runRepository.Update(ctx, run); err != nil {
+		slog.ErrorContext(ctx, "Failed to finish fetch run", "error", err)
+		return
 	}
-	return nil
+	slog.InfoContext(ctx, "Finished fetch run", "status", status, "duration", finished.Sub(run.StartedAt))
+}
+
+// circuitStates はブレーカーの現在の状態を返す。Skipped は実行開始時の状態（before）からの件数にする
+func (uc *FetchAndSaveUsecase) circuitStates(before map[string]fetcher.CircuitSnapshot) map[string]fetcher.CircuitSnapshot {
+	states := uc.fetchers.CircuitSnapshots()
+	for source, cb := range states {
+		states[source] = cb.Since(before[source])
+	}
+	return states
+}
+
+// buildReport は実行結果のレポートを作り、集計をログとスパンに記録する。
+// circuits は実行開始時のブレーカーの状態で、この実行でスキップした件数を求めるのに使う
+func (uc *FetchAndSaveUsecase) buildReport(ctx context.Context, run *model.FetchRun, items []model.FetchRunItem, targets []runTarget, circuits map[string]fetcher.CircuitSnapshot) *model.FetchRunReport {
+	report := model.NewFetchRunReport(run, items)
+	for source, cb := range uc.circuitStates(circuits) {
+		if report.CircuitBreakers == nil {
+			report.CircuitBreakers = make(map[string]model.CircuitBreakerReport)
+		}
+		report.CircuitBreakers[source] = model.CircuitBreakerReport{
+			State:               string(cb.State),
+			ConsecutiveFailures: cb.ConsecutiveFailures,
+			Skipped:             cb.Skipped,
+			OpenedAt:            cb.OpenedAt,
+			LastError:           cb.LastError,
+		}
+	}
+
+	sources := make(map[uuid.UUID]string, len(targets))
+	for _, t := range targets {
+		sources[t.config.UserFetchConfig.ID] = t.config.UserFetchConfig.DataSourceID
+	}
+	for i := range report.Configs {
+		report.Configs[i].Source = sources[report.Configs[i].ConfigID]
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("feedle.outcome", string(report.Outcome)),
+		attribute.Int("feedle.configs_succeeded", report.Totals.Succeeded),
+		attribute.Int("feedle.configs_failed", report.Totals.Failed),
+		tracing.AttrSaved.Int(report.Totals.ItemsSaved))
+	slog.InfoContext(ctx, "Fetch run report",
+		"outcome", report.Outcome,
+		"configs", report.Totals.Configs,
+		"succeeded", report.Totals.Succeeded,
+		"failed", report.Totals.Failed,
+		"skipped", report.Totals.Skipped,
+		"items_saved", report.Totals.ItemsSaved)
+
+	return report
+}
+
+// ProcessConfig は1つの設定について取得と保存を行い、成功した場合は取得時刻を記録する
+func (uc *FetchAndSaveUsecase) ProcessConfig(ctx context.Context, cfg service.EnrichedFetchConfig, fetchedAt time.Time) (int, error) {
+	ctx = configContext(ctx, cfg)
+	source := cfg.UserFetchConfig.DataSourceID
+
+	ctx, span := tracing.Start(ctx, "fetch.config",
+		tracing.AttrSource.String(source),
+		tracing.AttrConfigID.String(cfg.UserFetchConfig.ID.String()),
+		tracing.AttrUserID.String(cfg.UserFetchConfig.UserID.String()))
+	defer span.End()
+
+	// レスポンスのキャッシュは取得したデータを保存できた場合のみ書き込む
+	fetchCtx, cache := httpcache.Defer(ctx)
+	fetchStart := time.Now()
+	data, err := uc.fetchData(fetchCtx, cfg)
+	if errors.Is(err, fetcher.ErrCircuitOpen) {
+		// データソースの障害であり設定の失敗ではないため、連続失敗には数えない
+		metrics.ConfigsProcessed.WithLabelValues(source, "skipped").Inc()
+		span.SetAttributes(attribute.Bool("feedle.skipped", true))
+		return 0, err
+	}
+	if err != nil {
+		metrics.FetchDuration.WithLabelValues(source, "error").Observe(time.Since(fetchStart).Seconds())
+		metrics.ConfigsProcessed.WithLabelValues(source, "failed").Inc()
+
+		suspended, rerr := uc.fetchConfigService.RecordFailure(ctx, cfg.UserFetchConfig, err, time.Now())
+		if rerr != nil {
+			slog.ErrorContext(ctx, "Failed to record failure", "error", rerr)
+		}
+		if suspended {
+			slog.WarnContext(ctx, "Config has been suspended after repeated failures",
+				"error_kind", model.ClassifyFetchError(err))
+		}
+		return 0, tracing.Fail(span, fmt.Errorf("failed to fetch data: %w", err))
+	}
+
+	metrics.FetchDuration.WithLabelValues(source, "success").Observe(time.Since(fetchStart).Seconds())
+	metrics.ItemsFetched.WithLabelValues(source).Add(float64(len(data)))
+
+	// 以前の実行で保存済みのアイテムは加工も保存もしない
+	fetched := len(data)
+	data = uc.dropStored(ctx, data)
+	metrics.ItemsSkipped.WithLabelValues(source).Add(float64(fetched - len(data)))
+
+	uc.transformData(ctx, source, cfg.UserFetchConfig.UserID, data)
+
+	saved, err := uc.saveData(ctx, data)
+	metrics.ItemsSaved.WithLabelValues(source).Add(float64(saved))
+	metrics.ItemsSkipped.WithLabelValues(source).Add(float64(len(data) - saved))
+	span.SetAttributes(tracing.AttrItems.Int(len(data)), tracing.AttrSaved.Int(saved))
+	if err != nil {
+		metrics.ConfigsProcessed.WithLabelValues(source, "failed").Inc()
+		return saved, tracing.Fail(span, fmt.Errorf("failed to save data: %w", err))
+	}
+	metrics.ConfigsProcessed.WithLabelValues(source, "succeeded").Inc()
+	cache.Commit(ctx)
+
+	if err := uc.fetchConfigService.MarkFetched(ctx, cfg.UserFetchConfig, fetchedAt); err != nil {
+		slog.ErrorContext(ctx, "Failed to record last fetch time", "error", err)
+	}
+
+	return len(data), nil
+}
+
+// ProcessConfigByID は設定IDから設定を読み込んでProcessConfigを実行する。
+// キューに追加された後に無効化・停止された設定は取得せず、成功として扱う（再試行しない）
+func (uc *FetchAndSaveUsecase) ProcessConfigByID(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) (int, error) {
+	cfg, err := uc.fetchConfigService.GetEnrichedConfig(ctx, configID)
+	if err != nil {
+		return 0, err
+	}
+	if !cfg.UserFetchConfig.IsActive || cfg.UserFetchConfig.IsSuspended() {
+		slog.InfoContext(ctx, "Skipped config that is no longer active",
+			"is_active", cfg.UserFetchConfig.IsActive, "suspended", cfg.UserFetchConfig.IsSuspended())
+		return 0, nil
+	}
+	return uc.ProcessConfig(ctx, *cfg, fetchedAt)
 }
 
 func (uc *FetchAndSaveUsecase) fetchData(ctx context.Context, cfg service.EnrichedFetchConfig) ([]*model.FetchedData, error) {
-	switch detail := cfg.Detail.(type) {
-	case *model.RedditFetchConfigDetail:
-		return uc.redditFetcher.Fetch(ctx, *detail)
-	default:
-		return nil, fmt.Errorf("unsupported data source: %s", cfg.UserFetchConfig.DataSourceID)
+	ctx, span := tracing.Start(ctx, "fetch.fetch")
+	defer span.End()
+
+	f, err := uc.fetchers.Get(cfg.Detail.GetDataSourceID())
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	data, err := f.FetchDetail(ctx, cfg.Detail)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	span.SetAttributes(tracing.AttrItems.Int(len(data)))
+	return data, nil
+}
+
+// dropStored は既に保存されているアイテムを除く。確認できない場合はすべて保存を試みる
+func (uc *FetchAndSaveUsecase) dropStored(ctx context.Context, data []*model.FetchedData) []*model.FetchedData {
+	if len(data) == 0 {
+		return data
+	}
+	ids := lo.Map(data, func(d *model.FetchedData, _ int) uuid.UUID { return d.ID })
+	existing, err := uc.dataRepository.ExistingIDs(ctx, ids)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to look up stored items", "error", err)
+		return data
+	}
+	if len(existing) == 0 {
+		return data
 	}
+	slog.DebugContext(ctx, "Skipping already stored items", "count", len(existing))
+	return lo.Reject(data, func(d *model.FetchedData, _ int) bool { return existing[d.ID] })
 }
 
-func (uc *FetchAndSaveUsecase) saveData(ctx context.Context, configID uuid.UUID, data []*model.FetchedData) error {
+// transformData は保存前の加工（本文の正規化、ストーリーへの紐付け、重複の判定、記事の取り出し）を行う。
+// 加工に失敗してもアイテムは保存する
+func (uc *FetchAndSaveUsecase) transformData(ctx context.Context, source string, userID uuid.UUID, data []*model.FetchedData) {
+	ctx, span := tracing.Start(ctx, "fetch.transform", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	normalizeContent(ctx, uc.normalizer, data)
+	uc.linkStories(ctx, userID, data)
+	uc.markDuplicates(ctx, source, userID, data)
+	uc.enrichArticles(ctx, data)
+}
+
+// normalizeContent は本文を正規化する。正規化できなかったアイテムも元の本文のまま保存する
+func normalizeContent(ctx context.Context, normalizer output.ContentNormalizer, data []*model.FetchedData) {
+	if normalizer == nil || len(data) == 0 {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "fetch.normalize", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	var errs []error
 	for _, item := range data {
+		if item == nil {
+			continue
+		}
+		if err := normalizer.Normalize(ctx, item); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		slog.WarnContext(ctx, "Failed to normalize some items", "failed", len(errs), "error", tracing.Fail(span, err))
+	}
+}
+
+// linkStories はアイテムをユーザーのストーリーに紐付ける。紐付けに失敗してもアイテムは保存する
+func (uc *FetchAndSaveUsecase) linkStories(ctx context.Context, userID uuid.UUID, data []*model.FetchedData) {
+	if uc.storyService == nil || len(data) == 0 {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "fetch.link_stories", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	linked, err := uc.storyService.LinkStories(ctx, userID, data)
+	span.SetAttributes(attribute.Int("feedle.stories_linked", linked))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to link some items to stories", "linked", linked, "error", tracing.Fail(span, err))
+	}
+}
+
+// markDuplicates は内容がほぼ同じ以前のアイテムを探す。重複と判定したアイテムも印を付けて保存する
+func (uc *FetchAndSaveUsecase) markDuplicates(ctx context.Context, source string, userID uuid.UUID, data []*model.FetchedData) {
+	if uc.duplicateService == nil || len(data) == 0 {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "fetch.mark_duplicates", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	marked, err := uc.duplicateService.MarkDuplicates(ctx, userID, data)
+	span.SetAttributes(attribute.Int("feedle.near_duplicates", marked))
+	metrics.ItemsNearDuplicate.WithLabelValues(source).Add(float64(marked))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check for near-duplicate items", "error", tracing.Fail(span, err))
+	}
+}
+
+// enrichArticles はリンク先の記事をメタデータに加える。取り出せなかったアイテムも記事なしで保存する
+func (uc *FetchAndSaveUsecase) enrichArticles(ctx context.Context, data []*model.FetchedData) {
+	if uc.articleService == nil || len(data) == 0 {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "fetch.enrich_articles", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	enriched, err := uc.articleService.Enrich(ctx, data)
+	span.SetAttributes(attribute.Int("feedle.articles_extracted", enriched))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to extract some linked articles", "extracted", enriched, "error", tracing.Fail(span, err))
+	}
+}
+
+// saveData は保存できた件数を返す。途中で失敗した場合は残りを保存しない
+func (uc *FetchAndSaveUsecase) saveData(ctx context.Context, data []*model.FetchedData) (int, error) {
+	ctx, span := tracing.Start(ctx, "fetch.save", tracing.AttrItems.Int(len(data)))
+	defer span.End()
+
+	for i, item := range data {
 		if err := uc.dataRepository.Create(ctx, item); err != nil {
-			return fmt.Errorf("failed to save data item: %w", err)
+			span.SetAttributes(tracing.AttrSaved.Int(i))
+			return i, tracing.Fail(span, fmt.Errorf("failed to save data item: %w", err))
 		}
 	}
+	span.SetAttributes(tracing.AttrSaved.Int(len(data)))
+
+	slog.DebugContext(ctx, "Saved items", "count", len(data))
+	return len(data), nil
+}
 
-	log.Printf("Saved %d items for config %s", len(data), configID)
-	return nil
+// configContext は設定の識別情報をログ属性としてcontextに付与する
+func configContext(ctx context.Context, cfg service.EnrichedFetchConfig) context.Context {
+	return logging.WithConfig(ctx, cfg.UserFetchConfig.UserID, cfg.UserFetchConfig.ID, cfg.UserFetchConfig.DataSourceID)
 }
@@ -0,0 +1,511 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/fetcher/reddit"
+	"github.com/YamaguchiKoki/feedle_batch/internal/adapter/httpcache"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/service"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fake"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fakereddit"
+	"github.com/google/uuid"
+)
+
+// fetchFixture はインメモリのリポジトリと偽のReddit APIでユースケースを組み立てる
+type fetchFixture struct {
+	repos  *fake.Repositories
+	server *fakereddit.Server
+	uc     *FetchAndSaveUsecase
+	userID uuid.UUID
+}
+
+// fetchFixtureOptions は必要なテストだけが差し替える部品
+type fetchFixtureOptions struct {
+	// runs を指定すると実行履歴の保存を経由して実行を中断できる
+	runs *interruptingRuns
+	// cache を指定するとReddit APIへのリクエストをHTTPキャッシュ経由にする
+	cache *httpcache.Store
+}
+
+func newFetchFixture(t *testing.T, opts fetchFixtureOptions) *fetchFixture {
+	t.Helper()
+
+	server := fakereddit.NewServer()
+	t.Cleanup(server.Close)
+	server.RequireAuth("client-id", "client-secret")
+
+	client := server.Client()
+	if opts.cache != nil {
+		client = &http.Client{Transport: httpcache.NewTransport("reddit", opts.cache, time.Hour, client.Transport)}
+	}
+
+	auth := reddit.NewRedditAuth("client-id", "client-secret", "")
+	auth.SetTokenURL(server.TokenURL())
+	f := reddit.NewRedditFetcherWithClient("", auth, client)
+	f.SetBaseURL(server.URL)
+	f.SetRequestInterval(0)
+
+	fetchers := fetcher.NewRegistry()
+	fetchers.Register("reddit", fetcher.Adapt(f))
+
+	repos := fake.NewRepositories()
+	userID := uuid.New()
+	repos.Users.Add(model.UserID(userID.String()))
+
+	configService := service.NewFetchConfigService(repos.Users, repos.FetchConfigs, repos.RedditFetchConfigs)
+	configService.SetSuspendThreshold(2)
+
+	var runRepo output.FetchRunRepository = repos.FetchRuns
+	if runs := opts.runs; runs != nil {
+		runs.FetchRunRepository = repos.FetchRuns
+		runRepo = runs
+	}
+
+	return &fetchFixture{
+		repos:  repos,
+		server: server,
+		uc:     NewFetchAndSaveUsecase(configService, repos.FetchedData, runRepo, fetchers),
+		userID: userID,
+	}
+}
+
+// addConfig はRedditの設定を追加する。keywordsを指定した場合は検索、それ以外はサブレディットの一覧を取得する
+func (f *fetchFixture) addConfig(name, subreddit string, limit int, keywords ...string) uuid.UUID {
+	cfg := model.NewUserFetchConfig(f.userID, name, "reddit")
+	f.repos.FetchConfigs.Add(*cfg)
+
+	detail := model.NewRedditFetchConfigDetail(cfg.ID)
+	detail.Subreddit = subreddit
+	detail.LimitCount = limit
+	detail.Keywords = keywords
+	if len(keywords) > 0 {
+		detail.SortBy = model.RedditSortNew
+	}
+	f.repos.RedditFetchConfigs.Add(*detail)
+	return cfg.ID
+}
+
+func (f *fetchFixture) config(t *testing.T, id uuid.UUID) *model.UserFetchConfig {
+	t.Helper()
+	cfg, err := f.repos.FetchConfigs.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID(%s): %v", id, err)
+	}
+	return cfg
+}
+
+// interruptingRuns は指定した数の設定の結果が記録された時点でstopを閉じ（cancelを指定した場合はキャンセルし）、実行を中断させる
+type interruptingRuns struct {
+	*fake.FetchRunRepository
+	after  int
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	updated int
+	stop    chan struct{}
+}
+
+// Update はキャンセルされたコンテキストでは実際のリポジトリと同じく保存に失敗する
+func (r *interruptingRuns) Update(ctx context.Context, run *model.FetchRun) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.FetchRunRepository.Update(ctx, run)
+}
+
+func (r *interruptingRuns) UpdateItem(ctx context.Context, item *model.FetchRunItem) error {
+	if err := r.FetchRunRepository.UpdateItem(ctx, item); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated++
+	if r.updated == r.after {
+		if r.cancel != nil {
+			r.cancel()
+		} else {
+			close(r.stop)
+		}
+	}
+	return nil
+}
+
+func reportItem(t *testing.T, report *model.FetchRunReport, configID uuid.UUID) model.FetchRunReportItem {
+	t.Helper()
+	for _, item := range report.Configs {
+		if item.ConfigID == configID {
+			return item
+		}
+	}
+	t.Fatalf("config %s is not in the report", configID)
+	return model.FetchRunReportItem{}
+}
+
+func TestFetchAndSaveUsecasePaginatesSearch(t *testing.T) {
+	f := newFetchFixture(t, fetchFixtureOptions{})
+	f.server.SetPageSize(10)
+	for i := 0; i < 25; i++ {
+		f.server.AddPosts("golang", fakereddit.Post{Title: fmt.Sprintf("generics %d", i)})
+	}
+	for i := 0; i < 8; i++ {
+		f.server.AddPosts("rust", fakereddit.Post{Title: fmt.Sprintf("rust %d", i)})
+	}
+	searchID := f.addConfig("search", "golang", 25, "generics")
+	listingID := f.addConfig("listing", "rust", 5)
+
+	report, err := f.uc.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if report.Outcome != model.FetchRunOutcomeSuccess {
+		t.Fatalf("Outcome = %s, want success (report %+v)", report.Outcome, report.Configs)
+	}
+
+	if got := len(f.repos.FetchedData.ByConfigID(searchID)); got != 25 {
+		t.Errorf("search saved %d items, want 25", got)
+	}
+	if got := len(f.repos.FetchedData.ByConfigID(listingID)); got != 5 {
+		t.Errorf("listing saved %d items, want 5", got)
+	}
+
+	var searches []string
+	for _, r := range f.server.Requests() {
+		if strings.HasSuffix(r.Path, "/search.json") {
+			searches = append(searches, r.Query.Get("after"))
+		}
+	}
+	if len(searches) != 3 || searches[0] != "" || searches[1] == "" || searches[2] == "" {
+		t.Errorf("search requests with after = %q, want 3 pages following after", searches)
+	}
+	for _, r := range f.server.Requests() {
+		if r.Path != "/api/v1/access_token" && !strings.HasPrefix(r.Authorization, "Bearer ") {
+			t.Errorf("request %s has no bearer token", r.Path)
+		}
+	}
+
+	for _, id := range []uuid.UUID{searchID, listingID} {
+		if f.config(t, id).LastFetchedAt == nil {
+			t.Errorf("config %s has no last_fetched_at", id)
+		}
+	}
+}
+
+func TestFetchAndSaveUsecaseSuspendsPermanentFailures(t *testing.T) {
+	f := newFetchFixture(t, fetchFixtureOptions{})
+	f.server.AddPosts("golang", fakereddit.Post{Title: "ok"})
+	okID := f.addConfig("ok", "golang", 10)
+	goneID := f.addConfig("gone", "deleted_sub", 10)
+
+	for run := 1; run <= 2; run++ {
+		// 成功した設定は次の実行時刻まで対象外になり、失敗した設定だけが再度実行される
+		report, err := f.uc.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: Execute: %v", run, err)
+		}
+		item := reportItem(t, report, goneID)
+		if item.Status != model.FetchRunItemStatusFailed || !strings.Contains(item.Error, "not found") {
+			t.Errorf("run %d: gone config = %s %q, want failed with not found", run, item.Status, item.Error)
+		}
+
+		cfg := f.config(t, goneID)
+		if cfg.ConsecutiveFailures != run {
+			t.Errorf("run %d: ConsecutiveFailures = %d, want %d", run, cfg.ConsecutiveFailures, run)
+		}
+		if suspended := cfg.IsSuspended(); suspended != (run == 2) {
+			t.Errorf("run %d: suspended = %v, want %v", run, suspended, run == 2)
+		}
+		if cfg.LastErrorKind == nil || *cfg.LastErrorKind != model.FetchErrorKindNotFound {
+			t.Errorf("run %d: LastErrorKind = %v, want %s", run, cfg.LastErrorKind, model.FetchErrorKindNotFound)
+		}
+	}
+	if got := len(f.repos.FetchedData.ByConfigID(okID)); got != 1 {
+		t.Errorf("ok config saved %d items, want 1", got)
+	}
+
+	// 停止した設定は実行されない
+	requests := len(f.server.Requests())
+	report, err := f.uc.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute after suspension: %v", err)
+	}
+	if report.Totals.Configs != 0 || len(f.server.Requests()) != requests {
+		t.Errorf("suspended config was fetched again (configs %d)", report.Totals.Configs)
+	}
+
+	if err := f.uc.fetchConfigService.Unsuspend(context.Background(), goneID); err != nil {
+		t.Fatalf("Unsuspend: %v", err)
+	}
+	if cfg := f.config(t, goneID); cfg.IsSuspended() || cfg.ConsecutiveFailures != 0 {
+		t.Errorf("after Unsuspend: suspended %v, failures %d", cfg.IsSuspended(), cfg.ConsecutiveFailures)
+	}
+}
+
+// 対象固有でない失敗は連続しても自動停止しない
+func TestFetchAndSaveUsecaseDoesNotSuspendSourceWideFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   model.FetchErrorKind
+	}{
+		{"レート制限", http.StatusTooManyRequests, model.FetchErrorKindRateLimited},
+		// データソース全体のブロックやAPIキーの失効でも返るため、設定の誤りとはみなさない
+		{"アクセスの拒否", http.StatusForbidden, model.FetchErrorKindForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFetchFixture(t, fetchFixtureOptions{})
+			f.server.AddPosts("golang", fakereddit.Post{Title: "ok"})
+			id := f.addConfig("failing", "golang", 10)
+			f.server.FailNext("/r/", tt.status, 10)
+
+			for run := 1; run <= 3; run++ {
+				report, err := f.uc.Execute(context.Background())
+				if err != nil {
+					t.Fatalf("run %d: Execute: %v", run, err)
+				}
+				if report.Outcome != model.FetchRunOutcomeFailure {
+					t.Errorf("run %d: Outcome = %s, want failure", run, report.Outcome)
+				}
+			}
+
+			cfg := f.config(t, id)
+			if cfg.IsSuspended() {
+				t.Errorf("config was suspended after status %d: %v", tt.status, *cfg.SuspendedReason)
+			}
+			if cfg.ConsecutiveFailures != 3 {
+				t.Errorf("ConsecutiveFailures = %d, want 3", cfg.ConsecutiveFailures)
+			}
+			if cfg.LastErrorKind == nil || *cfg.LastErrorKind != tt.want {
+				t.Errorf("LastErrorKind = %v, want %s", cfg.LastErrorKind, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAndSaveUsecaseResume(t *testing.T) {
+	runs := &interruptingRuns{after: 1, stop: make(chan struct{})}
+	f := newFetchFixture(t, fetchFixtureOptions{runs: runs})
+	ids := []uuid.UUID{
+		f.addConfig("first", "golang", 10),
+		f.addConfig("second", "rust", 10),
+		f.addConfig("third", "python", 10),
+	}
+	for _, sub := range []string{"golang", "rust", "python"} {
+		f.server.AddPosts(sub, fakereddit.Post{Title: sub + " 1"}, fakereddit.Post{Title: sub + " 2"})
+	}
+
+	report, err := f.uc.ExecuteUntil(context.Background(), runs.stop)
+	if err != nil {
+		t.Fatalf("ExecuteUntil: %v", err)
+	}
+	if report.Status != model.FetchRunStatusInterrupted || report.Totals.Succeeded != 1 || report.Totals.Pending != 2 {
+		t.Fatalf("interrupted run: status %s, totals %+v, want interrupted with 1 succeeded and 2 pending",
+			report.Status, report.Totals)
+	}
+	var done uuid.UUID
+	for _, item := range report.Configs {
+		if item.Status == model.FetchRunItemStatusSucceeded {
+			done = item.ConfigID
+		}
+	}
+	if got := len(f.repos.FetchedData.All()); got != 2 {
+		t.Fatalf("saved %d items before interruption, want 2", got)
+	}
+	if run, err := f.repos.FetchRuns.GetByID(context.Background(), report.RunID); err != nil || run.Status != model.FetchRunStatusInterrupted || run.FinishedAt == nil {
+		t.Fatalf("stored interrupted run = %+v (%v), want interrupted and finished", run, err)
+	}
+
+	resumed, err := f.uc.Resume(context.Background(), report.RunID)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if resumed.RunID != report.RunID || resumed.Status != model.FetchRunStatusCompleted || resumed.Outcome != model.FetchRunOutcomeSuccess {
+		t.Fatalf("resumed run: %s %s %s, want %s completed success", resumed.RunID, resumed.Status, resumed.Outcome, report.RunID)
+	}
+	if resumed.Totals.Succeeded != 3 || resumed.Totals.ItemsSaved != 6 {
+		t.Errorf("resumed totals = %+v, want 3 succeeded and 6 items", resumed.Totals)
+	}
+
+	// 中断前に成功した設定は再度取得しない
+	for _, id := range ids {
+		if got := len(f.repos.FetchedData.ByConfigID(id)); got != 2 {
+			t.Errorf("config %s saved %d items, want 2 (done before interruption: %v)", id, got, id == done)
+		}
+	}
+	run, err := f.repos.FetchRuns.GetByID(context.Background(), report.RunID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if run.Status != model.FetchRunStatusCompleted || run.FinishedAt == nil {
+		t.Errorf("stored run = %s finished %v, want completed", run.Status, run.FinishedAt)
+	}
+}
+
+// デーモンの停止などでコンテキストがキャンセルされた場合も、実行を実行中のまま残さない
+func TestFetchAndSaveUsecaseFinishesCancelledRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := &interruptingRuns{after: 1, cancel: cancel}
+	f := newFetchFixture(t, fetchFixtureOptions{runs: runs})
+	for _, sub := range []string{"golang", "rust"} {
+		f.addConfig(sub, sub, 10)
+		f.server.AddPosts(sub, fakereddit.Post{Title: sub})
+	}
+
+	report, err := f.uc.Execute(ctx)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if report.Totals.Succeeded != 1 || report.Totals.Pending != 1 {
+		t.Fatalf("totals = %+v, want 1 succeeded and 1 pending", report.Totals)
+	}
+	run, err := f.repos.FetchRuns.GetByID(context.Background(), report.RunID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if run.Status != model.FetchRunStatusInterrupted || run.FinishedAt == nil {
+		t.Errorf("stored run = %s finished %v, want interrupted", run.Status, run.FinishedAt)
+	}
+}
+
+func TestFetchAndSaveUsecaseRefetchesAfterFailedSave(t *testing.T) {
+	store, err := httpcache.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFetchFixture(t, fetchFixtureOptions{cache: store})
+	f.server.AddPosts("golang", fakereddit.Post{Title: "one"}, fakereddit.Post{Title: "two"})
+	id := f.addConfig("cached", "golang", 10)
+
+	// 保存に失敗した取得のレスポンスはキャッシュに残さない
+	f.repos.FetchedData.Err = errors.New("database is unavailable")
+	report, err := f.uc.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if report.Outcome != model.FetchRunOutcomeFailure {
+		t.Fatalf("Outcome = %s, want failure", report.Outcome)
+	}
+
+	f.repos.FetchedData.Err = nil
+	if _, err := f.uc.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := len(f.repos.FetchedData.ByConfigID(id)); got != 2 {
+		t.Fatalf("saved %d items after the failed save, want 2", got)
+	}
+
+	// 保存できた後は有効期限内のレスポンスをキャッシュから返し、変更なしとして読み飛ばす
+	requests := len(f.server.Requests())
+	report, err = f.uc.ExecuteSelected(context.Background(), service.FetchSelector{})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if report.Totals.Configs != 0 {
+		t.Fatalf("config ran again before it was due")
+	}
+
+	// 設定を指定した取り直しではキャッシュを使わない
+	report, err = f.uc.ExecuteSelected(context.Background(), service.FetchSelector{ConfigIDs: []uuid.UUID{id}})
+	if err != nil {
+		t.Fatalf("ExecuteSelected: %v", err)
+	}
+	if got := len(f.server.Requests()) - requests; got != 1 {
+		t.Errorf("targeted run sent %d requests, want 1", got)
+	}
+	if item := reportItem(t, report, id); item.Status != model.FetchRunItemStatusSucceeded {
+		t.Errorf("targeted run = %s %q, want succeeded", item.Status, item.Error)
+	}
+}
+
+// countingExtractor はリンク先を取得せず、記事を取り出したURLを記録する
+type countingExtractor struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (e *countingExtractor) Extract(ctx context.Context, pageURL string) (*model.Article, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.urls = append(e.urls, pageURL)
+	return &model.Article{URL: pageURL, Title: "article"}, nil
+}
+
+func TestFetchAndSaveUsecaseEnrichesOnlyNewItems(t *testing.T) {
+	f := newFetchFixture(t, fetchFixtureOptions{})
+	extractor := &countingExtractor{}
+	f.uc.SetArticleService(service.NewArticleService(extractor))
+	f.server.AddPosts("golang", fakereddit.Post{Title: "one", URL: "https://blog.example.com/one"})
+	id := f.addConfig("articles", "golang", 10)
+
+	if _, err := f.uc.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// 保存済みのアイテムのリンク先は取り直しでも取得しない
+	f.server.AddPosts("golang", fakereddit.Post{Title: "two", URL: "https://blog.example.com/two"})
+	report, err := f.uc.ExecuteSelected(context.Background(), service.FetchSelector{ConfigIDs: []uuid.UUID{id}})
+	if err != nil {
+		t.Fatalf("ExecuteSelected: %v", err)
+	}
+	if item := reportItem(t, report, id); item.Status != model.FetchRunItemStatusSucceeded {
+		t.Fatalf("targeted run = %s %q, want succeeded", item.Status, item.Error)
+	}
+
+	want := []string{"https://blog.example.com/one", "https://blog.example.com/two"}
+	if !slices.Equal(extractor.urls, want) {
+		t.Errorf("extracted %v, want %v", extractor.urls, want)
+	}
+	for _, item := range f.repos.FetchedData.ByConfigID(id) {
+		if item.Metadata[model.MetadataArticle] == nil {
+			t.Errorf("item %q was saved without its article", item.Title)
+		}
+	}
+}
+
+// キューに追加された後に無効化・停止された設定のジョブは取得せずに終える
+func TestFetchAndSaveUsecaseProcessConfigByIDSkipsInactiveConfigs(t *testing.T) {
+	f := newFetchFixture(t, fetchFixtureOptions{})
+	f.server.AddPosts("golang", fakereddit.Post{Title: "one"})
+	suspendedAt := time.Now()
+	tests := []struct {
+		name   string
+		update func(ctx context.Context, cfg *model.UserFetchConfig) error
+	}{
+		{"無効化された設定", func(ctx context.Context, cfg *model.UserFetchConfig) error {
+			cfg.IsActive = false
+			return f.repos.FetchConfigs.Update(ctx, cfg)
+		}},
+		{"停止された設定", func(ctx context.Context, cfg *model.UserFetchConfig) error {
+			return f.repos.FetchConfigs.RecordFailure(ctx, cfg.ID, model.FetchFailure{SuspendedAt: &suspendedAt})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := f.addConfig(tt.name, "golang", 10)
+			if err := tt.update(context.Background(), f.config(t, id)); err != nil {
+				t.Fatal(err)
+			}
+
+			requests := len(f.server.Requests())
+			count, err := f.uc.ProcessConfigByID(context.Background(), id, time.Now())
+			if err != nil || count != 0 {
+				t.Fatalf("ProcessConfigByID = %d, %v, want 0 without error", count, err)
+			}
+			if got := len(f.server.Requests()) - requests; got != 0 {
+				t.Errorf("sent %d requests for an inactive config", got)
+			}
+		})
+	}
+}
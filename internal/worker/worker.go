@@ -0,0 +1,236 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/logging"
+	"github.com/YamaguchiKoki/feedle_batch/internal/metrics"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBatchSize    = 1
+	defaultLease        = 5 * time.Minute
+	defaultPollInterval = 10 * time.Second
+	defaultRetryBase    = 30 * time.Second
+	maxRetryBackoff     = time.Hour
+)
+
+// Processor はジョブが指す設定の取得と保存を行う
+type Processor interface {
+	ProcessConfigByID(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) (int, error)
+}
+
+type Options struct {
+	// ID はleaseの保持者として記録されるワーカー識別子
+	ID string
+	// BatchSize は1回のClaimで取得するジョブ数
+	BatchSize int
+	// Lease はジョブを他のワーカーに渡さずに保持する期間。Lease/3ごとにハートビートで延長する
+	Lease time.Duration
+	// PollInterval はキューが空のときに次のClaimまで待つ時間
+	PollInterval time.Duration
+	// RetryBase は再試行までの待ち時間の基準値。試行回数に応じて指数的に増やす
+	RetryBase time.Duration
+}
+
+// Worker はジョブキューからジョブを取得して実行する
+type Worker struct {
+	queue     output.FetchJobQueue
+	processor Processor
+	opts      Options
+}
+
+func New(queue output.FetchJobQueue, processor Processor, opts Options) *Worker {
+	if opts.ID == "" {
+		opts.ID = uuid.NewString()
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = defaultRetryBase
+	}
+
+	return &Worker{
+		queue:     queue,
+		processor: processor,
+		opts:      opts,
+	}
+}
+
+// Run はctxがキャンセルされるまでジョブを処理する。
+// Claimしたジョブは処理を待つ間も含めてすべてleaseを延長する。
+// キャンセル時に処理中のジョブは最後まで実行し、未着手のジョブは延長をやめてleaseの期限切れ後に他のワーカーに渡す
+func (w *Worker) Run(ctx context.Context) error {
+	ctx = logging.With(ctx, "worker_id", w.opts.ID)
+	slog.InfoContext(ctx, "Worker started")
+
+	for {
+		if ctx.Err() != nil {
+			slog.InfoContext(ctx, "Worker stopped")
+			return nil
+		}
+
+		jobs, err := w.queue.Claim(ctx, w.opts.ID, w.opts.BatchSize, w.opts.Lease)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim jobs", "error", err)
+		}
+
+		if len(jobs) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.opts.PollInterval):
+			}
+			continue
+		}
+
+		w.processBatch(ctx, jobs)
+	}
+}
+
+// processBatch はClaimしたジョブを順に処理する。処理を待つジョブのleaseも延長し続ける
+func (w *Worker) processBatch(ctx context.Context, jobs []model.FetchJob) {
+	leases := make([]*lease, len(jobs))
+	for i, job := range jobs {
+		jobCtx := logging.With(context.WithoutCancel(ctx), "job_id", job.ID.String(), logging.KeyConfigID, job.ConfigID.String())
+		leases[i] = w.hold(jobCtx, job.ID)
+	}
+	defer func() {
+		for _, l := range leases {
+			l.release()
+		}
+	}()
+
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		w.process(leases[i], job)
+		leases[i].release()
+	}
+}
+
+// lease はClaimしたジョブのleaseを延長し続ける。延長できなくなった場合はctxをErrLeaseLostでキャンセルする
+type lease struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// hold はジョブのleaseの延長を始める
+func (w *Worker) hold(ctx context.Context, jobID uuid.UUID) *lease {
+	ctx, cancel := context.WithCancelCause(ctx)
+	l := &lease{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		w.heartbeat(l, jobID)
+	}()
+	return l
+}
+
+// release はleaseの延長をやめる
+func (l *lease) release() {
+	l.cancel(context.Canceled)
+	<-l.done
+}
+
+// lost はleaseが他のワーカーに移ったかを返す
+func (l *lease) lost() bool {
+	return errors.Is(context.Cause(l.ctx), output.ErrLeaseLost)
+}
+
+func (w *Worker) process(l *lease, job model.FetchJob) {
+	ctx := l.ctx
+	if l.lost() {
+		slog.WarnContext(ctx, "Lease lost before the job started, leaving it to its new holder")
+		return
+	}
+
+	count, err := w.processor.ProcessConfigByID(ctx, job.ConfigID, time.Now())
+	if l.lost() {
+		// 他のワーカーが実行し直すため、結果を記録しない
+		slog.WarnContext(ctx, "Lease lost while processing, leaving the job to its new holder", "error", err)
+		return
+	}
+	// 結果の記録はleaseの延長を止めてから行う
+	ctx = context.WithoutCancel(ctx)
+	l.release()
+
+	if err != nil {
+		retryAfter := w.backoff(job.Attempts)
+		if job.IsLastAttempt() {
+			slog.ErrorContext(ctx, "Job failed on final attempt",
+				"attempt", job.Attempts, "max_attempts", job.MaxAttempts, "error", err)
+		} else {
+			metrics.Retries.WithLabelValues(string(model.ClassifyFetchError(err))).Inc()
+			slog.WarnContext(ctx, "Job failed, will retry",
+				"attempt", job.Attempts, "max_attempts", job.MaxAttempts, "retry_after", retryAfter, "error", err)
+		}
+		if ferr := w.queue.Fail(ctx, job.ID, w.opts.ID, err.Error(), retryAfter); ferr != nil {
+			slog.ErrorContext(ctx, "Failed to record job failure", "error", ferr)
+		}
+		return
+	}
+
+	if err := w.queue.Complete(ctx, job.ID, w.opts.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to complete job", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Job completed", "items_saved", count)
+}
+
+// heartbeat はreleaseされるまで定期的にleaseを延長する。
+// leaseが他のワーカーに移っていた場合や、延長に失敗し続けてleaseの期限が過ぎた場合はleaseを失ったものとする
+func (w *Worker) heartbeat(l *lease, jobID uuid.UUID) {
+	ticker := time.NewTicker(w.opts.Lease / 3)
+	defer ticker.Stop()
+	extended := time.Now()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.queue.Heartbeat(l.ctx, jobID, w.opts.ID, w.opts.Lease)
+			switch {
+			case err == nil:
+				extended = time.Now()
+			case errors.Is(err, output.ErrLeaseLost):
+				slog.WarnContext(l.ctx, "Lease lost", "error", err)
+				l.cancel(err)
+				return
+			case time.Since(extended) >= w.opts.Lease:
+				slog.WarnContext(l.ctx, "Heartbeat failed until the lease expired", "error", err)
+				l.cancel(fmt.Errorf("%w: %w", output.ErrLeaseLost, err))
+				return
+			default:
+				slog.WarnContext(l.ctx, "Heartbeat failed", "error", err)
+			}
+		}
+	}
+}
+
+// backoff は試行回数に応じた再試行までの待ち時間を返す
+func (w *Worker) backoff(attempts int) time.Duration {
+	// 試行回数が多いとDurationが桁あふれするため、上限との比較は変換前に行う
+	d := float64(w.opts.RetryBase) * math.Pow(2, float64(max(attempts-1, 0)))
+	if d > float64(maxRetryBackoff) {
+		return maxRetryBackoff
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,280 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YamaguchiKoki/feedle_batch/internal/domain/model"
+	"github.com/YamaguchiKoki/feedle_batch/internal/port/output"
+	"github.com/YamaguchiKoki/feedle_batch/internal/testing/fake"
+	"github.com/google/uuid"
+)
+
+type processorFunc func(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) (int, error)
+
+func (f processorFunc) ProcessConfigByID(ctx context.Context, configID uuid.UUID, fetchedAt time.Time) (int, error) {
+	return f(ctx, configID, fetchedAt)
+}
+
+// recordingQueue はキューの操作を記録し、heartbeatErr を指定した場合はleaseの延長を失敗させる
+type recordingQueue struct {
+	*fake.FetchJobQueue
+	heartbeatErr error
+
+	mu         sync.Mutex
+	heartbeats map[uuid.UUID]int
+	finished   map[uuid.UUID]string
+}
+
+func newRecordingQueue() *recordingQueue {
+	return &recordingQueue{
+		FetchJobQueue: fake.NewFetchJobQueue(),
+		heartbeats:    make(map[uuid.UUID]int),
+		finished:      make(map[uuid.UUID]string),
+	}
+}
+
+func (q *recordingQueue) Heartbeat(ctx context.Context, jobID uuid.UUID, workerID string, lease time.Duration) error {
+	q.mu.Lock()
+	q.heartbeats[jobID]++
+	q.mu.Unlock()
+	if q.heartbeatErr != nil {
+		return q.heartbeatErr
+	}
+	return q.FetchJobQueue.Heartbeat(ctx, jobID, workerID, lease)
+}
+
+func (q *recordingQueue) Complete(ctx context.Context, jobID uuid.UUID, workerID string) error {
+	q.record(jobID, "complete")
+	return q.FetchJobQueue.Complete(ctx, jobID, workerID)
+}
+
+func (q *recordingQueue) Fail(ctx context.Context, jobID uuid.UUID, workerID string, reason string, retryAfter time.Duration) error {
+	q.record(jobID, "fail")
+	return q.FetchJobQueue.Fail(ctx, jobID, workerID, reason, retryAfter)
+}
+
+func (q *recordingQueue) record(jobID uuid.UUID, op string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finished[jobID] = op
+}
+
+func (q *recordingQueue) enqueue(t *testing.T, maxAttempts int) uuid.UUID {
+	t.Helper()
+	configID := uuid.New()
+	if _, err := q.Enqueue(context.Background(), configID, maxAttempts); err != nil {
+		t.Fatal(err)
+	}
+	return configID
+}
+
+// start はワーカーを動かし、止めて終了を待つ関数を返す
+func start(t *testing.T, w *Worker) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Run(ctx); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func jobStatuses(q *recordingQueue) map[uuid.UUID]model.FetchJob {
+	jobs := make(map[uuid.UUID]model.FetchJob)
+	for _, j := range q.Jobs() {
+		jobs[j.ConfigID] = j
+	}
+	return jobs
+}
+
+func allInStatus(q *recordingQueue, status model.FetchJobStatus) func() bool {
+	return func() bool {
+		for _, j := range q.Jobs() {
+			if j.Status != status {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func TestWorkerCompletesClaimedJobs(t *testing.T) {
+	q := newRecordingQueue()
+	configs := []uuid.UUID{q.enqueue(t, 3), q.enqueue(t, 3), q.enqueue(t, 3)}
+
+	var mu sync.Mutex
+	var processed []uuid.UUID
+	w := New(q, processorFunc(func(ctx context.Context, configID uuid.UUID, _ time.Time) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, configID)
+		return 1, nil
+	}), Options{ID: "worker-1", BatchSize: 2, PollInterval: 10 * time.Millisecond})
+	stop := start(t, w)
+	waitFor(t, "all jobs to succeed", allInStatus(q, model.FetchJobStatusSucceeded))
+	stop()
+
+	if len(processed) != len(configs) {
+		t.Fatalf("processed %v, want each of %v once", processed, configs)
+	}
+	for i, id := range configs {
+		if processed[i] != id {
+			t.Errorf("processed %v, want %v in order", processed, configs)
+		}
+	}
+	for id, job := range jobStatuses(q) {
+		if job.Attempts != 1 || job.LeasedBy != nil {
+			t.Errorf("job for %s: attempts %d, leased by %v, want 1 attempt and released", id, job.Attempts, job.LeasedBy)
+		}
+	}
+}
+
+// 1回のClaimで取得したジョブは、順番を待つ間もleaseを延長し、他のワーカーに取られない
+func TestWorkerHeartbeatsQueuedJobs(t *testing.T) {
+	q := newRecordingQueue()
+	first, second := q.enqueue(t, 3), q.enqueue(t, 3)
+	const lease = 60 * time.Millisecond
+
+	var stolen []model.FetchJob
+	w := New(q, processorFunc(func(ctx context.Context, configID uuid.UUID, _ time.Time) (int, error) {
+		if configID == first {
+			// leaseの期間より長く処理する
+			time.Sleep(3 * lease)
+			jobs, err := q.Claim(ctx, "worker-2", 10, lease)
+			if err != nil {
+				t.Errorf("Claim: %v", err)
+			}
+			stolen = jobs
+		}
+		return 0, nil
+	}), Options{ID: "worker-1", BatchSize: 2, Lease: lease, PollInterval: 10 * time.Millisecond})
+	stop := start(t, w)
+	waitFor(t, "both jobs to succeed", allInStatus(q, model.FetchJobStatusSucceeded))
+	stop()
+
+	if len(stolen) != 0 {
+		t.Errorf("another worker claimed %d jobs of the batch", len(stolen))
+	}
+	jobs := jobStatuses(q)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heartbeats[jobs[second].ID] == 0 {
+		t.Errorf("queued job was not heartbeated while waiting")
+	}
+}
+
+// leaseを失ったジョブは処理を中断し、結果を記録しない
+func TestWorkerStopsJobWhenLeaseIsLost(t *testing.T) {
+	tests := []struct {
+		name         string
+		heartbeatErr error
+	}{
+		{"他のワーカーに移った", output.ErrLeaseLost},
+		{"延長の失敗が続いてleaseが切れた", errors.New("connection refused")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newRecordingQueue()
+			q.heartbeatErr = tt.heartbeatErr
+			q.enqueue(t, 3)
+
+			cause := make(chan error, 1)
+			w := New(q, processorFunc(func(ctx context.Context, _ uuid.UUID, _ time.Time) (int, error) {
+				select {
+				case <-ctx.Done():
+					cause <- context.Cause(ctx)
+					return 0, ctx.Err()
+				case <-time.After(5 * time.Second):
+					cause <- nil
+					return 0, nil
+				}
+			}), Options{ID: "worker-1", Lease: 30 * time.Millisecond, PollInterval: time.Hour})
+			stop := start(t, w)
+
+			if err := <-cause; !errors.Is(err, output.ErrLeaseLost) {
+				t.Fatalf("job context cause = %v, want ErrLeaseLost", err)
+			}
+			stop()
+
+			job := q.Jobs()[0]
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if op, ok := q.finished[job.ID]; ok {
+				t.Errorf("recorded %s for a job whose lease was lost", op)
+			}
+			if job.Status != model.FetchJobStatusRunning {
+				t.Errorf("status = %s, want it left running for the lease to expire", job.Status)
+			}
+		})
+	}
+}
+
+func TestWorkerRetriesFailedJobs(t *testing.T) {
+	q := newRecordingQueue()
+	retried := q.enqueue(t, 3)
+	last := q.enqueue(t, 1)
+
+	w := New(q, processorFunc(func(ctx context.Context, _ uuid.UUID, _ time.Time) (int, error) {
+		return 0, errors.New("status 503")
+	}), Options{ID: "worker-1", BatchSize: 2, RetryBase: time.Hour, PollInterval: 10 * time.Millisecond})
+	started := time.Now()
+	stop := start(t, w)
+	waitFor(t, "both jobs to fail", func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.finished) == 2
+	})
+	stop()
+
+	jobs := jobStatuses(q)
+	if job := jobs[retried]; job.Status != model.FetchJobStatusQueued || job.RunAfter.Before(started.Add(time.Hour)) || job.LastError == nil {
+		t.Errorf("retried job = %s run after %v (error %v), want queued for an hour later", job.Status, job.RunAfter, job.LastError)
+	}
+	if job := jobs[last]; job.Status != model.FetchJobStatusDead {
+		t.Errorf("job on its final attempt = %s, want dead", job.Status)
+	}
+}
+
+func TestWorkerBackoff(t *testing.T) {
+	w := New(nil, nil, Options{RetryBase: 30 * time.Second})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, maxRetryBackoff},
+		{30, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := w.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}